@@ -0,0 +1,154 @@
+// The employeedata command converts the scheduler's employee documents which
+// still carry the legacy data blob, moving the company information,
+// assignments, variations, balances, leaves and requests to the top-level
+// fields and copying the blob's labor codes into each assignment.  Each
+// converted employee is listed with what moved.  Once it has run, the lazy
+// conversion in the employee methods can be switched off by setting
+// EMPLOYEE_LEGACY_DATA=false.
+//
+// Employees stored in postgres are converted as they are written, so only the
+// mongo collection needs this.
+//
+//	employeedata -env .env [-config settings.yaml] [-dry-run] [-batch 500]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/employees"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	envFile := flag.String("env", ".env", "env file with the database settings")
+	cfgFile := flag.String("config", "", "optional yaml or toml settings file")
+	dryRun := flag.Bool("dry-run", false,
+		"report the employees which would change without saving them")
+	batch := flag.Int("batch", 500, "employees saved per bulk write")
+	flag.Parse()
+
+	cfg, err := config.Load(config.LoadOptions{
+		EnvFile:    *envFile,
+		ConfigFile: *cfgFile,
+		Required:   []string{"MONGO_URI"},
+		NoAuth:     true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := cfg.DatabaseOptions()
+	opts.PostgresURL = ""
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := config.Open(ctx, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close(context.Background())
+
+	coll := config.GetCollection(client.Mongo, "scheduler", "employees")
+	converted, err := convertEmployees(ctx, coll, *batch, *dryRun)
+	if *dryRun {
+		fmt.Printf("%d employees would be converted (dry run)\n", converted)
+	} else {
+		fmt.Printf("%d employees converted\n", converted)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// convertEmployees converts each employee with a legacy data blob, saving
+// them in bulk writes of the batch size, and returns the number converted.
+// The write only replaces a document which still has its blob, so one changed
+// since it was read is left for the next run; the count comes from what each
+// bulk write modified and a batch's employees are listed once it is written.
+func convertEmployees(ctx context.Context, coll *mongo.Collection, batch int,
+	dryRun bool) (int, error) {
+	legacy := bson.M{"data": bson.M{"$exists": true, "$ne": nil}}
+	cursor, err := coll.Find(ctx, legacy,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	var writes []mongo.WriteModel
+	var changes []string
+	flush := func() error {
+		if len(writes) == 0 {
+			return nil
+		}
+		result, err := coll.BulkWrite(ctx, writes,
+			options.BulkWrite().SetOrdered(false))
+		if result != nil {
+			count += int(result.ModifiedCount)
+		}
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		if skipped := len(writes) - int(result.ModifiedCount); skipped > 0 {
+			fmt.Printf("%d of these changed since they were read and were "+
+				"left for the next run\n", skipped)
+		}
+		writes, changes = writes[:0], changes[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var emp employees.Employee
+		if err := cursor.Decode(&emp); err != nil {
+			return count, err
+		}
+		if !emp.HasLegacyData() {
+			continue
+		}
+		change := describeChange(emp)
+		if err := emp.ConvertFromData(); err != nil {
+			return count, fmt.Errorf("%s: %w", emp.ID.Hex(), err)
+		}
+		if dryRun {
+			fmt.Println(change)
+			count++
+			continue
+		}
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": emp.ID, "data": bson.M{"$exists": true}}).
+			SetReplacement(emp))
+		changes = append(changes, change)
+		if len(writes) >= batch {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	return count, flush()
+}
+
+// describeChange gives the line reported for a converted employee, read from
+// its blob before the conversion clears it.
+func describeChange(emp employees.Employee) string {
+	data := emp.Data
+	return fmt.Sprintf("%s %-30s assignments: %d, variations: %d, "+
+		"balances: %d, leaves: %d, requests: %d, labor codes: %d",
+		emp.ID.Hex(), emp.Name.GetLastFirst(), len(data.Assignments),
+		len(data.Variations), len(data.Balances), len(data.Leaves),
+		len(data.Requests), len(data.LaborCodes))
+}
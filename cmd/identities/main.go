@@ -0,0 +1,197 @@
+// The identities command merges the SOAP journal's users into the shared
+// authentication users, so one account logs into both the scheduler and the
+// journal.  A journal user whose email address matches an account, ignoring
+// case, has its admin flag and preferences saved as the account's journal
+// profile and its journal entries moved to the account, keeping the
+// account's password.  Any other journal user becomes a new account with its
+// own id and password.  Each journal user is listed with what was done, and
+// the journal's users collection is left in place to be dropped once the
+// journal reads the accounts.
+//
+// The journal is only kept in mongo, so there is nothing to merge in
+// postgres.
+//
+//	identities -env .env [-config settings.yaml] [-dry-run]
+//	           [-soap-db soap] [-soap-users users] [-soap-entries entries]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/soap/entries"
+	"github.com/erneap/go-pg-models/users"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	envFile := flag.String("env", ".env", "env file with the database settings")
+	cfgFile := flag.String("config", "", "optional yaml or toml settings file")
+	dryRun := flag.Bool("dry-run", false,
+		"report the journal users which would merge without saving them")
+	soapDB := flag.String("soap-db", "soap", "the journal's database")
+	soapUsers := flag.String("soap-users", "users",
+		"the journal's users collection")
+	soapEntries := flag.String("soap-entries", "entries",
+		"the journal's entries collection")
+	flag.Parse()
+
+	cfg, err := config.Load(config.LoadOptions{
+		EnvFile:    *envFile,
+		ConfigFile: *cfgFile,
+		Required:   []string{"MONGO_URI"},
+		NoAuth:     true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := cfg.DatabaseOptions()
+	opts.PostgresURL = ""
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := config.Open(ctx, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close(context.Background())
+
+	m := merger{
+		accounts: config.GetCollection(client.Mongo, "authenticate", "users"),
+		users:    config.GetCollection(client.Mongo, *soapDB, *soapUsers),
+		entries:  config.GetCollection(client.Mongo, *soapDB, *soapEntries),
+		dryRun:   *dryRun,
+	}
+	merged, created, err := m.run(ctx)
+	if *dryRun {
+		fmt.Printf("%d users would be merged, %d created (dry run)\n", merged,
+			created)
+	} else {
+		fmt.Printf("%d users merged, %d created\n", merged, created)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// journalUser is a user as the journal kept it before the shared identity,
+// with the journal's settings beside the account's.
+type journalUser struct {
+	users.Identity  `bson:",inline"`
+	entries.Profile `bson:",inline"`
+}
+
+// merger moves the journal's users into the accounts collection.
+type merger struct {
+	accounts *mongo.Collection
+	users    *mongo.Collection
+	entries  *mongo.Collection
+	dryRun   bool
+}
+
+// run merges each of the journal's users, returning the number merged into
+// existing accounts and the number created as new accounts.
+func (m *merger) run(ctx context.Context) (int, int, error) {
+	cursor, err := m.users.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	merged, created := 0, 0
+	for cursor.Next(ctx) {
+		var journal journalUser
+		if err := cursor.Decode(&journal); err != nil {
+			return merged, created, err
+		}
+		user := entries.User{
+			Identity:    journal.Identity,
+			Admin:       journal.Admin,
+			Preferences: journal.Preferences,
+		}
+		var account users.User
+		err := m.accounts.FindOne(ctx, emailFilter(user.EmailAddress)).
+			Decode(&account)
+		switch {
+		case err == nil:
+			if err := m.merge(ctx, &user, &account); err != nil {
+				return merged, created, err
+			}
+			merged++
+		case errors.Is(err, mongo.ErrNoDocuments):
+			if err := m.create(ctx, &user); err != nil {
+				return merged, created, err
+			}
+			created++
+		default:
+			return merged, created, err
+		}
+	}
+	return merged, created, cursor.Err()
+}
+
+// emailFilter matches the email address ignoring case.
+func emailFilter(email string) bson.M {
+	return bson.M{"emailAddress": bson.M{
+		"$regex":   "^" + regexp.QuoteMeta(email) + "$",
+		"$options": "i",
+	}}
+}
+
+// merge saves the journal user's profile to the matching account and moves
+// the user's journal entries to the account's id.
+func (m *merger) merge(ctx context.Context, user *entries.User,
+	account *users.User) error {
+	fmt.Printf("%s %-30s merged into %s %s\n", user.ID.Hex(),
+		user.GetLastFirst(), account.ID.Hex(), account.EmailAddress)
+	if m.dryRun {
+		return nil
+	}
+	identity, err := user.ToIdentity()
+	if err != nil {
+		return err
+	}
+	profile := "profiles." + entries.Application
+	_, err = m.accounts.UpdateOne(ctx, bson.M{"_id": account.ID},
+		bson.M{
+			"$set": bson.M{profile: identity.Profiles[entries.Application]},
+			"$inc": bson.M{"version": 1},
+		})
+	if err != nil {
+		return err
+	}
+	if account.ID == user.ID {
+		return nil
+	}
+	_, err = m.entries.UpdateMany(ctx, bson.M{"user": user.ID},
+		bson.M{"$set": bson.M{"user": account.ID}})
+	return err
+}
+
+// create saves the journal user as a new account with its journal profile,
+// keeping its id so its journal entries needn't move.
+func (m *merger) create(ctx context.Context, user *entries.User) error {
+	fmt.Printf("%s %-30s created as %s\n", user.ID.Hex(), user.GetLastFirst(),
+		user.EmailAddress)
+	if m.dryRun {
+		return nil
+	}
+	identity, err := user.ToIdentity()
+	if err != nil {
+		return err
+	}
+	identity.Version = 0
+	_, err = m.accounts.InsertOne(ctx, users.User{Identity: identity})
+	return err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+
+	"github.com/erneap/go-pg-models/employees"
+	"github.com/erneap/go-pg-models/logs"
+	"github.com/erneap/go-pg-models/notifications"
+	"github.com/erneap/go-pg-models/sites"
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/teams"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// collection describes a mongo collection to copy.  The convert function
+// decodes the document and returns the function writing it to postgres, which
+// isn't called during a dry run.  The table is the postgres table keyed by the
+// mongo id, or blank when the rows are found through the id map.  The reset
+// tables are those -reset empties before the collection is copied again,
+// their child tables emptied along with them.
+type collection struct {
+	name     string
+	database string
+	mongo    string
+	table    string
+	convert  func(raw bson.Raw) (primitive.ObjectID, writer, error)
+	reset    []string
+}
+
+type writer func(ctx context.Context, tx *gorm.DB) error
+
+var collections = []collection{
+	{"authenticate.users", "authenticate", "users", "users", convertUser,
+		[]string{"users"}},
+	{"authenticate.logs", "authenticate", "logs", "logs", convertLogEntry,
+		[]string{"logs"}},
+	{"scheduler.notifications", "scheduler", "notifications", "notifications",
+		convertNotification, []string{"notifications"}},
+	{"scheduler.teams", "scheduler", "teams", "", convertTeam,
+		[]string{"teams", "labor_codes"}},
+	{"scheduler.employees", "scheduler", "employees", "employees",
+		convertEmployee, []string{"employees"}},
+}
+
+func convertUser(raw bson.Raw) (primitive.ObjectID, writer, error) {
+	var user users.User
+	if err := bson.Unmarshal(raw, &user); err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+	return user.ID, func(ctx context.Context, tx *gorm.DB) error {
+		return stores.NewPgUserStore(tx).CreateUser(ctx, &user)
+	}, nil
+}
+
+func convertLogEntry(raw bson.Raw) (primitive.ObjectID, writer, error) {
+	var entry logs.LogEntry
+	if err := bson.Unmarshal(raw, &entry); err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+	return entry.ID, func(ctx context.Context, tx *gorm.DB) error {
+		return stores.NewPgLogStore(tx).CreateLogEntry(ctx, &entry)
+	}, nil
+}
+
+func convertNotification(raw bson.Raw) (primitive.ObjectID, writer, error) {
+	var msg notifications.Notification
+	if err := bson.Unmarshal(raw, &msg); err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+	return msg.ID, func(ctx context.Context, tx *gorm.DB) error {
+		return stores.NewPgNotificationStore(tx).CreateMessage(ctx, &msg)
+	}, nil
+}
+
+// convertEmployee moves any legacy data blob into the top level fields, so the
+// assignments, variations, balances, leaves and requests each go to their own
+// table.
+func convertEmployee(raw bson.Raw) (primitive.ObjectID, writer, error) {
+	var emp employees.Employee
+	if err := bson.Unmarshal(raw, &emp); err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+	emp.ConvertFromData()
+	return emp.ID, func(ctx context.Context, tx *gorm.DB) error {
+		return stores.NewPgEmployeeStore(tx).CreateEmployee(ctx, &emp)
+	}, nil
+}
+
+// mongoTeam is the team document as stored in mongo, where the id is an
+// object id rather than the postgres serial id of teams.Team.
+type mongoTeam struct {
+	ID             primitive.ObjectID    `bson:"_id"`
+	Name           string                `bson:"name"`
+	Workcodes      []teams.Workcode      `bson:"workcodes"`
+	Sites          []sites.Site          `bson:"sites"`
+	Companies      []teams.Company       `bson:"companies,omitempty"`
+	ContactTypes   []teams.ContactType   `bson:"contacttypes,omitempty"`
+	SpecialtyTypes []teams.SpecialtyType `bson:"specialties,omitempty"`
+}
+
+// convertTeam builds the postgres team, keyed by the mongo id in its
+// object_id, since the employees and the roles' scopes name their team by
+// that id.  The new serial id is also recorded against the mongo id in the id
+// map, which the verification reads.
+func convertTeam(raw bson.Raw) (primitive.ObjectID, writer, error) {
+	var doc mongoTeam
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+	team := &teams.Team{
+		Name:           doc.Name,
+		Workcodes:      doc.Workcodes,
+		Sites:          doc.Sites,
+		Companies:      doc.Companies,
+		ContactTypes:   doc.ContactTypes,
+		SpecialtyTypes: doc.SpecialtyTypes,
+	}
+	for c, co := range team.Companies {
+		for h, hol := range co.Holidays {
+			hol.ConvertToDates()
+			co.Holidays[h] = hol
+		}
+		team.Companies[c] = co
+	}
+	return doc.ID, func(ctx context.Context, tx *gorm.DB) error {
+		if err := stores.NewPgTeamStore(tx).CreateTeam(ctx, team); err != nil {
+			return err
+		}
+		err := tx.Table("teams").Where("id = ?", team.ID).
+			UpdateColumn("object_id", doc.ID.Hex()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&idMapping{
+			Collection: "scheduler.teams",
+			MongoID:    doc.ID.Hex(),
+			PgID:       team.ID,
+		}).Error
+	}, nil
+}
@@ -0,0 +1,131 @@
+// The migrate command copies the scheduler's mongo databases into the
+// postgres schema.  Each collection is streamed in _id order and written in
+// batches, with a checkpoint saved in the same transaction as each batch, so
+// an interrupted run picks up where it stopped.  Once the copy is done, the
+// row counts and id checksums of both sides are compared and reported,
+// along with the employees joined to their team on each side.
+//
+// A rerun carries on from the checkpoints and doesn't write over the rows
+// already copied.  -reset starts the selected collections over, emptying
+// their postgres tables, and the tables cascading from them, first.
+//
+//	migrate -env .env [-config settings.yaml] [-dry-run] [-batch 500]
+//	        [-collections users,employees] [-verify] [-reset]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/stores"
+)
+
+func main() {
+	envFile := flag.String("env", ".env", "env file with the database settings")
+	cfgFile := flag.String("config", "", "optional yaml or toml settings file")
+	dryRun := flag.Bool("dry-run", false,
+		"read and convert the documents without writing to postgres")
+	batch := flag.Int("batch", 500, "documents written per transaction")
+	names := flag.String("collections", "",
+		"comma separated collections to copy, all when blank")
+	verifyOnly := flag.Bool("verify", false,
+		"only compare the row counts and checksums")
+	reset := flag.Bool("reset", false,
+		"empty the selected collections' postgres tables and copy them again "+
+			"from the beginning")
+	flag.Parse()
+
+	cfg, err := config.Load(config.LoadOptions{
+		EnvFile:    *envFile,
+		ConfigFile: *cfgFile,
+		Required:   []string{"MONGO_URI", "POSTGRES_URL"},
+		NoAuth:     true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := config.Open(ctx, cfg.DatabaseOptions())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close(context.Background())
+
+	selected, err := selectCollections(*names)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	m := &migrator{
+		client: client,
+		batch:  *batch,
+		dryRun: *dryRun,
+	}
+
+	if !*dryRun {
+		if err := stores.MigratePostgres(ctx, client.Postgres); err != nil {
+			log.Fatal(err)
+		}
+		if err := m.prepare(*reset, selected); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if !*verifyOnly {
+		for _, coll := range selected {
+			copied, err := m.copy(ctx, coll)
+			if err != nil {
+				log.Fatalf("%s: %s", coll.name, err.Error())
+			}
+			if *dryRun {
+				log.Printf("%s: %d documents converted (dry run)", coll.name, copied)
+			} else {
+				log.Printf("%s: %d documents copied", coll.name, copied)
+			}
+		}
+	}
+
+	if *dryRun {
+		return
+	}
+	report, err := m.verify(ctx, selected)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(report.String())
+	if !report.Matched() {
+		os.Exit(1)
+	}
+}
+
+// selectCollections returns the named collections in migration order, or all
+// of them when no names are given.
+func selectCollections(names string) ([]collection, error) {
+	if strings.TrimSpace(names) == "" {
+		return collections, nil
+	}
+	var answer []collection
+	for _, name := range strings.Split(names, ",") {
+		found := false
+		for _, coll := range collections {
+			if strings.EqualFold(coll.mongo, strings.TrimSpace(name)) ||
+				strings.EqualFold(coll.name, strings.TrimSpace(name)) {
+				answer = append(answer, coll)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown collection: %s", name)
+		}
+	}
+	return answer, nil
+}
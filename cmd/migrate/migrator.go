@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// checkpoint is the last mongo id written for a collection.
+type checkpoint struct {
+	Collection string `gorm:"primary_key"`
+	LastID     string `gorm:"type:char(24)"`
+	Copied     int64
+	UpdatedAt  time.Time
+}
+
+func (checkpoint) TableName() string {
+	return "migration_checkpoints"
+}
+
+// idMapping links a mongo id to the postgres serial id which replaced it.
+type idMapping struct {
+	Collection string `gorm:"primary_key"`
+	MongoID    string `gorm:"primary_key;type:char(24)"`
+	PgID       uint
+}
+
+func (idMapping) TableName() string {
+	return "migration_id_map"
+}
+
+type migrator struct {
+	client *config.Client
+	batch  int
+	dryRun bool
+}
+
+// prepare creates the checkpoint and id map tables.  When the run is to
+// start over, each collection's tables are emptied, along with its checkpoint
+// and id map rows, so the copy doesn't meet the rows of the earlier run.
+func (m *migrator) prepare(reset bool, colls []collection) error {
+	db := m.client.Postgres
+	if err := db.AutoMigrate(&checkpoint{}, &idMapping{}).Error; err != nil {
+		return err
+	}
+	if !reset {
+		return nil
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, coll := range colls {
+		for _, table := range coll.reset {
+			if err := tx.Exec(fmt.Sprintf(`TRUNCATE TABLE %q CASCADE`,
+				table)).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Where("collection = ?", coll.name).
+			Delete(&idMapping{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Where("collection = ?", coll.name).
+			Delete(&checkpoint{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// copy streams the collection from its checkpoint, returning the number of
+// documents copied or, in a dry run, converted.
+func (m *migrator) copy(ctx context.Context, coll collection) (int64, error) {
+	cp := checkpoint{Collection: coll.name}
+	if !m.dryRun {
+		err := m.client.Postgres.Where("collection = ?", coll.name).First(&cp).Error
+		if err != nil && !gorm.IsRecordNotFoundError(err) {
+			return 0, err
+		}
+	}
+
+	filter := bson.M{}
+	if cp.LastID != "" {
+		lastID, err := primitive.ObjectIDFromHex(cp.LastID)
+		if err != nil {
+			return 0, err
+		}
+		filter["_id"] = bson.M{"$gt": lastID}
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetBatchSize(int32(m.batch))
+	cursor, err := config.GetCollection(m.client.Mongo, coll.database,
+		coll.mongo).Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	count := int64(0)
+	var lastID primitive.ObjectID
+	var pending []writer
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		cp.LastID = lastID.Hex()
+		cp.Copied += int64(len(pending))
+		cp.UpdatedAt = time.Now().UTC()
+		tx := m.client.Postgres.BeginTx(ctx, nil)
+		if tx.Error != nil {
+			return tx.Error
+		}
+		for _, write := range pending {
+			if err := write(ctx, tx); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Save(&cp).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		pending = pending[:0]
+		return tx.Commit().Error
+	}
+
+	for cursor.Next(ctx) {
+		id, write, err := coll.convert(cursor.Current)
+		if err != nil {
+			return count, fmt.Errorf("document %s: %w", lookupID(cursor.Current),
+				err)
+		}
+		count++
+		if m.dryRun {
+			continue
+		}
+		lastID = id
+		pending = append(pending, func(ctx context.Context, tx *gorm.DB) error {
+			if err := write(ctx, tx); err != nil {
+				return fmt.Errorf("document %s: %w", id.Hex(), err)
+			}
+			return nil
+		})
+		if len(pending) >= m.batch {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	return count, flush()
+}
+
+// lookupID returns the document's id for the error messages.
+func lookupID(raw bson.Raw) string {
+	value, err := raw.LookupErr("_id")
+	if err != nil {
+		return "(no id)"
+	}
+	if oid, ok := value.ObjectIDOK(); ok {
+		return oid.Hex()
+	}
+	return value.String()
+}
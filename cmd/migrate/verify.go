@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// verification is the comparison of a collection with its postgres table.
+// The checksum is the sha256 of the ids in order, so it shows that the same
+// documents made it across, not only the same number of them.
+type verification struct {
+	Collection    string
+	MongoCount    int64
+	PostgresCount int64
+	MongoChecksum string
+	PgChecksum    string
+}
+
+func (v verification) Matched() bool {
+	return v.MongoCount == v.PostgresCount && v.MongoChecksum == v.PgChecksum
+}
+
+type verificationReport []verification
+
+func (r verificationReport) Matched() bool {
+	for _, v := range r {
+		if !v.Matched() {
+			return false
+		}
+	}
+	return true
+}
+
+func (r verificationReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-26s %10s %10s  %-16s %-16s %s\n", "collection", "mongo",
+		"postgres", "mongo sum", "postgres sum", "result")
+	for _, v := range r {
+		result := "ok"
+		if !v.Matched() {
+			result = "MISMATCH"
+		}
+		fmt.Fprintf(&sb, "%-26s %10d %10d  %-16s %-16s %s\n", v.Collection,
+			v.MongoCount, v.PostgresCount, v.MongoChecksum[:16],
+			v.PgChecksum[:16], result)
+	}
+	return sb.String()
+}
+
+func (m *migrator) verify(ctx context.Context,
+	colls []collection) (verificationReport, error) {
+	var report verificationReport
+	for _, coll := range colls {
+		v := verification{Collection: coll.name}
+		var err error
+		v.MongoCount, v.MongoChecksum, err = m.mongoChecksum(ctx, coll)
+		if err != nil {
+			return report, err
+		}
+		v.PostgresCount, v.PgChecksum, err = m.pgChecksum(coll)
+		if err != nil {
+			return report, err
+		}
+		report = append(report, v)
+		if coll.name == "scheduler.employees" {
+			v, err := m.verifyTeamJoin(ctx)
+			if err != nil {
+				return report, err
+			}
+			report = append(report, v)
+		}
+	}
+	return report, nil
+}
+
+// verifyTeamJoin compares the employees whose team is found, in mongo by the
+// teams' ids and in postgres by the teams' object_id, so an employee left
+// pointing at no team shows as a mismatch.
+func (m *migrator) verifyTeamJoin(ctx context.Context) (verification, error) {
+	v := verification{Collection: "scheduler.employees->teams"}
+
+	teams := make(map[primitive.ObjectID]bool)
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := config.GetCollection(m.client.Mongo, "scheduler",
+		"teams").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return v, err
+	}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			cursor.Close(ctx)
+			return v, err
+		}
+		teams[doc.ID] = true
+	}
+	cursor.Close(ctx)
+	if err := cursor.Err(); err != nil {
+		return v, err
+	}
+
+	opts = options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"_id": 1, "team": 1})
+	cursor, err = config.GetCollection(m.client.Mongo, "scheduler",
+		"employees").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return v, err
+	}
+	defer cursor.Close(ctx)
+	sum := sha256.New()
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Team primitive.ObjectID `bson:"team"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return v, err
+		}
+		if teams[doc.Team] {
+			addID(sum, doc.ID.Hex())
+			v.MongoCount++
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return v, err
+	}
+	v.MongoChecksum = hex.EncodeToString(sum.Sum(nil))
+
+	rows, err := m.client.Postgres.Table("employees").
+		Select("employees.id").
+		Joins("JOIN teams ON teams.object_id = employees.team_id").
+		Order("employees.id").Rows()
+	if err != nil {
+		return v, err
+	}
+	defer rows.Close()
+	sum = sha256.New()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return v, err
+		}
+		addID(sum, id)
+		v.PostgresCount++
+	}
+	if err := rows.Err(); err != nil {
+		return v, err
+	}
+	v.PgChecksum = hex.EncodeToString(sum.Sum(nil))
+	return v, nil
+}
+
+func (m *migrator) mongoChecksum(ctx context.Context,
+	coll collection) (int64, string, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"_id": 1})
+	cursor, err := config.GetCollection(m.client.Mongo, coll.database,
+		coll.mongo).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return 0, "", err
+	}
+	defer cursor.Close(ctx)
+
+	sum := sha256.New()
+	count := int64(0)
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return count, "", err
+		}
+		addID(sum, doc.ID.Hex())
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, "", err
+	}
+	return count, hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// pgChecksum reads the mongo ids of the copied rows, either from the table's
+// id column or, for tables with serial ids, from the id map.
+func (m *migrator) pgChecksum(coll collection) (int64, string, error) {
+	var query *gorm.DB
+	if coll.table != "" {
+		query = m.client.Postgres.Table(coll.table).Select("id").Order("id")
+	} else {
+		query = m.client.Postgres.Table("migration_id_map").
+			Select("mongo_id").Where("collection = ?", coll.name).Order("mongo_id")
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+
+	sum := sha256.New()
+	count := int64(0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return count, "", err
+		}
+		addID(sum, id)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, "", err
+	}
+	return count, hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func addID(sum hash.Hash, id string) {
+	sum.Write([]byte(id))
+	sum.Write([]byte{'\n'})
+}
@@ -0,0 +1,139 @@
+// The roles command converts the authentication users' legacy workgroup
+// strings, such as "scheduler-admin", to their typed roles.  Each converted
+// user is listed with the roles found, along with any workgroups which aren't
+// in the "app-role" form and so are dropped.  Users which already have roles
+// are left alone, and the workgroups are kept for the clients still reading
+// them.
+//
+// Users stored in postgres are converted by the schema migration adding the
+// roles column, so only the mongo collection needs this.
+//
+//	roles -env .env [-config settings.yaml] [-dry-run] [-batch 500]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/users"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	envFile := flag.String("env", ".env", "env file with the database settings")
+	cfgFile := flag.String("config", "", "optional yaml or toml settings file")
+	dryRun := flag.Bool("dry-run", false,
+		"report the users which would change without saving them")
+	batch := flag.Int("batch", 500, "users saved per bulk write")
+	flag.Parse()
+
+	cfg, err := config.Load(config.LoadOptions{
+		EnvFile:    *envFile,
+		ConfigFile: *cfgFile,
+		Required:   []string{"MONGO_URI"},
+		NoAuth:     true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := cfg.DatabaseOptions()
+	opts.PostgresURL = ""
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := config.Open(ctx, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close(context.Background())
+
+	coll := config.GetCollection(client.Mongo, "authenticate", "users")
+	converted, err := convertUsers(ctx, coll, *batch, *dryRun)
+	if *dryRun {
+		fmt.Printf("%d users would be converted (dry run)\n", converted)
+	} else {
+		fmt.Printf("%d users converted\n", converted)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// convertUsers sets the roles of each user with workgroups and no roles,
+// saving them in bulk writes of the batch size, and returns the number
+// converted.  Only the roles field is written, and only while the user still
+// has none.
+func convertUsers(ctx context.Context, coll *mongo.Collection, batch int,
+	dryRun bool) (int, error) {
+	unconverted := bson.M{
+		"workgroups.0": bson.M{"$exists": true},
+		"roles.0":      bson.M{"$exists": false},
+	}
+	cursor, err := coll.Find(ctx, unconverted,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	var writes []mongo.WriteModel
+	flush := func() error {
+		if len(writes) == 0 {
+			return nil
+		}
+		_, err := coll.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		writes = writes[:0]
+		return err
+	}
+
+	for cursor.Next(ctx) {
+		var user users.User
+		if err := cursor.Decode(&user); err != nil {
+			return count, err
+		}
+		roles, malformed := users.ParseRoles(user.Workgroups)
+		fmt.Println(describeChange(user, roles, malformed))
+		count++
+		if dryRun || len(roles) == 0 {
+			continue
+		}
+		filter := bson.M{"_id": user.ID, "roles.0": bson.M{"$exists": false}}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$set": bson.M{"roles": roles}}))
+		if len(writes) >= batch {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	return count, flush()
+}
+
+// describeChange gives the line reported for a user about to be converted.
+func describeChange(user users.User, roles []users.Role,
+	malformed []string) string {
+	var names []string
+	for _, role := range roles {
+		names = append(names, role.String())
+	}
+	line := fmt.Sprintf("%s %-30s roles: %s", user.ID.Hex(),
+		user.GetLastFirst(), strings.Join(names, ", "))
+	if len(malformed) > 0 {
+		line += fmt.Sprintf(" (dropped: %s)", strings.Join(malformed, ", "))
+	}
+	return line
+}
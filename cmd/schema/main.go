@@ -0,0 +1,121 @@
+// The schema command applies, rolls back and reports the versioned postgres
+// schema migrations.
+//
+//	schema [-env .env] [-config settings.yaml] up [version]
+//	schema [-env .env] [-config settings.yaml] down [steps]
+//	schema [-env .env] [-config settings.yaml] status
+//	schema [-env .env] [-config settings.yaml] baseline version
+//
+// Up applies every pending migration, or those up to the version given.  Down
+// rolls back the latest migration, or the number of steps given.  Baseline
+// records the migrations up to the version as applied without running them,
+// for a database whose tables already exist.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/stores"
+)
+
+func main() {
+	envFile := flag.String("env", ".env", "env file with the database settings")
+	cfgFile := flag.String("config", "", "optional yaml or toml settings file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"usage: schema [flags] up [version] | down [steps] | status | "+
+				"baseline version\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	number := func(def int) int {
+		if flag.NArg() < 2 {
+			return def
+		}
+		value, err := strconv.Atoi(flag.Arg(1))
+		if err != nil || value < 0 {
+			log.Fatalf("%s: not a valid number", flag.Arg(1))
+		}
+		return value
+	}
+
+	cfg, err := config.Load(config.LoadOptions{
+		EnvFile:    *envFile,
+		ConfigFile: *cfgFile,
+		Required:   []string{"POSTGRES_URL"},
+		NoAuth:     true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := cfg.DatabaseOptions()
+	opts.MongoURI = ""
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := config.Open(ctx, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close(context.Background())
+	db := client.Postgres
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := stores.MigrateUp(ctx, db, number(0))
+		for _, mig := range applied {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		rolledBack, err := stores.MigrateDown(ctx, db, number(1))
+		for _, mig := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("no migrations to roll back")
+		}
+	case "status":
+		status, err := stores.GetMigrationStatus(ctx, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, mig := range status {
+			applied := "pending"
+			if mig.Applied {
+				applied = mig.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-24s %s\n", mig.Version, mig.Name, applied)
+		}
+	case "baseline":
+		if flag.NArg() < 2 {
+			log.Fatal("baseline needs the version the database is already at")
+		}
+		if err := stores.MarkMigrated(ctx, db, number(0)); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -1,18 +1,443 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 )
 
-func Config(key string) string {
-	err := godotenv.Load(".env")
+// Config holds the application settings.  Each field's env tag gives the key
+// used for it in the env file, the environment and the yaml or toml file.
+type Config struct {
+	MongoURI    string `env:"MONGO_URI"`
+	PostgresURL string `env:"POSTGRES_URL"`
+
+	DBConnectTimeout  time.Duration `env:"DB_CONNECT_TIMEOUT"`
+	DBMaxRetries      int           `env:"DB_MAX_RETRIES"`
+	DBRetryBackoff    time.Duration `env:"DB_RETRY_BACKOFF"`
+	DBMaxPoolSize     uint64        `env:"DB_MAX_POOL_SIZE"`
+	DBMinPoolSize     uint64        `env:"DB_MIN_POOL_SIZE"`
+	DBMaxConnIdleTime time.Duration `env:"DB_MAX_CONN_IDLE_TIME"`
+	DBHealthInterval  time.Duration `env:"DB_HEALTH_INTERVAL"`
+	ServiceTimeout    time.Duration `env:"SERVICE_TIMEOUT"`
+
+	JWTSecret         string        `env:"JWT_SECRET"`
+	JWTKeyFile        string        `env:"JWT_KEY_FILE"`
+	JWTKeyID          string        `env:"JWT_KEY_ID"`
+	JWTSecretRetireAt time.Time     `env:"JWT_SECRET_RETIRE_AT"`
+	JWTAccessTTL      time.Duration `env:"JWT_ACCESS_TTL"`
+	JWTRefreshTTL     time.Duration `env:"JWT_REFRESH_TTL"`
+	APIKeyTTL         time.Duration `env:"API_KEY_TTL"`
+	LogDir            string        `env:"LOG_DIR"`
+	LogLevel          int           `env:"LOGLEVEL"`
+
+	ImpersonationTTL time.Duration `env:"IMPERSONATION_TTL"`
+
+	SmtpServer   string `env:"SMTP_SERVER"`
+	SmtpPort     string `env:"SMTP_PORT"`
+	SmtpPassword string `env:"SMTP_PASS"`
+	SmtpFrom     string `env:"SMTP_FROM"`
+
+	LockoutThreshold   int           `env:"LOCKOUT_THRESHOLD"`
+	LockoutDuration    time.Duration `env:"LOCKOUT_DURATION"`
+	LockoutResetWindow time.Duration `env:"LOCKOUT_RESET_WINDOW"`
+
+	MFAIssuer       string        `env:"MFA_ISSUER"`
+	MFAChallengeTTL time.Duration `env:"MFA_CHALLENGE_TTL"`
+	MFASkew         int           `env:"MFA_SKEW"`
+	MFAAttemptLimit int           `env:"MFA_ATTEMPT_LIMIT"`
+
+	PasswordMinLength     int           `env:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper  bool          `env:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower  bool          `env:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit  bool          `env:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol bool          `env:"PASSWORD_REQUIRE_SYMBOL"`
+	PasswordBanned        string        `env:"PASSWORD_BANNED"`
+	PasswordHistory       int           `env:"PASSWORD_HISTORY"`
+	PasswordExpiry        time.Duration `env:"PASSWORD_EXPIRY"`
+
+	PasswordHasher    string `env:"PASSWORD_HASHER"`
+	BcryptCost        int    `env:"BCRYPT_COST"`
+	Argon2Memory      int    `env:"ARGON2_MEMORY"`
+	Argon2Iterations  int    `env:"ARGON2_ITERATIONS"`
+	Argon2Parallelism int    `env:"ARGON2_PARALLELISM"`
+
+	PasswordResetURL  string        `env:"PASSWORD_RESET_URL"`
+	PasswordResetTTL  time.Duration `env:"PASSWORD_RESET_TTL"`
+	ResetRequestLimit int           `env:"RESET_REQUEST_LIMIT"`
+	ResetAttemptLimit int           `env:"RESET_ATTEMPT_LIMIT"`
+	ResetRateWindow   time.Duration `env:"RESET_RATE_WINDOW"`
+
+	EmailVerifyURL string        `env:"EMAIL_VERIFY_URL"`
+	VerifyTokenTTL time.Duration `env:"VERIFY_TOKEN_TTL"`
+	InviteURL      string        `env:"INVITE_URL"`
+	InviteTTL      time.Duration `env:"INVITE_TTL"`
+
+	EmployeeLegacyData bool `env:"EMPLOYEE_LEGACY_DATA"`
+}
+
+// LoadOptions tell Load where to find the optional files and which keys the
+// application requires beyond the jwt signing key and a database connection.
+// The signing key is either the jwt secret or, for RS256 and EdDSA tokens, the
+// private key file with its key id.  A jwt secret kept alongside the key file
+// only verifies the tokens it already signed, until the fixed time given by
+// JWT_SECRET_RETIRE_AT.  NoAuth drops the signing key requirement, for the
+// command line tools which don't issue tokens.
+type LoadOptions struct {
+	EnvFile    string
+	ConfigFile string
+	Required   []string
+	NoAuth     bool
+}
+
+// defaultServiceTimeout is the deadline given to a service call whose context
+// doesn't already have one.
+const defaultServiceTimeout = 15 * time.Second
+
+// the default lifetimes of the short-lived access tokens and of the refresh
+// tokens used to renew them.
+const (
+	defaultAccessTTL  = 15 * time.Minute
+	defaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// the default lifetime of an api key issued without one.
+const defaultAPIKeyTTL = 90 * 24 * time.Hour
+
+// the default lifetime of an administrator's token acting as another user,
+// which can't be renewed.
+const defaultImpersonationTTL = 30 * time.Minute
+
+// the default account lockout, after three failed logins, each within an hour
+// of the one before, for fifteen minutes.
+const (
+	defaultLockoutThreshold   = 3
+	defaultLockoutDuration    = 15 * time.Minute
+	defaultLockoutResetWindow = time.Hour
+)
+
+// the default multi-factor settings, the time allowed to answer the challenge
+// after the password, the number of time steps either side of now accepted
+// for clock skew and the failed codes allowed for a challenge.
+const (
+	defaultMFAChallengeTTL = 5 * time.Minute
+	defaultMFASkew         = 1
+	defaultMFAAttemptLimit = 5
+)
+
+// the default password policy, also refusing the common passwords and any
+// extra ones listed, comma separated, in PASSWORD_BANNED.
+const (
+	defaultPasswordMinLength = 10
+	defaultPasswordHistory   = 5
+	defaultPasswordExpiry    = 90 * 24 * time.Hour
+)
+
+// the password hashers, and the default hashing costs, bcrypt's cost and
+// argon2id's memory in KiB, passes and threads.
+const (
+	HasherBcrypt   = "bcrypt"
+	HasherArgon2id = "argon2id"
+
+	defaultBcryptCost        = 12
+	defaultArgon2Memory      = 64 * 1024
+	defaultArgon2Iterations  = 3
+	defaultArgon2Parallelism = 2
+)
+
+// the costs the hashers accept, bcrypt's range of costs and the most threads
+// argon2id runs.
+const (
+	minBcryptCost        = 4
+	maxBcryptCost        = 31
+	maxArgon2Parallelism = 255
+)
+
+// the default password reset settings, the reset token lifetime and the
+// number of reset requests and failed reset attempts allowed for an email
+// address within the rate window.
+const (
+	defaultResetTTL          = time.Hour
+	defaultResetRequestLimit = 3
+	defaultResetAttemptLimit = 5
+	defaultResetRateWindow   = time.Hour
+)
+
+// the default lifetimes of the email verification and invitation tokens.
+const (
+	defaultVerifyTokenTTL = 48 * time.Hour
+	defaultInviteTTL      = 7 * 24 * time.Hour
+)
+
+// Defaults returns the settings used before any source is read.
+func Defaults() *Config {
+	return &Config{
+		DBConnectTimeout: defaultConnectTimeout,
+		DBMaxRetries:     defaultMaxRetries,
+		DBRetryBackoff:   defaultRetryBackoff,
+		DBMaxPoolSize:    defaultMaxPoolSize,
+		ServiceTimeout:   defaultServiceTimeout,
+		JWTAccessTTL:     defaultAccessTTL,
+		JWTRefreshTTL:    defaultRefreshTTL,
+		APIKeyTTL:        defaultAPIKeyTTL,
+		LogDir:           "logs",
+		SmtpPort:         "587",
+
+		ImpersonationTTL: defaultImpersonationTTL,
+
+		LockoutThreshold:   defaultLockoutThreshold,
+		LockoutDuration:    defaultLockoutDuration,
+		LockoutResetWindow: defaultLockoutResetWindow,
+
+		MFAIssuer:       "Scheduler",
+		MFAChallengeTTL: defaultMFAChallengeTTL,
+		MFASkew:         defaultMFASkew,
+		MFAAttemptLimit: defaultMFAAttemptLimit,
+
+		PasswordMinLength:    defaultPasswordMinLength,
+		PasswordRequireUpper: true,
+		PasswordRequireLower: true,
+		PasswordRequireDigit: true,
+		PasswordHistory:      defaultPasswordHistory,
+		PasswordExpiry:       defaultPasswordExpiry,
+
+		PasswordHasher:    HasherBcrypt,
+		BcryptCost:        defaultBcryptCost,
+		Argon2Memory:      defaultArgon2Memory,
+		Argon2Iterations:  defaultArgon2Iterations,
+		Argon2Parallelism: defaultArgon2Parallelism,
+
+		PasswordResetTTL:  defaultResetTTL,
+		ResetRequestLimit: defaultResetRequestLimit,
+		ResetAttemptLimit: defaultResetAttemptLimit,
+		ResetRateWindow:   defaultResetRateWindow,
+
+		VerifyTokenTTL: defaultVerifyTokenTTL,
+		InviteTTL:      defaultInviteTTL,
+
+		EmployeeLegacyData: true,
+	}
+}
+
+// Load builds the settings once at startup, starting with the defaults then
+// applying, in order, the env file, the environment variables and the yaml or
+// toml configuration file, each overriding the one before.  Missing files are
+// skipped.  The result is validated before it is returned.
+func Load(opts LoadOptions) (*Config, error) {
+	cfg := Defaults()
+
+	if opts.EnvFile != "" {
+		values, err := godotenv.Read(opts.EnvFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err := cfg.apply(values); err != nil {
+			return nil, err
+		}
+	}
+
+	values := make(map[string]string)
+	for _, key := range cfg.keys() {
+		if value, ok := os.LookupEnv(key); ok {
+			values[key] = value
+		}
+	}
+	if err := cfg.apply(values); err != nil {
+		return nil, err
+	}
+
+	if opts.ConfigFile != "" {
+		values, err := readConfigFile(opts.ConfigFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err := cfg.apply(values); err != nil {
+			return nil, err
+		}
+	}
+
+	switch strings.ToLower(cfg.PasswordHasher) {
+	case HasherBcrypt, HasherArgon2id:
+	default:
+		return nil, fmt.Errorf("PASSWORD_HASHER: unknown password hasher: %s",
+			cfg.PasswordHasher)
+	}
+	if cfg.BcryptCost < minBcryptCost || cfg.BcryptCost > maxBcryptCost {
+		return nil, fmt.Errorf("BCRYPT_COST: %d is outside %d to %d",
+			cfg.BcryptCost, minBcryptCost, maxBcryptCost)
+	}
+	if cfg.Argon2Memory <= 0 {
+		return nil, fmt.Errorf("ARGON2_MEMORY: %d is not positive",
+			cfg.Argon2Memory)
+	}
+	if cfg.Argon2Iterations <= 0 {
+		return nil, fmt.Errorf("ARGON2_ITERATIONS: %d is not positive",
+			cfg.Argon2Iterations)
+	}
+	if cfg.Argon2Parallelism <= 0 ||
+		cfg.Argon2Parallelism > maxArgon2Parallelism {
+		return nil, fmt.Errorf("ARGON2_PARALLELISM: %d is outside 1 to %d",
+			cfg.Argon2Parallelism, maxArgon2Parallelism)
+	}
+
+	required := opts.Required
+	if !opts.NoAuth && cfg.JWTKeyFile != "" {
+		required = append([]string{"JWT_KEY_ID"}, required...)
+		if cfg.JWTSecret != "" {
+			required = append([]string{"JWT_SECRET_RETIRE_AT"}, required...)
+			retireAt := cfg.JWTSecretRetireAt
+			if !retireAt.IsZero() && !retireAt.After(time.Now()) {
+				return nil, fmt.Errorf("JWT_SECRET_RETIRE_AT: the jwt secret "+
+					"retired at %s, remove JWT_SECRET",
+					retireAt.Format(time.RFC3339))
+			}
+		}
+	} else if !opts.NoAuth {
+		required = append([]string{"JWT_SECRET"}, required...)
+	}
+	if err := cfg.Validate(required...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readConfigFile reads the flat key/value yaml or toml file, chosen by the
+// file's extension.
+func readConfigFile(file string) (map[string]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		err = fmt.Errorf("unknown configuration file type: %s", file)
+	}
 	if err != nil {
-		log.Print("Error loading .env file")
-		log.Println(err.Error())
+		return nil, err
 	}
 
-	return os.Getenv(key)
+	values := make(map[string]string)
+	for key, value := range raw {
+		if when, ok := value.(time.Time); ok {
+			value = when.Format(time.RFC3339)
+		}
+		values[strings.ToUpper(key)] = fmt.Sprint(value)
+	}
+	return values, nil
+}
+
+// keys returns the env keys of the settings.
+func (c *Config) keys() []string {
+	var answer []string
+	t := reflect.TypeOf(*c)
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("env"); key != "" {
+			answer = append(answer, key)
+		}
+	}
+	return answer
+}
+
+// apply sets each setting found in the values by its env key.
+func (c *Config) apply(values map[string]string) error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("env")
+		value, ok := values[key]
+		if key == "" || !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		field := v.Field(i)
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(value)
+		case time.Duration:
+			dur, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			field.SetInt(int64(dur))
+		case time.Time:
+			when, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			field.Set(reflect.ValueOf(when))
+		case int:
+			num, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			field.SetInt(int64(num))
+		case bool:
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			field.SetBool(flag)
+		case uint64:
+			num, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			field.SetUint(num)
+		}
+	}
+	return nil
+}
+
+// Validate checks that a database connection and each of the required keys
+// have been set.
+func (c *Config) Validate(required ...string) error {
+	var missing []string
+	if c.MongoURI == "" && c.PostgresURL == "" {
+		missing = append(missing, "MONGO_URI or POSTGRES_URL")
+	}
+
+	v := reflect.ValueOf(*c)
+	t := v.Type()
+	for _, key := range required {
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Tag.Get("env") == key && v.Field(i).IsZero() {
+				missing = append(missing, key)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required configuration: %s",
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// DatabaseOptions returns the options used to open the database client.
+func (c *Config) DatabaseOptions() Options {
+	return Options{
+		MongoURI:        c.MongoURI,
+		PostgresURL:     c.PostgresURL,
+		ConnectTimeout:  c.DBConnectTimeout,
+		MaxRetries:      c.DBMaxRetries,
+		RetryBackoff:    c.DBRetryBackoff,
+		MaxPoolSize:     c.DBMaxPoolSize,
+		MinPoolSize:     c.DBMinPoolSize,
+		MaxConnIdleTime: c.DBMaxConnIdleTime,
+		HealthInterval:  c.DBHealthInterval,
+	}
 }
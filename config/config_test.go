@@ -0,0 +1,228 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes the content to the named file in a temporary directory
+// and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return file
+}
+
+func TestLoadSecretRetirement(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name     string
+		retireAt string
+		wantErr  string
+	}{
+		{"no retirement time", "", "JWT_SECRET_RETIRE_AT"},
+		{"retirement to come", future, ""},
+		{"retirement passed", past, "remove JWT_SECRET"},
+		{"malformed retirement time", "tomorrow", "JWT_SECRET_RETIRE_AT"},
+	}
+	for _, tt := range tests {
+		env := "MONGO_URI=mongodb://localhost\nJWT_SECRET=secret\n" +
+			"JWT_KEY_FILE=jwt.pem\nJWT_KEY_ID=current\n"
+		if tt.retireAt != "" {
+			env += "JWT_SECRET_RETIRE_AT=" + tt.retireAt + "\n"
+		}
+		cfg, err := Load(LoadOptions{EnvFile: writeFile(t, ".env", env)})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Load = %v", tt.name, err)
+			} else if cfg.JWTSecretRetireAt.Format(time.RFC3339) != tt.retireAt {
+				t.Errorf("%s: retire at %v, want %s", tt.name,
+					cfg.JWTSecretRetireAt, tt.retireAt)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Load = %v, want an error naming %s", tt.name, err,
+				tt.wantErr)
+		}
+	}
+}
+
+func TestLoadHashingCosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		wantErr string
+	}{
+		{"defaults", "", ""},
+		{"bcrypt cost below the minimum", "BCRYPT_COST=3", "BCRYPT_COST"},
+		{"bcrypt cost above the maximum", "BCRYPT_COST=32", "BCRYPT_COST"},
+		{"no argon2 memory", "ARGON2_MEMORY=0", "ARGON2_MEMORY"},
+		{"negative argon2 iterations", "ARGON2_ITERATIONS=-1",
+			"ARGON2_ITERATIONS"},
+		{"no argon2 threads", "ARGON2_PARALLELISM=0", "ARGON2_PARALLELISM"},
+		{"too many argon2 threads", "ARGON2_PARALLELISM=256",
+			"ARGON2_PARALLELISM"},
+		{"most argon2 threads", "ARGON2_PARALLELISM=255", ""},
+	}
+	for _, tt := range tests {
+		env := "MONGO_URI=mongodb://localhost\n" + tt.setting + "\n"
+		_, err := Load(LoadOptions{EnvFile: writeFile(t, ".env", env),
+			NoAuth: true})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Load = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Load = %v, want an error naming %s", tt.name, err,
+				tt.wantErr)
+		}
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	envFile := writeFile(t, ".env", "MONGO_URI=mongodb://localhost\n"+
+		"JWT_SECRET=secret\nLOG_DIR=env-file\nLOGLEVEL=1\n"+
+		"SMTP_SERVER=env-file\n")
+	yamlFile := writeFile(t, "settings.yaml", "log_dir: yaml\n")
+	tomlFile := writeFile(t, "settings.toml", "LOG_DIR = \"toml\"\n")
+	tests := []struct {
+		name       string
+		env        map[string]string
+		configFile string
+		wantLogDir string
+		wantLevel  int
+		wantSMTP   string
+	}{
+		{"defaults under the env file", nil, "", "env-file", 1, "env-file"},
+		{"environment over the env file",
+			map[string]string{"LOG_DIR": "environment", "LOGLEVEL": "2"}, "",
+			"environment", 2, "env-file"},
+		{"yaml over the environment",
+			map[string]string{"LOG_DIR": "environment", "LOGLEVEL": "2"},
+			yamlFile, "yaml", 2, "env-file"},
+		{"toml over the environment", map[string]string{"LOG_DIR": "environment"},
+			tomlFile, "toml", 1, "env-file"},
+		{"missing config file skipped", nil,
+			filepath.Join(t.TempDir(), "missing.yaml"), "env-file", 1,
+			"env-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load(LoadOptions{EnvFile: envFile,
+				ConfigFile: tt.configFile})
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.LogDir != tt.wantLogDir || cfg.LogLevel != tt.wantLevel ||
+				cfg.SmtpServer != tt.wantSMTP {
+				t.Errorf("LOG_DIR %q, LOGLEVEL %d, SMTP_SERVER %q; want %q, "+
+					"%d, %q", cfg.LogDir, cfg.LogLevel, cfg.SmtpServer,
+					tt.wantLogDir, tt.wantLevel, tt.wantSMTP)
+			}
+			if cfg.JWTAccessTTL != defaultAccessTTL {
+				t.Errorf("JWT_ACCESS_TTL %v, want the default %v",
+					cfg.JWTAccessTTL, defaultAccessTTL)
+			}
+		})
+	}
+}
+
+func TestLoadSigningKeyRequired(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name    string
+		env     string
+		noAuth  bool
+		wantErr string
+	}{
+		{"secret", "JWT_SECRET=secret", false, ""},
+		{"no signing key", "", false, "JWT_SECRET"},
+		{"no signing key without auth", "", true, ""},
+		{"key file and id", "JWT_KEY_FILE=jwt.pem\nJWT_KEY_ID=current", false,
+			""},
+		{"key file without an id", "JWT_KEY_FILE=jwt.pem", false, "JWT_KEY_ID"},
+		{"key file with the secret to retire",
+			"JWT_KEY_FILE=jwt.pem\nJWT_KEY_ID=current\nJWT_SECRET=secret\n" +
+				"JWT_SECRET_RETIRE_AT=" + future, false, ""},
+	}
+	for _, tt := range tests {
+		env := "MONGO_URI=mongodb://localhost\n" + tt.env + "\n"
+		_, err := Load(LoadOptions{EnvFile: writeFile(t, ".env", env),
+			NoAuth: tt.noAuth})
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Load = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Load = %v, want an error naming %s", tt.name, err,
+				tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConversionErrors(t *testing.T) {
+	tests := []struct {
+		setting string
+		key     string
+	}{
+		{"DB_CONNECT_TIMEOUT=ten seconds", "DB_CONNECT_TIMEOUT"},
+		{"DB_MAX_RETRIES=three", "DB_MAX_RETRIES"},
+		{"DB_MAX_POOL_SIZE=-1", "DB_MAX_POOL_SIZE"},
+		{"PASSWORD_REQUIRE_UPPER=sometimes", "PASSWORD_REQUIRE_UPPER"},
+		{"JWT_SECRET_RETIRE_AT=2026-13-01", "JWT_SECRET_RETIRE_AT"},
+		{"PASSWORD_HASHER=md5", "PASSWORD_HASHER"},
+	}
+	for _, tt := range tests {
+		env := "MONGO_URI=mongodb://localhost\n" + tt.setting + "\n"
+		_, err := Load(LoadOptions{EnvFile: writeFile(t, ".env", env),
+			NoAuth: true})
+		if err == nil || !strings.HasPrefix(err.Error(), tt.key+":") {
+			t.Errorf("%s: Load = %v, want an error naming %s", tt.setting, err,
+				tt.key)
+		}
+	}
+}
+
+func TestValidateMissing(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      Config
+		required []string
+		wantErr  string
+	}{
+		{"no database", Config{}, nil, "MONGO_URI or POSTGRES_URL"},
+		{"postgres only", Config{PostgresURL: "postgres://localhost"}, nil, ""},
+		{"required key missing", Config{MongoURI: "mongodb://localhost"},
+			[]string{"SMTP_SERVER"}, "SMTP_SERVER"},
+		{"required key set", Config{MongoURI: "mongodb://localhost",
+			SmtpServer: "smtp"}, []string{"SMTP_SERVER"}, ""},
+	}
+	for _, tt := range tests {
+		err := tt.cfg.Validate(tt.required...)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: Validate = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: Validate = %v, want an error naming %s", tt.name, err,
+				tt.wantErr)
+		}
+	}
+}
@@ -2,48 +2,267 @@ package config
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"log"
+	"sync"
 	"time"
 
-	"github.com/erneap/go-models/converters"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func ConnectDB() *mongo.Client {
-	client, err := mongo.NewClient(options.Client().ApplyURI(Config("MONGO_URI")))
-	if err != nil {
-		log.Fatal(err)
+// Options are the settings used to open the database connections.  Either or
+// both of the mongo uri and postgres url may be given; the zero values of the
+// other settings are replaced by the defaults below.
+type Options struct {
+	MongoURI    string
+	PostgresURL string
+
+	// ConnectTimeout limits each connection attempt, which is retried up to
+	// MaxRetries times, waiting RetryBackoff before the first retry and
+	// doubling the wait for each one after, up to MaxBackoff.
+	ConnectTimeout time.Duration
+	MaxRetries     int
+	RetryBackoff   time.Duration
+	MaxBackoff     time.Duration
+
+	// connection pool settings, MaxPoolSize and MinPoolSize are the number of
+	// open connections for either database.
+	MaxPoolSize     uint64
+	MinPoolSize     uint64
+	MaxConnIdleTime time.Duration
+
+	// HealthInterval is the time between the background pings of the
+	// databases, zero turns the pings off.
+	HealthInterval time.Duration
+}
+
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultMaxRetries     = 5
+	defaultRetryBackoff   = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+	defaultMaxPoolSize    = 100
+)
+
+func (o *Options) setDefaults() {
+	if o.ConnectTimeout <= 0 {
+		o.ConnectTimeout = defaultConnectTimeout
+	}
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = defaultMaxRetries
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = defaultRetryBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultMaxBackoff
+	}
+	if o.MaxPoolSize == 0 {
+		o.MaxPoolSize = defaultMaxPoolSize
+	}
+}
+
+// Client holds the open database connections, which are handed to the
+// services and closed by the application when it shuts down.
+type Client struct {
+	Mongo    *mongo.Client
+	Postgres *gorm.DB
+
+	options   Options
+	mutex     sync.RWMutex
+	healthErr error
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// Open connects to the databases given in the options, retrying with backoff
+// until the connection answers a ping, the retries run out or the context is
+// done.
+func Open(ctx context.Context, opts Options) (*Client, error) {
+	if opts.MongoURI == "" && opts.PostgresURL == "" {
+		return nil, errors.New("no database connection given")
+	}
+	opts.setDefaults()
+	c := &Client{
+		options: opts,
+	}
+
+	if opts.MongoURI != "" {
+		if err := c.openMongo(ctx); err != nil {
+			return nil, err
+		}
+		log.Println("Connected to MongoDB")
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	err = client.Connect(ctx)
+	if opts.PostgresURL != "" {
+		if err := c.openPostgres(ctx); err != nil {
+			c.Close(ctx)
+			return nil, err
+		}
+		log.Println("Connected to PostgreSQL")
+	}
+
+	if opts.HealthInterval > 0 {
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.healthCheck()
+	}
+	return c, nil
+}
+
+func (c *Client) openMongo(ctx context.Context) error {
+	mOpts := options.Client().ApplyURI(c.options.MongoURI).
+		SetConnectTimeout(c.options.ConnectTimeout).
+		SetServerSelectionTimeout(c.options.ConnectTimeout).
+		SetMaxPoolSize(c.options.MaxPoolSize).
+		SetMinPoolSize(c.options.MinPoolSize)
+	if c.options.MaxConnIdleTime > 0 {
+		mOpts.SetMaxConnIdleTime(c.options.MaxConnIdleTime)
+	}
+	client, err := mongo.Connect(ctx, mOpts)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	// ping the database
-	err = client.Ping(ctx, nil)
+	err = c.retry(ctx, func(ctx context.Context) error {
+		return client.Ping(ctx, nil)
+	})
 	if err != nil {
-		log.Fatal(err)
+		client.Disconnect(context.Background())
+		return err
 	}
+	c.Mongo = client
+	return nil
+}
 
-	log.Println("Connected to MongoDB")
-	return client
+// openPostgres opens the postgres connection pool and pings it within the
+// attempt's deadline before handing it to gorm, since gorm's own ping when
+// opening a url takes no context and would wait out the driver's timeout.
+func (c *Client) openPostgres(ctx context.Context) error {
+	return c.retry(ctx, func(ctx context.Context) error {
+		sqlDB, err := sql.Open("postgres", c.options.PostgresURL)
+		if err != nil {
+			return err
+		}
+		sqlDB.SetMaxOpenConns(int(c.options.MaxPoolSize))
+		sqlDB.SetMaxIdleConns(int(c.options.MinPoolSize))
+		if c.options.MaxConnIdleTime > 0 {
+			sqlDB.SetConnMaxIdleTime(c.options.MaxConnIdleTime)
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			sqlDB.Close()
+			return err
+		}
+		db, err := gorm.Open("postgres", sqlDB)
+		if err != nil {
+			sqlDB.Close()
+			return err
+		}
+		c.Postgres = db
+		return nil
+	})
 }
 
-func SetLogLevel() int {
-	answer := 0
-	level := Config("LOGLEVEL")
-	if level != "" {
-		answer = converters.ParseInt(level)
+// retry runs the connection attempt until it succeeds, giving each attempt
+// the connect timeout and backing off between them.
+func (c *Client) retry(ctx context.Context, attempt func(ctx context.Context) error) error {
+	backoff := c.options.RetryBackoff
+	var err error
+	for i := 0; i <= c.options.MaxRetries; i++ {
+		if i > 0 {
+			log.Printf("Database connection failed, retrying in %s: %s", backoff,
+				err.Error())
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > c.options.MaxBackoff {
+				backoff = c.options.MaxBackoff
+			}
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, c.options.ConnectTimeout)
+		err = attempt(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
 	}
-	return answer
+	return err
 }
 
-var DB *mongo.Client = ConnectDB()
+// Ping checks each of the open connections, returning the first failure.
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.options.ConnectTimeout)
+	defer cancel()
+	if c.Mongo != nil {
+		if err := c.Mongo.Ping(ctx, nil); err != nil {
+			return err
+		}
+	}
+	if c.Postgres != nil {
+		if err := c.Postgres.DB().PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-var LogLevel int = SetLogLevel()
+// Health returns the result of the latest background ping, which is nil when
+// the pings are turned off.
+func (c *Client) Health() error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.healthErr
+}
+
+func (c *Client) healthCheck() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.options.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			err := c.Ping(context.Background())
+			if err != nil {
+				log.Println("Database health check failed: " + err.Error())
+			}
+			c.mutex.Lock()
+			c.healthErr = err
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// Close stops the health checks and closes the database connections.
+func (c *Client) Close(ctx context.Context) error {
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+		c.stop = nil
+	}
+	var answer error
+	if c.Mongo != nil {
+		if err := c.Mongo.Disconnect(ctx); err != nil {
+			answer = err
+		}
+		c.Mongo = nil
+	}
+	if c.Postgres != nil {
+		if err := c.Postgres.Close(); err != nil && answer == nil {
+			answer = err
+		}
+		c.Postgres = nil
+	}
+	return answer
+}
 
 // get the requested database collection
 func GetCollection(client *mongo.Client, dbName, collectionName string) *mongo.Collection {
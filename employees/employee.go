@@ -8,8 +8,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/erneap/go-models/labor"
-	"github.com/erneap/go-models/users"
+	"github.com/erneap/go-pg-models/labor"
+	"github.com/erneap/go-pg-models/users"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -32,6 +32,7 @@ type Employee struct {
 	Work        []Work              `json:"work,omitempty" bson:"-"`
 	ContactInfo []Contact           `json:"contactinfo,omitempty" bson:"contactinfo,omitempty"`
 	Specialties []Specialty         `json:"specialties,omitempty" bson:"specialties,omitempty"`
+	Version     uint                `json:"version" bson:"version"`
 }
 
 type ByEmployees []Employee
@@ -49,9 +50,7 @@ func (c ByEmployees) Less(i, j int) bool {
 func (c ByEmployees) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
 func (e *Employee) RemoveLeaves(start, end time.Time) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	sort.Sort(ByLeaveDay(e.Leaves))
 	startpos := -1
 	endpos := -1
@@ -72,6 +71,23 @@ func (e *Employee) RemoveLeaves(start, end time.Time) {
 	}
 }
 
+// ConvertLegacyData has the employee methods move a legacy Data blob into the
+// top-level fields before they use them.  Once the stored employees have been
+// converted in bulk it can be switched off, and the methods skip the check.
+var ConvertLegacyData = true
+
+func (e *Employee) convertLegacyData() {
+	if ConvertLegacyData && e.Data != nil {
+		e.ConvertFromData()
+	}
+}
+
+// HasLegacyData reports whether the employee still carries the legacy Data
+// blob.
+func (e *Employee) HasLegacyData() bool {
+	return e.Data != nil
+}
+
 func (e *Employee) ConvertFromData() error {
 	if e.Data != nil {
 		e.CompanyInfo = e.Data.CompanyInfo
@@ -124,9 +140,7 @@ type EmployeeData struct {
 }
 
 func (e *Employee) IsActive(date time.Time) bool {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	answer := false
 	for _, asgmt := range e.Assignments {
 		if asgmt.UseAssignment(e.SiteID, date) {
@@ -137,9 +151,7 @@ func (e *Employee) IsActive(date time.Time) bool {
 }
 
 func (e *Employee) IsAssigned(site, workcenter string, start, end time.Time) bool {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	answer := false
 	for _, asgmt := range e.Assignments {
 		if strings.EqualFold(asgmt.Site, site) &&
@@ -152,9 +164,7 @@ func (e *Employee) IsAssigned(site, workcenter string, start, end time.Time) boo
 }
 
 func (e *Employee) AtSite(site string, start, end time.Time) bool {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	answer := false
 	for _, asgmt := range e.Assignments {
 		if strings.EqualFold(asgmt.Site, site) &&
@@ -166,9 +176,7 @@ func (e *Employee) AtSite(site string, start, end time.Time) bool {
 }
 
 func (e *Employee) GetWorkday(date time.Time, offset float64) *Workday {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	var wkday *Workday = nil
 	work := 0.0
 	stdWorkDay := 8.0
@@ -228,9 +236,7 @@ func (e *Employee) GetWorkday(date time.Time, offset float64) *Workday {
 }
 
 func (e *Employee) GetWorkdayActual(date time.Time, offset float64) *Workday {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	var wkday *Workday = nil
 	var siteid string = ""
 	for _, asgmt := range e.Assignments {
@@ -272,9 +278,7 @@ func (e *Employee) GetWorkdayActual(date time.Time, offset float64) *Workday {
 }
 
 func (e *Employee) GetWorkdayWOLeave(date time.Time, offset float64) *Workday {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	var wkday *Workday = nil
 	var siteid string = ""
 	for _, asgmt := range e.Assignments {
@@ -294,9 +298,7 @@ func (e *Employee) GetWorkdayWOLeave(date time.Time, offset float64) *Workday {
 }
 
 func (e *Employee) GetStandardWorkday(date time.Time) float64 {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	answer := 8.0
 	count := 0
 	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0,
@@ -322,9 +324,7 @@ func (e *Employee) GetStandardWorkday(date time.Time) float64 {
 }
 
 func (e *Employee) AddAssignment(site, wkctr string, start time.Time) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	// get next assignment id as one plus the highest in employee data
 	max := 0
 	for _, asgmt := range e.Assignments {
@@ -370,9 +370,7 @@ func (e *Employee) AddAssignment(site, wkctr string, start time.Time) {
 }
 
 func (e *Employee) RemoveAssignment(id uint) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	pos := -1
 	if id > 1 {
 		sort.Sort(ByAssignment(e.Assignments))
@@ -392,9 +390,7 @@ func (e *Employee) RemoveAssignment(id uint) {
 }
 
 func (e *Employee) PurgeOldData(date time.Time) bool {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	// purge old variations based on variation end date
 	sort.Sort(ByVariation(e.Variations))
 	for i := len(e.Variations) - 1; i >= 0; i-- {
@@ -434,9 +430,7 @@ func (e *Employee) PurgeOldData(date time.Time) bool {
 }
 
 func (e *Employee) CreateLeaveBalance(year int) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	found := false
 	lastAnnual := 0.0
 	lastCarry := 0.0
@@ -472,9 +466,7 @@ func (e *Employee) CreateLeaveBalance(year int) {
 }
 
 func (e *Employee) UpdateAnnualLeave(year int, annual, carry float64) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	found := false
 	for _, al := range e.Balances {
 		if al.Year == year {
@@ -496,9 +488,7 @@ func (e *Employee) UpdateAnnualLeave(year int, annual, carry float64) {
 
 func (e *Employee) AddLeave(id int, date time.Time, code, status string,
 	hours float64, requestID *primitive.ObjectID) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	found := false
 	max := 0
 	for _, lv := range e.Leaves {
@@ -529,9 +519,7 @@ func (e *Employee) AddLeave(id int, date time.Time, code, status string,
 }
 
 func (e *Employee) UpdateLeave(id int, field, value string) (*LeaveDay, error) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	var oldLv *LeaveDay
 	oldLv = nil
 	found := false
@@ -574,9 +562,7 @@ func (e *Employee) UpdateLeave(id int, field, value string) (*LeaveDay, error) {
 }
 
 func (e *Employee) DeleteLeave(id int) *LeaveDay {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	var oldLv *LeaveDay
 	oldLv = nil
 	pos := -1
@@ -593,9 +579,7 @@ func (e *Employee) DeleteLeave(id int) *LeaveDay {
 }
 
 func (e *Employee) GetLeaveHours(start, end time.Time) float64 {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	answer := 0.0
 
 	sort.Sort(ByLeaveDay(e.Leaves))
@@ -611,9 +595,7 @@ func (e *Employee) GetLeaveHours(start, end time.Time) float64 {
 }
 
 func (e *Employee) GetPTOHours(start, end time.Time) float64 {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	answer := 0.0
 
 	sort.Sort(ByLeaveDay(e.Leaves))
@@ -631,9 +613,7 @@ func (e *Employee) GetPTOHours(start, end time.Time) float64 {
 
 func (e *Employee) NewLeaveRequest(empID, code string, start, end time.Time,
 	offset float64) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	lr := LeaveRequest{
 		ID:          primitive.NewObjectID().Hex(),
 		EmployeeID:  empID,
@@ -679,9 +659,7 @@ func (e *Employee) NewLeaveRequest(empID, code string, start, end time.Time,
 
 func (e *Employee) UpdateLeaveRequest(request, field, value string,
 	offset float64) (string, *LeaveRequest, error) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	message := ""
 	var lr *LeaveRequest
 	lr = nil
@@ -910,9 +888,7 @@ func (e *Employee) UpdateLeaveRequest(request, field, value string,
 }
 
 func (e *Employee) ChangeApprovedLeaveDates(lr LeaveRequest) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	// approved leave affects the leave listing, so we will
 	// remove old leaves for the period then add the new ones
 	startPos := -1
@@ -951,9 +927,7 @@ func (e *Employee) ChangeApprovedLeaveDates(lr LeaveRequest) {
 }
 
 func (e *Employee) DeleteLeaveRequest(request string) error {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	pos := -1
 	for i, req := range e.Requests {
 		if req.ID == request {
@@ -983,9 +957,7 @@ func (e *Employee) DeleteLeaveRequest(request string) error {
 }
 
 func (e *Employee) HasLaborCode(chargeNumber, extension string) bool {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	found := false
 	for _, asgmt := range e.Assignments {
 		for _, lc := range asgmt.LaborCodes {
@@ -999,9 +971,7 @@ func (e *Employee) HasLaborCode(chargeNumber, extension string) bool {
 }
 
 func (e *Employee) DeleteLaborCode(chargeNo, ext string) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	if e.HasLaborCode(chargeNo, ext) {
 		for a, asgmt := range e.Assignments {
 			pos := -1
@@ -1019,9 +989,7 @@ func (e *Employee) DeleteLaborCode(chargeNo, ext string) {
 }
 
 func (e *Employee) DeleteLeavesBetweenDates(start, end time.Time) {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	for i := len(e.Leaves) - 1; i >= 0; i-- {
 		if e.Leaves[i].LeaveDate.Equal(start) ||
 			e.Leaves[i].LeaveDate.Equal(end) ||
@@ -1099,9 +1067,7 @@ func (e *Employee) GetWorkedHoursForLabor(chgno, ext string,
 func (e *Employee) GetForecastHours(lCode labor.LaborCode,
 	start, end time.Time, workcodes []EmployeeCompareCode,
 	offset float64) float64 {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	answer := 0.0
 
 	// first check to see if assigned this labor code, if not
@@ -1177,9 +1143,7 @@ func (e *Employee) GetForecastHours(lCode labor.LaborCode,
 }
 
 func (e *Employee) GetLastWorkday() time.Time {
-	if e.Data != nil {
-		e.ConvertFromData()
-	}
+	e.convertLegacyData()
 	sort.Sort(ByEmployeeWork(e.Work))
 	answer := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
 	if len(e.Work) > 0 {
@@ -3,15 +3,16 @@ package sites
 import (
 	"time"
 
-	"github.com/erneap/go-models/labor"
+	"github.com/erneap/go-pg-models/labor"
 	"github.com/jinzhu/gorm"
 )
 
 type CofSCompany struct {
 	gorm.Model
+	CofSReportID   uint              `json:"-" bson:"-"`
 	Code           string            `json:"id" bson:"id"`
 	SignatureBlock string            `json:"signature" bson:"signature"`
-	LaborCodes     []labor.LaborCode `json:"laborcodes,omitempty" bson:"laborcodes,omitempty"`
+	LaborCodes     []labor.LaborCode `json:"laborcodes,omitempty" bson:"laborcodes,omitempty" gorm:"many2many:cofs_company_labor_codes"`
 	SortID         int               `json:"sortid" bson:"sortid"`
 	AddExercises   bool              `json:"exercises" bson:"exercises"`
 }
@@ -26,13 +27,14 @@ func (c ByCofSCompany) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
 type CofSReport struct {
 	gorm.Model
+	SiteID         uint          `json:"-" bson:"-"`
 	Code           int           `json:"id" bson:"id"`
 	Name           string        `json:"name" bson:"name"`
 	ShortName      string        `json:"shortname" bson:"shortname"`
 	AssociatedUnit string        `json:"unit" bson:"unit"`
 	StartDate      time.Time     `json:"startdate" bson:"startdate"`
 	EndDate        time.Time     `json:"enddate" bson:"enddate"`
-	Companies      []CofSCompany `json:"companies,omitempty" bson:"companies,omitempty"`
+	Companies      []CofSCompany `json:"companies,omitempty" bson:"companies,omitempty" gorm:"foreignkey:CofSReportID"`
 }
 
 type ByCofSReport []CofSReport
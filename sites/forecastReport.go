@@ -4,14 +4,39 @@ import (
 	"sort"
 	"time"
 
-	"github.com/erneap/go-models/labor"
+	"github.com/erneap/go-pg-models/labor"
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
 )
 
 type ForecastPeriod struct {
 	gorm.Model
-	Month   time.Time   `json:"month" bson:"month"`
-	Periods []time.Time `json:"periods,omitempty" bson:"periods,omitempty"`
+	ForecastReportID uint           `json:"-" bson:"-"`
+	Month            time.Time      `json:"month" bson:"month"`
+	Periods          []time.Time    `json:"periods,omitempty" bson:"periods,omitempty" gorm:"-:all"`
+	Dates            pq.StringArray `json:"-" bson:"-" gorm:"type:text[]"`
+}
+
+// BeforeSave and AfterFind are called by gorm to move the period dates to and
+// from the postgres text array column.
+func (fp *ForecastPeriod) BeforeSave() error {
+	fp.Dates = fp.Dates[:0]
+	for _, prd := range fp.Periods {
+		fp.Dates = append(fp.Dates, prd.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (fp *ForecastPeriod) AfterFind() error {
+	fp.Periods = fp.Periods[:0]
+	for _, dt := range fp.Dates {
+		prd, err := time.Parse(time.RFC3339, dt)
+		if err != nil {
+			return err
+		}
+		fp.Periods = append(fp.Periods, prd)
+	}
+	return nil
 }
 
 type ByForecastPeriod []ForecastPeriod
@@ -32,12 +57,13 @@ func (c ByDate) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
 type ForecastReport struct {
 	gorm.Model
+	SiteID     uint              `json:"-" bson:"-"`
 	Code       int               `json:"id" bson:"id"`
 	Name       string            `json:"name" bson:"name"`
 	StartDate  time.Time         `json:"startDate" bson:"startDate"`
 	EndDate    time.Time         `json:"endDate" bson:"endDate"`
-	Periods    []ForecastPeriod  `json:"periods,omitempty" bson:"periods,omitempty"`
-	LaborCodes []labor.LaborCode `json:"laborCodes,omitempty" bson:"laborCodes,omitempty"`
+	Periods    []ForecastPeriod  `json:"periods,omitempty" bson:"periods,omitempty" gorm:"foreignkey:ForecastReportID"`
+	LaborCodes []labor.LaborCode `json:"laborCodes,omitempty" bson:"laborCodes,omitempty" gorm:"many2many:forecast_report_labor_codes"`
 	CompanyID  string            `json:"companyid,omitempty" bson:"companyid,omitempty"`
 }
 
@@ -1,22 +1,23 @@
 package sites
 
 import (
-	"github.com/erneap/go-models/employees"
-	"github.com/erneap/go-models/labor"
+	"github.com/erneap/go-pg-models/employees"
+	"github.com/erneap/go-pg-models/labor"
 	"github.com/jinzhu/gorm"
 )
 
 type Site struct {
 	gorm.Model
+	TeamID          uint                 `json:"-" bson:"-"`
 	Code            string               `json:"id" bson:"id"`
 	Name            string               `json:"name" bson:"name"`
 	UtcOffset       float64              `json:"utcOffset" bson:"utcOffset"`
 	ShowMids        bool                 `json:"showMids" bson:"showMids"`
-	Workcenters     []Workcenter         `json:"workcenters,omitempty" bson:"workcenters,omitempty"`
-	LaborCodes      []labor.LaborCode    `json:"laborCodes,omitempty" bson:"laborCodes,omitempty"`
-	ForecastReports []ForecastReport     `json:"forecasts,omitempty" bson:"forecasts,omitempty"`
-	CofSReports     []CofSReport         `json:"cofs,omitempty" bson:"cofs,omitempty"`
-	Employees       []employees.Employee `json:"employees,omitempty" bson:"-"`
+	Workcenters     []Workcenter         `json:"workcenters,omitempty" bson:"workcenters,omitempty" gorm:"foreignkey:SiteID"`
+	LaborCodes      []labor.LaborCode    `json:"laborCodes,omitempty" bson:"laborCodes,omitempty" gorm:"many2many:site_labor_codes"`
+	ForecastReports []ForecastReport     `json:"forecasts,omitempty" bson:"forecasts,omitempty" gorm:"foreignkey:SiteID"`
+	CofSReports     []CofSReport         `json:"cofs,omitempty" bson:"cofs,omitempty" gorm:"foreignkey:SiteID"`
+	Employees       []employees.Employee `json:"employees,omitempty" bson:"-" gorm:"-"`
 }
 
 type BySites []Site
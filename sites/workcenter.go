@@ -1,19 +1,21 @@
 package sites
 
 import (
-	"github.com/erneap/go-models/employees"
+	"github.com/erneap/go-pg-models/employees"
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
 )
 
 type Shift struct {
 	gorm.Model
+	WorkcenterID    uint                 `json:"-" bson:"-"`
 	Code            string               `json:"id" bson:"id"`
 	Name            string               `json:"name" bson:"name"`
 	SortID          uint                 `json:"sort" bson:"sort"`
-	AssociatedCodes []string             `json:"associatedCodes,omitempty" bson:"associatedCodes,omitempty"`
+	AssociatedCodes pq.StringArray       `json:"associatedCodes,omitempty" bson:"associatedCodes,omitempty" gorm:"type:text[]"`
 	PayCode         uint                 `json:"payCode" bson:"payCode"`
 	Minimums        uint                 `json:"minimums" bson:"minimums"`
-	Employees       []employees.Employee `json:"-" bson:"_"`
+	Employees       []employees.Employee `json:"-" bson:"_" gorm:"-"`
 }
 
 type ByShift []Shift
@@ -26,11 +28,12 @@ func (c ByShift) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
 type Position struct {
 	gorm.Model
-	Code      string               `json:"id" bson:"id"`
-	Name      string               `json:"name" bson:"name"`
-	SortID    uint                 `json:"sort" bson:"sort"`
-	Assigned  []string             `json:"assigned" bson:"assigned"`
-	Employees []employees.Employee `json:"-" bson:"_"`
+	WorkcenterID uint                 `json:"-" bson:"-"`
+	Code         string               `json:"id" bson:"id"`
+	Name         string               `json:"name" bson:"name"`
+	SortID       uint                 `json:"sort" bson:"sort"`
+	Assigned     pq.StringArray       `json:"assigned" bson:"assigned" gorm:"type:text[]"`
+	Employees    []employees.Employee `json:"-" bson:"_" gorm:"-"`
 }
 
 type ByPosition []Position
@@ -43,11 +46,12 @@ func (c ByPosition) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
 type Workcenter struct {
 	gorm.Model
+	SiteID    uint       `json:"-" bson:"-"`
 	Code      string     `json:"id" bson:"id"`
 	Name      string     `json:"name" bson:"name"`
 	SortID    uint       `json:"sort" bson:"sort"`
-	Shifts    []Shift    `json:"shifts,omitempty" bson:"shifts,omitempty"`
-	Positions []Position `json:"positions,omitempty" bson:"positions,omitempty"`
+	Shifts    []Shift    `json:"shifts,omitempty" bson:"shifts,omitempty" gorm:"foreignkey:WorkcenterID"`
+	Positions []Position `json:"positions,omitempty" bson:"positions,omitempty" gorm:"foreignkey:WorkcenterID"`
 }
 
 type ByWorkcenter []Workcenter
@@ -5,7 +5,7 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/erneap/go-models/soap/plans"
+	"github.com/erneap/go-pg-models/soap/plans"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
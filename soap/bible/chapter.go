@@ -6,7 +6,7 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/erneap/go-models/soap/plans"
+	"github.com/erneap/go-pg-models/soap/plans"
 )
 
 type BibleChapter struct {
@@ -1,27 +1,58 @@
 package entries
 
 import (
-	"errors"
-	"fmt"
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/erneap/go-pg-models/users"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
+// Application is the name the journal's profile is kept under in the shared
+// identity.
+const Application = "soap"
+
+// User is an account as the journal sees it, the shared identity with the
+// journal's profile.  The profile is only stored in the identity's profiles,
+// so Admin and Preferences are read from it by FromIdentity and written back
+// by ToIdentity, never saved alongside it.
 type User struct {
-	ID              primitive.ObjectID `json:"id" bson:"_id"`
-	EmailAddress    string             `json:"emailAddress" bson:"emailAddress"`
-	Password        string             `json:"password" bson:"password"`
-	PasswordExpires time.Time          `json:"passwordExpires" bson:"passwordExpires"`
-	BadAttempts     uint               `json:"badAttempts" bson:"badAttempts"`
-	FirstName       string             `json:"firstName" bson:"firstName"`
-	MiddleName      string             `json:"middleName,omitempty" bson:"middleName,omitempty"`
-	LastName        string             `json:"lastName" bson:"lastName"`
-	Admin           bool               `json:"admin" bson:"admin"`
-	ResetToken      string             `json:"-" bson:"resettoken,omitempty"`
-	ResetTokenExp   *time.Time         `json:"-" bson:"resettokenexp,omitempty"`
-	Preferences     UserPreferences    `json:"preferences" bson:"preferences"`
+	users.Identity `bson:",inline"`
+	Admin          bool            `json:"admin" bson:"-"`
+	Preferences    UserPreferences `json:"preferences" bson:"-"`
+}
+
+// Profile is the journal's settings for an account, kept in the identity's
+// profiles.
+type Profile struct {
+	Admin       bool            `json:"admin" bson:"admin"`
+	Preferences UserPreferences `json:"preferences" bson:"preferences"`
+}
+
+// FromIdentity returns the journal's user for the account, with the journal's
+// profile when the account has one.
+func FromIdentity(identity users.Identity) (*User, error) {
+	user := &User{Identity: identity}
+	var profile Profile
+	if _, err := identity.GetProfile(Application, &profile); err != nil {
+		return nil, err
+	}
+	user.Admin = profile.Admin
+	user.Preferences = profile.Preferences
+	return user, nil
+}
+
+// ToIdentity returns the user's identity with the journal's profile set from
+// the user, ready to save to the account.
+func (u *User) ToIdentity() (users.Identity, error) {
+	identity := u.Identity
+	profiles := make(map[string]bson.Raw, len(identity.Profiles))
+	for app, raw := range identity.Profiles {
+		profiles[app] = raw
+	}
+	identity.Profiles = profiles
+	err := identity.SetProfile(Application, Profile{
+		Admin:       u.Admin,
+		Preferences: u.Preferences,
+	})
+	return identity, err
 }
 
 type ByUser []User
@@ -37,47 +68,3 @@ func (c ByUser) Less(i, j int) bool {
 	return c[i].LastName < c[j].LastName
 }
 func (c ByUser) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
-
-func (u *User) SetPassword(passwd string) error {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(passwd), 12)
-	if err != nil {
-		return err
-	}
-	u.Password = string(hashed)
-	u.BadAttempts = 0
-	u.PasswordExpires = time.Now().UTC().AddDate(0, 0, 90)
-	return nil
-}
-
-func (u *User) Authenticate(passwd string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(passwd))
-	if err != nil {
-		u.BadAttempts++
-		return errors.New("email address/password mismatch")
-	}
-
-	if u.PasswordExpires.Before(time.Now().UTC()) {
-		u.BadAttempts++
-		return errors.New("password expired")
-	}
-	if u.BadAttempts > 2 {
-		return errors.New("account locked")
-	}
-
-	u.BadAttempts = 0
-	return nil
-}
-
-func (u *User) GetFullName() string {
-	if u.MiddleName != "" {
-		return fmt.Sprintf("%s %s. %s", u.FirstName, u.MiddleName[:1], u.LastName)
-	}
-	return fmt.Sprintf("%s %s", u.FirstName, u.LastName)
-}
-
-func (u *User) GetLastFirst() string {
-	if u.MiddleName != "" {
-		return fmt.Sprintf("%s, %s %s.", u.LastName, u.FirstName, u.MiddleName[:1])
-	}
-	return fmt.Sprintf("%s, %s", u.LastName, u.FirstName)
-}
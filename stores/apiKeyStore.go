@@ -0,0 +1,356 @@
+package stores
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKeyStore is the storage for the api keys.  GetAPIKeys lists the keys of
+// the application, or all of them when the application is blank.  TouchAPIKey
+// records the key's last use and RevokeAPIKey revokes it, both returning
+// ErrNotFound for an unknown key.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key *users.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*users.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (*users.APIKey, error)
+	GetAPIKeys(ctx context.Context, app string) ([]users.APIKey, error)
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+// MongoAPIKeyStore keeps the api keys in the authenticate database's apikeys
+// collection.
+type MongoAPIKeyStore struct {
+	Client *mongo.Client
+}
+
+func NewMongoAPIKeyStore(client *mongo.Client) *MongoAPIKeyStore {
+	return &MongoAPIKeyStore{Client: client}
+}
+
+func (s *MongoAPIKeyStore) collection() *mongo.Collection {
+	return config.GetCollection(s.Client, "authenticate", "apikeys")
+}
+
+func (s *MongoAPIKeyStore) CreateAPIKey(ctx context.Context,
+	key *users.APIKey) error {
+	if key.ID.IsZero() {
+		key.ID = primitive.NewObjectID()
+	}
+	_, err := s.collection().InsertOne(ctx, key)
+	return err
+}
+
+func (s *MongoAPIKeyStore) GetAPIKey(ctx context.Context,
+	id string) (*users.APIKey, error) {
+	keyid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.findOne(ctx, bson.M{"_id": keyid})
+}
+
+func (s *MongoAPIKeyStore) GetAPIKeyByHash(ctx context.Context,
+	hash string) (*users.APIKey, error) {
+	return s.findOne(ctx, bson.M{"keyhash": hash})
+}
+
+func (s *MongoAPIKeyStore) findOne(ctx context.Context,
+	filter bson.M) (*users.APIKey, error) {
+	var key users.APIKey
+	if err := s.collection().FindOne(ctx, filter).Decode(&key); err != nil {
+		return nil, storeError(err)
+	}
+	return &key, nil
+}
+
+func (s *MongoAPIKeyStore) GetAPIKeys(ctx context.Context,
+	app string) ([]users.APIKey, error) {
+	var list []users.APIKey
+	filter := bson.M{}
+	if app != "" {
+		filter["application"] = strings.ToLower(app)
+	}
+
+	cursor, err := s.collection().Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return list, err
+	}
+	if err = cursor.All(ctx, &list); err != nil {
+		return list, err
+	}
+	return list, nil
+}
+
+func (s *MongoAPIKeyStore) TouchAPIKey(ctx context.Context, id string,
+	at time.Time) error {
+	return s.set(ctx, id, bson.M{"lastused": at})
+}
+
+func (s *MongoAPIKeyStore) RevokeAPIKey(ctx context.Context, id string,
+	at time.Time) error {
+	return s.set(ctx, id, bson.M{"revokedat": at})
+}
+
+func (s *MongoAPIKeyStore) set(ctx context.Context, id string,
+	fields bson.M) error {
+	keyid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	result, err := s.collection().UpdateOne(ctx, bson.M{"_id": keyid},
+		bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// pgAPIKey is the postgres row for an api key, with the key's roles in their
+// string form.
+type pgAPIKey struct {
+	ID          string `gorm:"primary_key;type:char(24)"`
+	Name        string
+	Application string         `gorm:"index"`
+	Roles       pq.StringArray `gorm:"type:text[]"`
+	Prefix      string
+	KeyHash     string `gorm:"unique_index"`
+	CreatedBy   string
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	LastUsed    *time.Time
+	RevokedAt   *time.Time
+}
+
+func (pgAPIKey) TableName() string {
+	return "api_keys"
+}
+
+func toPgAPIKey(key users.APIKey) *pgAPIKey {
+	return &pgAPIKey{
+		ID:          key.ID.Hex(),
+		Name:        key.Name,
+		Application: key.Application,
+		Roles:       pgRoles(key.Roles),
+		Prefix:      key.Prefix,
+		KeyHash:     key.KeyHash,
+		CreatedBy:   key.CreatedBy,
+		CreatedAt:   key.CreatedAt,
+		ExpiresAt:   key.ExpiresAt,
+		LastUsed:    key.LastUsed,
+		RevokedAt:   key.RevokedAt,
+	}
+}
+
+func (k *pgAPIKey) toAPIKey() *users.APIKey {
+	id, _ := primitive.ObjectIDFromHex(k.ID)
+	return &users.APIKey{
+		ID:          id,
+		Name:        k.Name,
+		Application: k.Application,
+		Roles:       pgRolesToUser(k.Roles),
+		Prefix:      k.Prefix,
+		KeyHash:     k.KeyHash,
+		CreatedBy:   k.CreatedBy,
+		CreatedAt:   k.CreatedAt,
+		ExpiresAt:   k.ExpiresAt,
+		LastUsed:    k.LastUsed,
+		RevokedAt:   k.RevokedAt,
+	}
+}
+
+// PgAPIKeyStore keeps the api keys in the postgres api_keys table.
+type PgAPIKeyStore struct {
+	DB *gorm.DB
+}
+
+func NewPgAPIKeyStore(db *gorm.DB) *PgAPIKeyStore {
+	return &PgAPIKeyStore{DB: db}
+}
+
+func (s *PgAPIKeyStore) CreateAPIKey(ctx context.Context,
+	key *users.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if key.ID.IsZero() {
+		key.ID = primitive.NewObjectID()
+	}
+	return s.DB.Create(toPgAPIKey(*key)).Error
+}
+
+func (s *PgAPIKeyStore) GetAPIKey(ctx context.Context,
+	id string) (*users.APIKey, error) {
+	return s.findOne(ctx, s.DB.Where("id = ?", id))
+}
+
+func (s *PgAPIKeyStore) GetAPIKeyByHash(ctx context.Context,
+	hash string) (*users.APIKey, error) {
+	return s.findOne(ctx, s.DB.Where("key_hash = ?", hash))
+}
+
+func (s *PgAPIKeyStore) findOne(ctx context.Context,
+	query *gorm.DB) (*users.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var row pgAPIKey
+	if err := query.First(&row).Error; err != nil {
+		return nil, storeError(err)
+	}
+	return row.toAPIKey(), nil
+}
+
+func (s *PgAPIKeyStore) GetAPIKeys(ctx context.Context,
+	app string) ([]users.APIKey, error) {
+	var list []users.APIKey
+	if err := ctx.Err(); err != nil {
+		return list, err
+	}
+	query := s.DB.Order("name")
+	if app != "" {
+		query = query.Where("application = ?", strings.ToLower(app))
+	}
+	var rows []pgAPIKey
+	if err := query.Find(&rows).Error; err != nil {
+		return list, err
+	}
+	for _, row := range rows {
+		list = append(list, *row.toAPIKey())
+	}
+	return list, nil
+}
+
+func (s *PgAPIKeyStore) TouchAPIKey(ctx context.Context, id string,
+	at time.Time) error {
+	return s.update(ctx, id, "last_used", at)
+}
+
+func (s *PgAPIKeyStore) RevokeAPIKey(ctx context.Context, id string,
+	at time.Time) error {
+	return s.update(ctx, id, "revoked_at", at)
+}
+
+func (s *PgAPIKeyStore) update(ctx context.Context, id, column string,
+	at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	result := s.DB.Model(&pgAPIKey{}).Where("id = ?", id).Update(column, at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MemoryAPIKeyStore keeps the api keys in memory.
+type MemoryAPIKeyStore struct {
+	mutex sync.RWMutex
+	keys  map[string]users.APIKey
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: make(map[string]users.APIKey)}
+}
+
+func copyAPIKey(key users.APIKey) users.APIKey {
+	key.Roles = append([]users.Role(nil), key.Roles...)
+	return key
+}
+
+func (s *MemoryAPIKeyStore) CreateAPIKey(ctx context.Context,
+	key *users.APIKey) error {
+	if key.ID.IsZero() {
+		key.ID = primitive.NewObjectID()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[key.ID.Hex()] = copyAPIKey(*key)
+	return nil
+}
+
+func (s *MemoryAPIKeyStore) GetAPIKey(ctx context.Context,
+	id string) (*users.APIKey, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	key = copyAPIKey(key)
+	return &key, nil
+}
+
+func (s *MemoryAPIKeyStore) GetAPIKeyByHash(ctx context.Context,
+	hash string) (*users.APIKey, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, key := range s.keys {
+		if key.KeyHash == hash {
+			key = copyAPIKey(key)
+			return &key, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryAPIKeyStore) GetAPIKeys(ctx context.Context,
+	app string) ([]users.APIKey, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var list []users.APIKey
+	for _, key := range s.keys {
+		if app == "" || strings.EqualFold(key.Application, app) {
+			list = append(list, copyAPIKey(key))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+func (s *MemoryAPIKeyStore) TouchAPIKey(ctx context.Context, id string,
+	at time.Time) error {
+	return s.update(id, func(key *users.APIKey) {
+		key.LastUsed = &at
+	})
+}
+
+func (s *MemoryAPIKeyStore) RevokeAPIKey(ctx context.Context, id string,
+	at time.Time) error {
+	return s.update(id, func(key *users.APIKey) {
+		key.RevokedAt = &at
+	})
+}
+
+func (s *MemoryAPIKeyStore) update(id string, change func(*users.APIKey)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	change(&key)
+	s.keys[id] = key
+	return nil
+}
@@ -0,0 +1,797 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/employees"
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// EmployeeStore is the storage for the scheduler's employees.  UpdateEmployee
+// only saves the employee while the stored version is the one it was read
+// with, returning a ConflictError otherwise, and advances the employee's
+// version.
+type EmployeeStore interface {
+	CreateEmployee(ctx context.Context, emp *employees.Employee) error
+	GetEmployee(ctx context.Context, id string) (*employees.Employee, error)
+	GetEmployees(ctx context.Context) ([]employees.Employee, error)
+	GetEmployeesBySite(ctx context.Context, teamID, siteID string) ([]employees.Employee, error)
+	GetEmployeeByUserID(ctx context.Context, userid string) (*employees.Employee, error)
+	UpdateEmployee(ctx context.Context, emp *employees.Employee) error
+	DeleteEmployee(ctx context.Context, id string) error
+}
+
+// MongoEmployeeStore keeps the employees in the scheduler database's
+// employees collection.
+type MongoEmployeeStore struct {
+	Client *mongo.Client
+}
+
+func NewMongoEmployeeStore(client *mongo.Client) *MongoEmployeeStore {
+	return &MongoEmployeeStore{Client: client}
+}
+
+func (s *MongoEmployeeStore) collection() *mongo.Collection {
+	return config.GetCollection(s.Client, "scheduler", "employees")
+}
+
+func (s *MongoEmployeeStore) CreateEmployee(ctx context.Context,
+	emp *employees.Employee) error {
+	if emp.ID.IsZero() {
+		emp.ID = primitive.NewObjectID()
+	}
+	_, err := s.collection().InsertOne(ctx, emp)
+	return err
+}
+
+func (s *MongoEmployeeStore) GetEmployee(ctx context.Context,
+	id string) (*employees.Employee, error) {
+	empID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"_id": empID,
+	}
+
+	var emp employees.Employee
+	if err := s.collection().FindOne(ctx, filter).Decode(&emp); err != nil {
+		return nil, storeError(err)
+	}
+	return &emp, nil
+}
+
+func (s *MongoEmployeeStore) find(ctx context.Context,
+	filter bson.M) ([]employees.Employee, error) {
+	var list []employees.Employee
+
+	cursor, err := s.collection().Find(ctx, filter)
+	if err != nil {
+		return list, err
+	}
+
+	if err = cursor.All(ctx, &list); err != nil {
+		return list, err
+	}
+	sort.Sort(employees.ByEmployees(list))
+	return list, nil
+}
+
+func (s *MongoEmployeeStore) GetEmployees(ctx context.Context) ([]employees.Employee, error) {
+	return s.find(ctx, bson.M{})
+}
+
+func (s *MongoEmployeeStore) GetEmployeesBySite(ctx context.Context, teamID,
+	siteID string) ([]employees.Employee, error) {
+	tid, err := primitive.ObjectIDFromHex(teamID)
+	if err != nil {
+		return nil, err
+	}
+	return s.find(ctx, bson.M{
+		"team": tid,
+		"site": siteID,
+	})
+}
+
+func (s *MongoEmployeeStore) GetEmployeeByUserID(ctx context.Context,
+	userid string) (*employees.Employee, error) {
+	uid, err := primitive.ObjectIDFromHex(userid)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"userid": uid,
+	}
+
+	var emp employees.Employee
+	if err := s.collection().FindOne(ctx, filter).Decode(&emp); err != nil {
+		return nil, storeError(err)
+	}
+	return &emp, nil
+}
+
+func (s *MongoEmployeeStore) UpdateEmployee(ctx context.Context,
+	emp *employees.Employee) error {
+	filter := mongoVersionFilter(emp.ID, emp.Version)
+
+	updated := *emp
+	updated.Version++
+	result, err := s.collection().ReplaceOne(ctx, filter, updated)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongoConflict(ctx, s.collection(), "employee", emp.ID,
+			emp.Version)
+	}
+	emp.Version = updated.Version
+	return nil
+}
+
+func (s *MongoEmployeeStore) DeleteEmployee(ctx context.Context, id string) error {
+	empID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id": empID,
+	}
+
+	_, err = s.collection().DeleteOne(ctx, filter)
+	return err
+}
+
+// pgEmployee is the postgres row for an employee.  The nested slices are kept
+// in their own tables, except the labor codes, contacts and specialties which
+// are small enough to be kept as json.
+type pgEmployee struct {
+	ID                string `gorm:"primary_key;type:char(24)"`
+	TeamID            string `gorm:"type:char(24);index"`
+	SiteID            string `gorm:"index"`
+	UserID            string `gorm:"type:char(24);index"`
+	Email             string
+	FirstName         string
+	MiddleName        string
+	LastName          string
+	Suffix            string
+	Company           string
+	CompanyEmployeeID string
+	AlternateID       string
+	JobTitle          string
+	Rank              string
+	CostCenter        string
+	Division          string
+	LaborCodes        string `gorm:"type:jsonb"`
+	ContactInfo       string `gorm:"type:jsonb"`
+	Specialties       string `gorm:"type:jsonb"`
+	Version           uint
+}
+
+func (pgEmployee) TableName() string {
+	return "employees"
+}
+
+type pgAssignment struct {
+	ID           uint   `gorm:"primary_key"`
+	EmployeeID   string `gorm:"type:char(24);index"`
+	AssignmentID uint
+	Site         string
+	Workcenter   string
+	StartDate    time.Time
+	EndDate      time.Time
+	RotationDate time.Time
+	RotationDays int
+	Schedules    string `gorm:"type:jsonb"`
+	LaborCodes   string `gorm:"type:jsonb"`
+}
+
+func (pgAssignment) TableName() string {
+	return "employee_assignments"
+}
+
+type pgVariation struct {
+	ID          uint   `gorm:"primary_key"`
+	EmployeeID  string `gorm:"type:char(24);index"`
+	VariationID uint
+	Site        string
+	IsMids      bool
+	StartDate   time.Time
+	EndDate     time.Time
+	Schedule    string `gorm:"type:jsonb"`
+}
+
+func (pgVariation) TableName() string {
+	return "employee_variations"
+}
+
+type pgBalance struct {
+	ID         uint   `gorm:"primary_key"`
+	EmployeeID string `gorm:"type:char(24);index"`
+	Year       int
+	Annual     float64
+	Carryover  float64
+}
+
+func (pgBalance) TableName() string {
+	return "employee_balances"
+}
+
+type pgLeave struct {
+	ID         uint   `gorm:"primary_key"`
+	EmployeeID string `gorm:"type:char(24);index"`
+	LeaveID    int
+	LeaveDate  time.Time
+	Code       string
+	Hours      float64
+	Status     string
+	RequestID  string
+}
+
+func (pgLeave) TableName() string {
+	return "employee_leaves"
+}
+
+type pgLeaveRequest struct {
+	ID            string `gorm:"primary_key"`
+	EmployeeID    string `gorm:"type:char(24);index"`
+	RequestDate   time.Time
+	PrimaryCode   string
+	StartDate     time.Time
+	EndDate       time.Time
+	Status        string
+	ApprovedBy    string
+	ApprovalDate  time.Time
+	RequestedDays string `gorm:"type:jsonb"`
+	Comments      string `gorm:"type:jsonb"`
+}
+
+func (pgLeaveRequest) TableName() string {
+	return "employee_leave_requests"
+}
+
+// the employee child tables, which are replaced as a whole when the employee
+// is saved.
+var employeeChildren = []interface{}{
+	&pgAssignment{},
+	&pgVariation{},
+	&pgBalance{},
+	&pgLeave{},
+	&pgLeaveRequest{},
+}
+
+func toJSON(value interface{}) (string, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func fromJSON(content string, value interface{}) error {
+	if content == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(content), value)
+}
+
+// pgInsertEmployee writes the employee row and its children.
+func pgInsertEmployee(tx *gorm.DB, emp *employees.Employee) error {
+	row := &pgEmployee{
+		ID:                emp.ID.Hex(),
+		TeamID:            emp.TeamID.Hex(),
+		SiteID:            emp.SiteID,
+		UserID:            emp.UserID.Hex(),
+		Email:             emp.Email,
+		FirstName:         emp.Name.FirstName,
+		MiddleName:        emp.Name.MiddleName,
+		LastName:          emp.Name.LastName,
+		Suffix:            emp.Name.Suffix,
+		Company:           emp.CompanyInfo.Company,
+		CompanyEmployeeID: emp.CompanyInfo.EmployeeID,
+		AlternateID:       emp.CompanyInfo.AlternateID,
+		JobTitle:          emp.CompanyInfo.JobTitle,
+		Rank:              emp.CompanyInfo.Rank,
+		CostCenter:        emp.CompanyInfo.CostCenter,
+		Division:          emp.CompanyInfo.Division,
+		Version:           emp.Version,
+	}
+	var err error
+	if row.LaborCodes, err = toJSON(emp.LaborCodes); err != nil {
+		return err
+	}
+	if row.ContactInfo, err = toJSON(emp.ContactInfo); err != nil {
+		return err
+	}
+	if row.Specialties, err = toJSON(emp.Specialties); err != nil {
+		return err
+	}
+	if err := tx.Create(row).Error; err != nil {
+		return err
+	}
+
+	for _, asgmt := range emp.Assignments {
+		child := &pgAssignment{
+			EmployeeID:   row.ID,
+			AssignmentID: asgmt.ID,
+			Site:         asgmt.Site,
+			Workcenter:   asgmt.Workcenter,
+			StartDate:    asgmt.StartDate,
+			EndDate:      asgmt.EndDate,
+			RotationDate: asgmt.RotationDate,
+			RotationDays: asgmt.RotationDays,
+		}
+		if child.Schedules, err = toJSON(asgmt.Schedules); err != nil {
+			return err
+		}
+		if child.LaborCodes, err = toJSON(asgmt.LaborCodes); err != nil {
+			return err
+		}
+		if err := tx.Create(child).Error; err != nil {
+			return err
+		}
+	}
+	for _, vari := range emp.Variations {
+		child := &pgVariation{
+			EmployeeID:  row.ID,
+			VariationID: vari.ID,
+			Site:        vari.Site,
+			IsMids:      vari.IsMids,
+			StartDate:   vari.StartDate,
+			EndDate:     vari.EndDate,
+		}
+		if child.Schedule, err = toJSON(vari.Schedule); err != nil {
+			return err
+		}
+		if err := tx.Create(child).Error; err != nil {
+			return err
+		}
+	}
+	for _, bal := range emp.Balances {
+		child := &pgBalance{
+			EmployeeID: row.ID,
+			Year:       bal.Year,
+			Annual:     bal.Annual,
+			Carryover:  bal.Carryover,
+		}
+		if err := tx.Create(child).Error; err != nil {
+			return err
+		}
+	}
+	for _, lv := range emp.Leaves {
+		child := &pgLeave{
+			EmployeeID: row.ID,
+			LeaveID:    lv.ID,
+			LeaveDate:  lv.LeaveDate,
+			Code:       lv.Code,
+			Hours:      lv.Hours,
+			Status:     lv.Status,
+			RequestID:  lv.RequestID,
+		}
+		if err := tx.Create(child).Error; err != nil {
+			return err
+		}
+	}
+	for _, req := range emp.Requests {
+		child := &pgLeaveRequest{
+			ID:           req.ID,
+			EmployeeID:   row.ID,
+			RequestDate:  req.RequestDate,
+			PrimaryCode:  req.PrimaryCode,
+			StartDate:    req.StartDate,
+			EndDate:      req.EndDate,
+			Status:       req.Status,
+			ApprovedBy:   req.ApprovedBy,
+			ApprovalDate: req.ApprovalDate,
+		}
+		if child.RequestedDays, err = toJSON(req.RequestedDays); err != nil {
+			return err
+		}
+		if child.Comments, err = toJSON(req.Comments); err != nil {
+			return err
+		}
+		if err := tx.Create(child).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pgLoadEmployee builds the employee from its row and children.
+func pgLoadEmployee(db *gorm.DB, row *pgEmployee) (*employees.Employee, error) {
+	emp := &employees.Employee{
+		SiteID:  row.SiteID,
+		Email:   row.Email,
+		Version: row.Version,
+		Name: employees.EmployeeName{
+			FirstName:  row.FirstName,
+			MiddleName: row.MiddleName,
+			LastName:   row.LastName,
+			Suffix:     row.Suffix,
+		},
+		CompanyInfo: employees.CompanyInfo{
+			Company:     row.Company,
+			EmployeeID:  row.CompanyEmployeeID,
+			AlternateID: row.AlternateID,
+			JobTitle:    row.JobTitle,
+			Rank:        row.Rank,
+			CostCenter:  row.CostCenter,
+			Division:    row.Division,
+		},
+	}
+	emp.ID, _ = primitive.ObjectIDFromHex(row.ID)
+	emp.TeamID, _ = primitive.ObjectIDFromHex(row.TeamID)
+	emp.UserID, _ = primitive.ObjectIDFromHex(row.UserID)
+	if err := fromJSON(row.LaborCodes, &emp.LaborCodes); err != nil {
+		return nil, err
+	}
+	if err := fromJSON(row.ContactInfo, &emp.ContactInfo); err != nil {
+		return nil, err
+	}
+	if err := fromJSON(row.Specialties, &emp.Specialties); err != nil {
+		return nil, err
+	}
+
+	var asgmts []pgAssignment
+	if err := db.Where("employee_id = ?", row.ID).Find(&asgmts).Error; err != nil {
+		return nil, err
+	}
+	for _, child := range asgmts {
+		asgmt := employees.Assignment{
+			ID:           child.AssignmentID,
+			Site:         child.Site,
+			Workcenter:   child.Workcenter,
+			StartDate:    child.StartDate,
+			EndDate:      child.EndDate,
+			RotationDate: child.RotationDate,
+			RotationDays: child.RotationDays,
+		}
+		if err := fromJSON(child.Schedules, &asgmt.Schedules); err != nil {
+			return nil, err
+		}
+		if err := fromJSON(child.LaborCodes, &asgmt.LaborCodes); err != nil {
+			return nil, err
+		}
+		emp.Assignments = append(emp.Assignments, asgmt)
+	}
+	sort.Sort(employees.ByAssignment(emp.Assignments))
+
+	var varis []pgVariation
+	if err := db.Where("employee_id = ?", row.ID).Find(&varis).Error; err != nil {
+		return nil, err
+	}
+	for _, child := range varis {
+		vari := employees.Variation{
+			ID:        child.VariationID,
+			Site:      child.Site,
+			IsMids:    child.IsMids,
+			StartDate: child.StartDate,
+			EndDate:   child.EndDate,
+		}
+		if err := fromJSON(child.Schedule, &vari.Schedule); err != nil {
+			return nil, err
+		}
+		emp.Variations = append(emp.Variations, vari)
+	}
+	sort.Sort(employees.ByVariation(emp.Variations))
+
+	var bals []pgBalance
+	if err := db.Where("employee_id = ?", row.ID).Find(&bals).Error; err != nil {
+		return nil, err
+	}
+	for _, child := range bals {
+		emp.Balances = append(emp.Balances, employees.AnnualLeave{
+			Year:      child.Year,
+			Annual:    child.Annual,
+			Carryover: child.Carryover,
+		})
+	}
+	sort.Sort(employees.ByBalance(emp.Balances))
+
+	var leaves []pgLeave
+	if err := db.Where("employee_id = ?", row.ID).Find(&leaves).Error; err != nil {
+		return nil, err
+	}
+	for _, child := range leaves {
+		emp.Leaves = append(emp.Leaves, employees.LeaveDay{
+			ID:        child.LeaveID,
+			LeaveDate: child.LeaveDate,
+			Code:      child.Code,
+			Hours:     child.Hours,
+			Status:    child.Status,
+			RequestID: child.RequestID,
+		})
+	}
+	sort.Sort(employees.ByLeaveDay(emp.Leaves))
+
+	var reqs []pgLeaveRequest
+	if err := db.Where("employee_id = ?", row.ID).Find(&reqs).Error; err != nil {
+		return nil, err
+	}
+	for _, child := range reqs {
+		req := employees.LeaveRequest{
+			ID:           child.ID,
+			EmployeeID:   row.ID,
+			RequestDate:  child.RequestDate,
+			PrimaryCode:  child.PrimaryCode,
+			StartDate:    child.StartDate,
+			EndDate:      child.EndDate,
+			Status:       child.Status,
+			ApprovedBy:   child.ApprovedBy,
+			ApprovalDate: child.ApprovalDate,
+		}
+		if err := fromJSON(child.RequestedDays, &req.RequestedDays); err != nil {
+			return nil, err
+		}
+		if err := fromJSON(child.Comments, &req.Comments); err != nil {
+			return nil, err
+		}
+		emp.Requests = append(emp.Requests, req)
+	}
+	sort.Sort(employees.ByLeaveRequest(emp.Requests))
+	return emp, nil
+}
+
+// PgEmployeeStore keeps the employees in the postgres employees table and its
+// child tables.
+type PgEmployeeStore struct {
+	DB *gorm.DB
+}
+
+func NewPgEmployeeStore(db *gorm.DB) *PgEmployeeStore {
+	return &PgEmployeeStore{DB: db}
+}
+
+// CreateEmployee saves a new employee, moving any legacy data into the top
+// level fields first since postgres has no place for it.
+func (s *PgEmployeeStore) CreateEmployee(ctx context.Context,
+	emp *employees.Employee) error {
+	if emp.ID.IsZero() {
+		emp.ID = primitive.NewObjectID()
+	}
+	emp.ConvertFromData()
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		return pgInsertEmployee(tx, emp)
+	})
+}
+
+func (s *PgEmployeeStore) GetEmployee(ctx context.Context,
+	id string) (*employees.Employee, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var row pgEmployee
+	if err := s.DB.Where("id = ?", id).First(&row).Error; err != nil {
+		return nil, storeError(err)
+	}
+	return pgLoadEmployee(s.DB, &row)
+}
+
+func (s *PgEmployeeStore) find(ctx context.Context,
+	query *gorm.DB) ([]employees.Employee, error) {
+	var list []employees.Employee
+	if err := ctx.Err(); err != nil {
+		return list, err
+	}
+	var rows []pgEmployee
+	if err := query.Find(&rows).Error; err != nil {
+		return list, err
+	}
+	for i := range rows {
+		emp, err := pgLoadEmployee(s.DB, &rows[i])
+		if err != nil {
+			return list, err
+		}
+		list = append(list, *emp)
+	}
+	sort.Sort(employees.ByEmployees(list))
+	return list, nil
+}
+
+func (s *PgEmployeeStore) GetEmployees(ctx context.Context) ([]employees.Employee, error) {
+	return s.find(ctx, s.DB)
+}
+
+func (s *PgEmployeeStore) GetEmployeesBySite(ctx context.Context, teamID,
+	siteID string) ([]employees.Employee, error) {
+	return s.find(ctx, s.DB.Where("team_id = ? AND site_id = ?", teamID,
+		siteID))
+}
+
+func (s *PgEmployeeStore) GetEmployeeByUserID(ctx context.Context,
+	userid string) (*employees.Employee, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var row pgEmployee
+	if err := s.DB.Where("user_id = ?", userid).First(&row).Error; err != nil {
+		return nil, storeError(err)
+	}
+	return pgLoadEmployee(s.DB, &row)
+}
+
+// UpdateEmployee replaces the employee's row and all of its children.
+func (s *PgEmployeeStore) UpdateEmployee(ctx context.Context,
+	emp *employees.Employee) error {
+	emp.ConvertFromData()
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		err := pgCheckVersion(tx, "employees", "employee", emp.ID.Hex(),
+			emp.Version)
+		if err != nil {
+			return err
+		}
+		if err := pgDeleteEmployee(tx, emp.ID.Hex()); err != nil {
+			return err
+		}
+		updated := *emp
+		updated.Version++
+		if err := pgInsertEmployee(tx, &updated); err != nil {
+			return err
+		}
+		emp.Version = updated.Version
+		return nil
+	})
+}
+
+func (s *PgEmployeeStore) DeleteEmployee(ctx context.Context, id string) error {
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		err := pgDeleteEmployee(tx, id)
+		if err == ErrNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+func pgDeleteEmployee(tx *gorm.DB, id string) error {
+	for _, child := range employeeChildren {
+		if err := tx.Where("employee_id = ?", id).Delete(child).Error; err != nil {
+			return err
+		}
+	}
+	result := tx.Where("id = ?", id).Delete(&pgEmployee{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MemoryEmployeeStore keeps the employees in memory, for unit tests and
+// applications without a database.
+type MemoryEmployeeStore struct {
+	mutex     sync.RWMutex
+	employees map[string][]byte
+}
+
+func NewMemoryEmployeeStore() *MemoryEmployeeStore {
+	return &MemoryEmployeeStore{employees: make(map[string][]byte)}
+}
+
+// the memory store keeps each employee encoded, so the callers never share
+// the nested slices with the stored copy.
+func (s *MemoryEmployeeStore) decode(raw []byte) (*employees.Employee, error) {
+	var emp employees.Employee
+	if err := bson.Unmarshal(raw, &emp); err != nil {
+		return nil, err
+	}
+	return &emp, nil
+}
+
+func (s *MemoryEmployeeStore) CreateEmployee(ctx context.Context,
+	emp *employees.Employee) error {
+	if emp.ID.IsZero() {
+		emp.ID = primitive.NewObjectID()
+	}
+	raw, err := bson.Marshal(emp)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.employees[emp.ID.Hex()] = raw
+	return nil
+}
+
+func (s *MemoryEmployeeStore) GetEmployee(ctx context.Context,
+	id string) (*employees.Employee, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	raw, ok := s.employees[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.decode(raw)
+}
+
+func (s *MemoryEmployeeStore) find(
+	match func(emp *employees.Employee) bool) ([]employees.Employee, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var list []employees.Employee
+	for _, raw := range s.employees {
+		emp, err := s.decode(raw)
+		if err != nil {
+			return list, err
+		}
+		if match(emp) {
+			list = append(list, *emp)
+		}
+	}
+	sort.Sort(employees.ByEmployees(list))
+	return list, nil
+}
+
+func (s *MemoryEmployeeStore) GetEmployees(ctx context.Context) ([]employees.Employee, error) {
+	return s.find(func(emp *employees.Employee) bool {
+		return true
+	})
+}
+
+func (s *MemoryEmployeeStore) GetEmployeesBySite(ctx context.Context, teamID,
+	siteID string) ([]employees.Employee, error) {
+	return s.find(func(emp *employees.Employee) bool {
+		return emp.TeamID.Hex() == teamID && emp.SiteID == siteID
+	})
+}
+
+func (s *MemoryEmployeeStore) GetEmployeeByUserID(ctx context.Context,
+	userid string) (*employees.Employee, error) {
+	list, err := s.find(func(emp *employees.Employee) bool {
+		return !emp.UserID.IsZero() && emp.UserID.Hex() == userid
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return &list[0], nil
+}
+
+func (s *MemoryEmployeeStore) UpdateEmployee(ctx context.Context,
+	emp *employees.Employee) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	raw, ok := s.employees[emp.ID.Hex()]
+	if !ok {
+		return ErrNotFound
+	}
+	current, err := s.decode(raw)
+	if err != nil {
+		return err
+	}
+	if current.Version != emp.Version {
+		return &ConflictError{Kind: "employee", ID: emp.ID.Hex(),
+			Version: emp.Version}
+	}
+
+	updated := *emp
+	updated.Version++
+	raw, err = bson.Marshal(&updated)
+	if err != nil {
+		return err
+	}
+	s.employees[emp.ID.Hex()] = raw
+	emp.Version = updated.Version
+	return nil
+}
+
+func (s *MemoryEmployeeStore) DeleteEmployee(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.employees, id)
+	return nil
+}
@@ -0,0 +1,57 @@
+package stores
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned by every store implementation when the requested
+// record doesn't exist.
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicate is returned by every store implementation when a new record
+// would repeat a value which must be unique, such as a user's email address.
+var ErrDuplicate = errors.New("duplicate record")
+
+// ErrConflict is matched, through errors.Is, by the ConflictError returned
+// when an update loses a race with another writer.
+var ErrConflict = errors.New("record changed since it was read")
+
+// ConflictError is returned by a conditional update when the stored record's
+// version is no longer the one the caller read, meaning someone else saved it
+// in between.  The caller should read the record again and redo its change.
+type ConflictError struct {
+	Kind    string
+	ID      string
+	Version uint
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s changed since version %d was read", e.Kind, e.ID,
+		e.Version)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// pgUniqueViolation is the postgres error code of a unique index violation.
+const pgUniqueViolation = "23505"
+
+// storeError converts the database specific not found errors to ErrNotFound,
+// and the unique index violations to ErrDuplicate.
+func storeError(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) || gorm.IsRecordNotFoundError(err) {
+		return ErrNotFound
+	}
+	var pgErr *pq.Error
+	if mongo.IsDuplicateKeyError(err) ||
+		(errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation) {
+		return ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package stores
+
+import (
+	"context"
+	"sort"
+
+	"github.com/erneap/go-pg-models/labor"
+	"github.com/jinzhu/gorm"
+)
+
+// PgLaborCodeStore provides the postgres CRUD functions for labor codes.
+type PgLaborCodeStore struct {
+	DB *gorm.DB
+}
+
+func NewPgLaborCodeStore(db *gorm.DB) *PgLaborCodeStore {
+	return &PgLaborCodeStore{DB: db}
+}
+
+func (s *PgLaborCodeStore) CreateLaborCode(ctx context.Context,
+	lc *labor.LaborCode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.DB.Create(lc).Error
+}
+
+func (s *PgLaborCodeStore) GetLaborCode(ctx context.Context,
+	id uint) (*labor.LaborCode, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var lc labor.LaborCode
+	if err := s.DB.First(&lc, id).Error; err != nil {
+		return nil, err
+	}
+	return &lc, nil
+}
+
+func (s *PgLaborCodeStore) GetLaborCodeByNumber(ctx context.Context,
+	chargeNumber, extension string) (*labor.LaborCode, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var lc labor.LaborCode
+	err := s.DB.Where("charge_number = ? AND extension = ?", chargeNumber,
+		extension).First(&lc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &lc, nil
+}
+
+func (s *PgLaborCodeStore) GetLaborCodes(ctx context.Context) ([]labor.LaborCode, error) {
+	var codes []labor.LaborCode
+	if err := ctx.Err(); err != nil {
+		return codes, err
+	}
+	if err := s.DB.Find(&codes).Error; err != nil {
+		return codes, err
+	}
+	sort.Sort(labor.ByLaborCode(codes))
+	return codes, nil
+}
+
+func (s *PgLaborCodeStore) UpdateLaborCode(ctx context.Context,
+	lc *labor.LaborCode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.DB.Save(lc).Error
+}
+
+func (s *PgLaborCodeStore) DeleteLaborCode(ctx context.Context, id uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.DB.Unscoped().Delete(&labor.LaborCode{}, id).Error
+}
@@ -0,0 +1,332 @@
+package stores
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/logs"
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LogStore is the storage for the application log entries.  A blank
+// application in the retrieval and delete functions means all applications,
+// while zero begin or end dates leave that side of the date range open.
+type LogStore interface {
+	CreateLogEntry(ctx context.Context, entry *logs.LogEntry) error
+	GetLogEntry(ctx context.Context, id string) (*logs.LogEntry, error)
+	GetLogEntries(ctx context.Context, app string, begin, end time.Time) ([]logs.LogEntry, error)
+	UpdateLogEntry(ctx context.Context, entry logs.LogEntry) error
+	DeleteLogEntry(ctx context.Context, id string) error
+	DeleteLogEntriesBefore(ctx context.Context, app string, dt time.Time) error
+}
+
+// MongoLogStore keeps the log entries in the authenticate database's logs
+// collection.
+type MongoLogStore struct {
+	Client *mongo.Client
+}
+
+func NewMongoLogStore(client *mongo.Client) *MongoLogStore {
+	return &MongoLogStore{Client: client}
+}
+
+func (s *MongoLogStore) collection() *mongo.Collection {
+	return config.GetCollection(s.Client, "authenticate", "logs")
+}
+
+func (s *MongoLogStore) CreateLogEntry(ctx context.Context, entry *logs.LogEntry) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	_, err := s.collection().InsertOne(ctx, entry)
+	return err
+}
+
+func (s *MongoLogStore) GetLogEntry(ctx context.Context, id string) (*logs.LogEntry, error) {
+	logid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"_id": logid,
+	}
+
+	var entry logs.LogEntry
+	if err = s.collection().FindOne(ctx, filter).Decode(&entry); err != nil {
+		return nil, storeError(err)
+	}
+	return &entry, nil
+}
+
+func (s *MongoLogStore) GetLogEntries(ctx context.Context, app string,
+	begin, end time.Time) ([]logs.LogEntry, error) {
+	var entries []logs.LogEntry
+
+	filter := bson.M{}
+	if app != "" {
+		filter["application"] = app
+	}
+	dates := bson.M{}
+	if !begin.IsZero() {
+		dates["$gte"] = begin
+	}
+	if !end.IsZero() {
+		dates["$lt"] = end
+	}
+	if len(dates) > 0 {
+		filter["datetime"] = dates
+	}
+
+	cursor, err := s.collection().Find(ctx, filter)
+	if err != nil {
+		return entries, err
+	}
+
+	if err = cursor.All(ctx, &entries); err != nil {
+		return entries, err
+	}
+
+	sort.Sort(logs.ByLogEntry(entries))
+	return entries, nil
+}
+
+func (s *MongoLogStore) UpdateLogEntry(ctx context.Context, entry logs.LogEntry) error {
+	filter := bson.M{
+		"_id": entry.ID,
+	}
+
+	result, err := s.collection().ReplaceOne(ctx, filter, entry)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoLogStore) DeleteLogEntry(ctx context.Context, id string) error {
+	logid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id": logid,
+	}
+
+	_, err = s.collection().DeleteOne(ctx, filter)
+	return err
+}
+
+func (s *MongoLogStore) DeleteLogEntriesBefore(ctx context.Context, app string,
+	dt time.Time) error {
+	filter := bson.M{
+		"datetime": bson.M{"$lt": dt},
+	}
+	if app != "" {
+		filter["application"] = app
+	}
+
+	_, err := s.collection().DeleteMany(ctx, filter)
+	return err
+}
+
+// pgLogEntry is the postgres row for a log entry.
+type pgLogEntry struct {
+	ID          string    `gorm:"primary_key;type:char(24)"`
+	DateTime    time.Time `gorm:"index"`
+	Application string    `gorm:"index"`
+	Level       int64
+	Message     string
+}
+
+func (pgLogEntry) TableName() string {
+	return "logs"
+}
+
+func toPgLogEntry(entry logs.LogEntry) *pgLogEntry {
+	return &pgLogEntry{
+		ID:          entry.ID.Hex(),
+		DateTime:    entry.DateTime,
+		Application: entry.Application,
+		Level:       int64(entry.Level),
+		Message:     entry.Message,
+	}
+}
+
+func (e *pgLogEntry) toLogEntry() *logs.LogEntry {
+	id, _ := primitive.ObjectIDFromHex(e.ID)
+	return &logs.LogEntry{
+		ID:          id,
+		DateTime:    e.DateTime,
+		Application: e.Application,
+		Level:       logs.DebugLevel(e.Level),
+		Message:     e.Message,
+	}
+}
+
+// PgLogStore keeps the log entries in the postgres logs table.
+type PgLogStore struct {
+	DB *gorm.DB
+}
+
+func NewPgLogStore(db *gorm.DB) *PgLogStore {
+	return &PgLogStore{DB: db}
+}
+
+func (s *PgLogStore) CreateLogEntry(ctx context.Context, entry *logs.LogEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	return s.DB.Create(toPgLogEntry(*entry)).Error
+}
+
+func (s *PgLogStore) GetLogEntry(ctx context.Context, id string) (*logs.LogEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var row pgLogEntry
+	if err := s.DB.Where("id = ?", id).First(&row).Error; err != nil {
+		return nil, storeError(err)
+	}
+	return row.toLogEntry(), nil
+}
+
+func (s *PgLogStore) GetLogEntries(ctx context.Context, app string,
+	begin, end time.Time) ([]logs.LogEntry, error) {
+	var entries []logs.LogEntry
+	if err := ctx.Err(); err != nil {
+		return entries, err
+	}
+	query := s.DB
+	if app != "" {
+		query = query.Where("application = ?", app)
+	}
+	if !begin.IsZero() {
+		query = query.Where("date_time >= ?", begin)
+	}
+	if !end.IsZero() {
+		query = query.Where("date_time < ?", end)
+	}
+	var rows []pgLogEntry
+	if err := query.Find(&rows).Error; err != nil {
+		return entries, err
+	}
+	for _, row := range rows {
+		entries = append(entries, *row.toLogEntry())
+	}
+	sort.Sort(logs.ByLogEntry(entries))
+	return entries, nil
+}
+
+func (s *PgLogStore) UpdateLogEntry(ctx context.Context, entry logs.LogEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return pgReplace(s.DB, toPgLogEntry(entry), entry.ID.Hex())
+}
+
+func (s *PgLogStore) DeleteLogEntry(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.DB.Where("id = ?", id).Delete(&pgLogEntry{}).Error
+}
+
+func (s *PgLogStore) DeleteLogEntriesBefore(ctx context.Context, app string,
+	dt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	query := s.DB.Where("date_time < ?", dt)
+	if app != "" {
+		query = query.Where("application = ?", app)
+	}
+	return query.Delete(&pgLogEntry{}).Error
+}
+
+// MemoryLogStore keeps the log entries in memory.
+type MemoryLogStore struct {
+	mutex   sync.RWMutex
+	entries map[string]logs.LogEntry
+}
+
+func NewMemoryLogStore() *MemoryLogStore {
+	return &MemoryLogStore{entries: make(map[string]logs.LogEntry)}
+}
+
+func (s *MemoryLogStore) CreateLogEntry(ctx context.Context, entry *logs.LogEntry) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries[entry.ID.Hex()] = *entry
+	return nil
+}
+
+func (s *MemoryLogStore) GetLogEntry(ctx context.Context, id string) (*logs.LogEntry, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &entry, nil
+}
+
+func (s *MemoryLogStore) GetLogEntries(ctx context.Context, app string,
+	begin, end time.Time) ([]logs.LogEntry, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var entries []logs.LogEntry
+	for _, entry := range s.entries {
+		if (app == "" || entry.Application == app) &&
+			(begin.IsZero() || !entry.DateTime.Before(begin)) &&
+			(end.IsZero() || entry.DateTime.Before(end)) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Sort(logs.ByLogEntry(entries))
+	return entries, nil
+}
+
+func (s *MemoryLogStore) UpdateLogEntry(ctx context.Context, entry logs.LogEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.entries[entry.ID.Hex()]; !ok {
+		return ErrNotFound
+	}
+	s.entries[entry.ID.Hex()] = entry
+	return nil
+}
+
+func (s *MemoryLogStore) DeleteLogEntry(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+func (s *MemoryLogStore) DeleteLogEntriesBefore(ctx context.Context, app string,
+	dt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, entry := range s.entries {
+		if (app == "" || entry.Application == app) && entry.DateTime.Before(dt) {
+			delete(s.entries, id)
+		}
+	}
+	return nil
+}
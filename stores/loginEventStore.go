@@ -0,0 +1,242 @@
+package stores
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginEventStore is the storage for the accounts' login events.
+// GetLoginEvents returns the events newest first, where a blank user id or
+// event type means all of them, while zero begin or end dates leave that side
+// of the date range open.
+type LoginEventStore interface {
+	CreateLoginEvent(ctx context.Context, event *users.LoginEvent) error
+	GetLoginEvents(ctx context.Context, userid, eventType string, begin, end time.Time) ([]users.LoginEvent, error)
+	DeleteLoginEventsBefore(ctx context.Context, dt time.Time) error
+}
+
+// MongoLoginEventStore keeps the login events in the authenticate database's
+// loginevents collection.
+type MongoLoginEventStore struct {
+	Client *mongo.Client
+}
+
+func NewMongoLoginEventStore(client *mongo.Client) *MongoLoginEventStore {
+	return &MongoLoginEventStore{Client: client}
+}
+
+func (s *MongoLoginEventStore) collection() *mongo.Collection {
+	return config.GetCollection(s.Client, "authenticate", "loginevents")
+}
+
+func (s *MongoLoginEventStore) CreateLoginEvent(ctx context.Context,
+	event *users.LoginEvent) error {
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	_, err := s.collection().InsertOne(ctx, event)
+	return err
+}
+
+func (s *MongoLoginEventStore) GetLoginEvents(ctx context.Context, userid,
+	eventType string, begin, end time.Time) ([]users.LoginEvent, error) {
+	var events []users.LoginEvent
+
+	filter := bson.M{}
+	if userid != "" {
+		filter["userid"] = userid
+	}
+	if eventType != "" {
+		filter["type"] = eventType
+	}
+	dates := bson.M{}
+	if !begin.IsZero() {
+		dates["$gte"] = begin
+	}
+	if !end.IsZero() {
+		dates["$lt"] = end
+	}
+	if len(dates) > 0 {
+		filter["datetime"] = dates
+	}
+
+	cursor, err := s.collection().Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "datetime", Value: -1}}))
+	if err != nil {
+		return events, err
+	}
+	if err = cursor.All(ctx, &events); err != nil {
+		return events, err
+	}
+	return events, nil
+}
+
+func (s *MongoLoginEventStore) DeleteLoginEventsBefore(ctx context.Context,
+	dt time.Time) error {
+	_, err := s.collection().DeleteMany(ctx, bson.M{"datetime": bson.M{"$lt": dt}})
+	return err
+}
+
+// pgLoginEvent is the postgres row for a login event.
+type pgLoginEvent struct {
+	ID           string `gorm:"primary_key;type:char(24)"`
+	UserID       string `gorm:"index"`
+	EmailAddress string
+	Type         string
+	Detail       string
+	IPAddress    string
+	UserAgent    string
+	ActorID      string
+	DateTime     time.Time `gorm:"index"`
+}
+
+func (pgLoginEvent) TableName() string {
+	return "login_events"
+}
+
+func toPgLoginEvent(event users.LoginEvent) *pgLoginEvent {
+	return &pgLoginEvent{
+		ID:           event.ID.Hex(),
+		UserID:       event.UserID,
+		EmailAddress: event.EmailAddress,
+		Type:         event.Type,
+		Detail:       event.Detail,
+		IPAddress:    event.IPAddress,
+		UserAgent:    event.UserAgent,
+		ActorID:      event.ActorID,
+		DateTime:     event.DateTime,
+	}
+}
+
+func (e *pgLoginEvent) toLoginEvent() *users.LoginEvent {
+	id, _ := primitive.ObjectIDFromHex(e.ID)
+	return &users.LoginEvent{
+		ID:           id,
+		UserID:       e.UserID,
+		EmailAddress: e.EmailAddress,
+		Type:         e.Type,
+		Detail:       e.Detail,
+		IPAddress:    e.IPAddress,
+		UserAgent:    e.UserAgent,
+		ActorID:      e.ActorID,
+		DateTime:     e.DateTime,
+	}
+}
+
+// PgLoginEventStore keeps the login events in the postgres login_events
+// table.
+type PgLoginEventStore struct {
+	DB *gorm.DB
+}
+
+func NewPgLoginEventStore(db *gorm.DB) *PgLoginEventStore {
+	return &PgLoginEventStore{DB: db}
+}
+
+func (s *PgLoginEventStore) CreateLoginEvent(ctx context.Context,
+	event *users.LoginEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	return s.DB.Create(toPgLoginEvent(*event)).Error
+}
+
+func (s *PgLoginEventStore) GetLoginEvents(ctx context.Context, userid,
+	eventType string, begin, end time.Time) ([]users.LoginEvent, error) {
+	var events []users.LoginEvent
+	if err := ctx.Err(); err != nil {
+		return events, err
+	}
+	query := s.DB.Order("date_time desc")
+	if userid != "" {
+		query = query.Where("user_id = ?", userid)
+	}
+	if eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if !begin.IsZero() {
+		query = query.Where("date_time >= ?", begin)
+	}
+	if !end.IsZero() {
+		query = query.Where("date_time < ?", end)
+	}
+	var rows []pgLoginEvent
+	if err := query.Find(&rows).Error; err != nil {
+		return events, err
+	}
+	for _, row := range rows {
+		events = append(events, *row.toLoginEvent())
+	}
+	return events, nil
+}
+
+func (s *PgLoginEventStore) DeleteLoginEventsBefore(ctx context.Context,
+	dt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.DB.Where("date_time < ?", dt).Delete(&pgLoginEvent{}).Error
+}
+
+// MemoryLoginEventStore keeps the login events in memory.
+type MemoryLoginEventStore struct {
+	mutex  sync.RWMutex
+	events map[string]users.LoginEvent
+}
+
+func NewMemoryLoginEventStore() *MemoryLoginEventStore {
+	return &MemoryLoginEventStore{events: make(map[string]users.LoginEvent)}
+}
+
+func (s *MemoryLoginEventStore) CreateLoginEvent(ctx context.Context,
+	event *users.LoginEvent) error {
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events[event.ID.Hex()] = *event
+	return nil
+}
+
+func (s *MemoryLoginEventStore) GetLoginEvents(ctx context.Context, userid,
+	eventType string, begin, end time.Time) ([]users.LoginEvent, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var events []users.LoginEvent
+	for _, event := range s.events {
+		if (userid == "" || event.UserID == userid) &&
+			(eventType == "" || event.Type == eventType) &&
+			(begin.IsZero() || !event.DateTime.Before(begin)) &&
+			(end.IsZero() || event.DateTime.Before(end)) {
+			events = append(events, event)
+		}
+	}
+	sort.Sort(users.ByLoginEvent(events))
+	return events, nil
+}
+
+func (s *MemoryLoginEventStore) DeleteLoginEventsBefore(ctx context.Context,
+	dt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, event := range s.events {
+		if event.DateTime.Before(dt) {
+			delete(s.events, id)
+		}
+	}
+	return nil
+}
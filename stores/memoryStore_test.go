@@ -0,0 +1,183 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erneap/go-pg-models/employees"
+	"github.com/erneap/go-pg-models/logs"
+	"github.com/erneap/go-pg-models/users"
+)
+
+func TestMemoryUserStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+	user := &users.User{}
+	user.EmailAddress = "pat.doe@example.com"
+	user.LastName = "Doe"
+	user.Workgroups = []string{"scheduler-employee"}
+	if err := store.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.ID.IsZero() {
+		t.Fatal("CreateUser left the user without an id")
+	}
+
+	byEmail, err := store.GetUserByEmail(ctx, user.EmailAddress)
+	if err != nil || byEmail.ID != user.ID {
+		t.Fatalf("GetUserByEmail = %v, %v, want the created user", byEmail, err)
+	}
+	found, err := store.GetUserByID(ctx, user.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	found.Workgroups[0] = "scheduler-admin"
+	again, err := store.GetUserByID(ctx, user.ID.Hex())
+	if err != nil || !reflect.DeepEqual(again.Workgroups, user.Workgroups) {
+		t.Errorf("stored workgroups %v changed through a read copy, want %v",
+			again.Workgroups, user.Workgroups)
+	}
+
+	found.LastName = "Smith"
+	if err := store.UpdateUser(ctx, found); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if again, err := store.GetUserByID(ctx, user.ID.Hex()); err != nil ||
+		again.LastName != "Smith" {
+		t.Errorf("after UpdateUser, last name %q, %v, want Smith",
+			again.LastName, err)
+	}
+
+	if err := store.DeleteUser(ctx, user.ID.Hex()); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"deleted user", func() error {
+			_, err := store.GetUserByID(ctx, user.ID.Hex())
+			return err
+		}()},
+		{"unknown email", func() error {
+			_, err := store.GetUserByEmail(ctx, "nobody@example.com")
+			return err
+		}()},
+		{"update of a deleted user", store.UpdateUser(ctx, found)},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, ErrNotFound) {
+			t.Errorf("%s: %v, want %v", tt.name, tt.err, ErrNotFound)
+		}
+	}
+}
+
+func TestMemoryUserStoreVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+	user := &users.User{}
+	user.EmailAddress = "pat.doe@example.com"
+	if err := store.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	first, _ := store.GetUserByID(ctx, user.ID.Hex())
+	second, _ := store.GetUserByID(ctx, user.ID.Hex())
+
+	first.LastName = "Doe"
+	if err := store.UpdateUser(ctx, first); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if first.Version != user.Version+1 {
+		t.Errorf("version after the update %d, want %d", first.Version,
+			user.Version+1)
+	}
+	second.LastName = "Smith"
+	err := store.UpdateUser(ctx, second)
+	var conflict *ConflictError
+	if !errors.Is(err, ErrConflict) || !errors.As(err, &conflict) ||
+		conflict.Version != user.Version {
+		t.Fatalf("stale UpdateUser = %v, want a conflict at version %d", err,
+			user.Version)
+	}
+	if second.Version != user.Version {
+		t.Errorf("refused update changed the version to %d", second.Version)
+	}
+	if stored, _ := store.GetUserByID(ctx, user.ID.Hex()); stored.LastName !=
+		"Doe" {
+		t.Errorf("stale update overwrote the last name with %q", stored.LastName)
+	}
+}
+
+func TestMemoryEmployeeStoreVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryEmployeeStore()
+	emp := &employees.Employee{Email: "pat.doe@example.com"}
+	if err := store.CreateEmployee(ctx, emp); err != nil {
+		t.Fatalf("CreateEmployee: %v", err)
+	}
+	first, _ := store.GetEmployee(ctx, emp.ID.Hex())
+	second, _ := store.GetEmployee(ctx, emp.ID.Hex())
+
+	first.SiteID = "dgsc"
+	if err := store.UpdateEmployee(ctx, first); err != nil {
+		t.Fatalf("UpdateEmployee: %v", err)
+	}
+	second.SiteID = "lmc"
+	if err := store.UpdateEmployee(ctx, second); !errors.Is(err, ErrConflict) {
+		t.Fatalf("stale UpdateEmployee = %v, want %v", err, ErrConflict)
+	}
+	stored, err := store.GetEmployee(ctx, emp.ID.Hex())
+	if err != nil || stored.SiteID != "dgsc" || stored.Version != first.Version {
+		t.Errorf("stored employee site %q version %d, %v; want dgsc version %d",
+			stored.SiteID, stored.Version, err, first.Version)
+	}
+
+	second, _ = store.GetEmployee(ctx, emp.ID.Hex())
+	second.SiteID = "lmc"
+	if err := store.UpdateEmployee(ctx, second); err != nil {
+		t.Errorf("UpdateEmployee after reading again: %v", err)
+	}
+}
+
+func TestMemoryLogStoreRange(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryLogStore()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	for _, entry := range []logs.LogEntry{
+		{Application: "scheduler", DateTime: now.Add(-2 * time.Hour)},
+		{Application: "scheduler", DateTime: now.Add(-time.Hour)},
+		{Application: "scheduler", DateTime: now},
+		{Application: "authenticate", DateTime: now.Add(-time.Hour)},
+	} {
+		entry := entry
+		if err := store.CreateLogEntry(ctx, &entry); err != nil {
+			t.Fatalf("CreateLogEntry: %v", err)
+		}
+	}
+	tests := []struct {
+		name       string
+		app        string
+		begin, end time.Time
+		want       int
+	}{
+		{"all entries", "", time.Time{}, time.Time{}, 4},
+		{"one application", "scheduler", time.Time{}, time.Time{}, 3},
+		{"from the beginning", "scheduler", now.Add(-time.Hour), time.Time{}, 2},
+		{"before the end", "scheduler", time.Time{}, now, 2},
+		{"within the range", "", now.Add(-time.Hour), now, 2},
+	}
+	for _, tt := range tests {
+		entries, err := store.GetLogEntries(ctx, tt.app, tt.begin, tt.end)
+		if err != nil || len(entries) != tt.want {
+			t.Errorf("%s: %d entries, %v, want %d", tt.name, len(entries), err,
+				tt.want)
+		}
+	}
+	if _, err := store.GetLogEntry(ctx, "unknown"); !errors.Is(err,
+		ErrNotFound) {
+		t.Errorf("GetLogEntry of an unknown id = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,36 @@
+CREATE TABLE "users" (
+	"id" char(24),
+	"email_address" text,
+	"password" text,
+	"password_expires" timestamp with time zone,
+	"bad_attempts" integer,
+	"first_name" text,
+	"middle_name" text,
+	"last_name" text,
+	"workgroups" text[],
+	"reset_token" text,
+	"reset_token_exp" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX uix_users_email_address ON "users"(email_address);
+
+CREATE TABLE "logs" (
+	"id" char(24),
+	"date_time" timestamp with time zone,
+	"application" text,
+	"level" bigint,
+	"message" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_logs_date_time ON "logs"(date_time);
+CREATE INDEX idx_logs_application ON "logs"("application");
+
+CREATE TABLE "notifications" (
+	"id" char(24),
+	"date" timestamp with time zone,
+	"recipient" text,
+	"sender" text,
+	"message" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_notifications_recipient ON "notifications"("recipient");
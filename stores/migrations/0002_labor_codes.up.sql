@@ -0,0 +1,20 @@
+CREATE TABLE "labor_codes" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"charge_number" text,
+	"extension" text,
+	"clin" text,
+	"slin" text,
+	"location" text,
+	"wbs" text,
+	"minimum_employees" integer,
+	"not_assigned_name" text,
+	"hours_per_employee" numeric,
+	"exercise" boolean,
+	"start_date" timestamp with time zone,
+	"end_date" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_labor_codes_deleted_at ON "labor_codes"(deleted_at);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "specialty_types";
+DROP TABLE IF EXISTS "contact_types";
+DROP TABLE IF EXISTS "company_holidays";
+DROP TABLE IF EXISTS "companies";
+DROP TABLE IF EXISTS "workcodes";
+DROP TABLE IF EXISTS "teams";
@@ -0,0 +1,94 @@
+CREATE TABLE "teams" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"name" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_teams_deleted_at ON "teams"(deleted_at);
+
+CREATE TABLE "workcodes" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"code" text,
+	"team_id" integer,
+	"title" text,
+	"start_time" bigint,
+	"shift_code" text,
+	"alt_code" text,
+	"search" text,
+	"is_leave" boolean,
+	"text_color" text,
+	"back_color" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_workcodes_deleted_at ON "workcodes"(deleted_at);
+ALTER TABLE "workcodes" ADD CONSTRAINT workcodes_team_id_teams_id_foreign
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "companies" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"team_id" integer,
+	"code" text,
+	"name" text,
+	"ingest_type" text,
+	"ingest_period" integer,
+	"ingest_start_day" integer,
+	"ingest_pwd" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_companies_deleted_at ON "companies"(deleted_at);
+ALTER TABLE "companies" ADD CONSTRAINT companies_team_id_teams_id_foreign
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "company_holidays" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"company_id" integer,
+	"code" text,
+	"name" text,
+	"sort_id" integer,
+	"dates" text[],
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_company_holidays_deleted_at ON "company_holidays"(deleted_at);
+ALTER TABLE "company_holidays" ADD CONSTRAINT company_holidays_company_id_companies_id_foreign
+	FOREIGN KEY (company_id) REFERENCES companies(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "contact_types" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"team_id" integer,
+	"code" integer,
+	"name" text,
+	"sort_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_contact_types_deleted_at ON "contact_types"(deleted_at);
+ALTER TABLE "contact_types" ADD CONSTRAINT contact_types_team_id_teams_id_foreign
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "specialty_types" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"team_id" integer,
+	"code" integer,
+	"name" text,
+	"sort_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_specialty_types_deleted_at ON "specialty_types"(deleted_at);
+ALTER TABLE "specialty_types" ADD CONSTRAINT specialty_types_team_id_teams_id_foreign
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS "cofs_company_labor_codes";
+DROP TABLE IF EXISTS "cof_s_companies";
+DROP TABLE IF EXISTS "cof_s_reports";
+DROP TABLE IF EXISTS "forecast_periods";
+DROP TABLE IF EXISTS "forecast_report_labor_codes";
+DROP TABLE IF EXISTS "forecast_reports";
+DROP TABLE IF EXISTS "positions";
+DROP TABLE IF EXISTS "shifts";
+DROP TABLE IF EXISTS "workcenters";
+DROP TABLE IF EXISTS "site_labor_codes";
+DROP TABLE IF EXISTS "sites";
@@ -0,0 +1,159 @@
+CREATE TABLE "sites" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"team_id" integer,
+	"code" text,
+	"name" text,
+	"utc_offset" numeric,
+	"show_mids" boolean,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_sites_deleted_at ON "sites"(deleted_at);
+ALTER TABLE "sites" ADD CONSTRAINT sites_team_id_teams_id_foreign
+	FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "site_labor_codes" (
+	"site_id" integer,
+	"labor_code_id" integer,
+	PRIMARY KEY ("site_id", "labor_code_id")
+);
+ALTER TABLE "site_labor_codes" ADD CONSTRAINT site_labor_codes_site_id_sites_id_foreign
+	FOREIGN KEY (site_id) REFERENCES sites(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE "site_labor_codes" ADD CONSTRAINT site_labor_codes_labor_code_id_labor_codes_id_foreign
+	FOREIGN KEY (labor_code_id) REFERENCES labor_codes(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "workcenters" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"site_id" integer,
+	"code" text,
+	"name" text,
+	"sort_id" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_workcenters_deleted_at ON "workcenters"(deleted_at);
+ALTER TABLE "workcenters" ADD CONSTRAINT workcenters_site_id_sites_id_foreign
+	FOREIGN KEY (site_id) REFERENCES sites(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "shifts" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"workcenter_id" integer,
+	"code" text,
+	"name" text,
+	"sort_id" integer,
+	"associated_codes" text[],
+	"pay_code" integer,
+	"minimums" integer,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_shifts_deleted_at ON "shifts"(deleted_at);
+ALTER TABLE "shifts" ADD CONSTRAINT shifts_workcenter_id_workcenters_id_foreign
+	FOREIGN KEY (workcenter_id) REFERENCES workcenters(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "positions" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"workcenter_id" integer,
+	"code" text,
+	"name" text,
+	"sort_id" integer,
+	"assigned" text[],
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_positions_deleted_at ON "positions"(deleted_at);
+ALTER TABLE "positions" ADD CONSTRAINT positions_workcenter_id_workcenters_id_foreign
+	FOREIGN KEY (workcenter_id) REFERENCES workcenters(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "forecast_reports" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"site_id" integer,
+	"code" integer,
+	"name" text,
+	"start_date" timestamp with time zone,
+	"end_date" timestamp with time zone,
+	"company_id" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_forecast_reports_deleted_at ON "forecast_reports"(deleted_at);
+ALTER TABLE "forecast_reports" ADD CONSTRAINT forecast_reports_site_id_sites_id_foreign
+	FOREIGN KEY (site_id) REFERENCES sites(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "forecast_report_labor_codes" (
+	"forecast_report_id" integer,
+	"labor_code_id" integer,
+	PRIMARY KEY ("forecast_report_id", "labor_code_id")
+);
+ALTER TABLE "forecast_report_labor_codes" ADD CONSTRAINT forecast_report_labor_codes_forecast_report_id_forecast_reports_id_foreign
+	FOREIGN KEY (forecast_report_id) REFERENCES forecast_reports(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE "forecast_report_labor_codes" ADD CONSTRAINT forecast_report_labor_codes_labor_code_id_labor_codes_id_foreign
+	FOREIGN KEY (labor_code_id) REFERENCES labor_codes(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "forecast_periods" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"forecast_report_id" integer,
+	"month" timestamp with time zone,
+	"dates" text[],
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_forecast_periods_deleted_at ON "forecast_periods"(deleted_at);
+ALTER TABLE "forecast_periods" ADD CONSTRAINT forecast_periods_forecast_report_id_forecast_reports_id_foreign
+	FOREIGN KEY (forecast_report_id) REFERENCES forecast_reports(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "cof_s_reports" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"site_id" integer,
+	"code" integer,
+	"name" text,
+	"short_name" text,
+	"associated_unit" text,
+	"start_date" timestamp with time zone,
+	"end_date" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_cof_s_reports_deleted_at ON "cof_s_reports"(deleted_at);
+ALTER TABLE "cof_s_reports" ADD CONSTRAINT cof_s_reports_site_id_sites_id_foreign
+	FOREIGN KEY (site_id) REFERENCES sites(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "cof_s_companies" (
+	"id" serial,
+	"created_at" timestamp with time zone,
+	"updated_at" timestamp with time zone,
+	"deleted_at" timestamp with time zone,
+	"cof_s_report_id" integer,
+	"code" text,
+	"signature_block" text,
+	"sort_id" integer,
+	"add_exercises" boolean,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_cof_s_companies_deleted_at ON "cof_s_companies"(deleted_at);
+ALTER TABLE "cof_s_companies" ADD CONSTRAINT cof_s_companies_cof_s_report_id_cof_s_reports_id_foreign
+	FOREIGN KEY (cof_s_report_id) REFERENCES cof_s_reports(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "cofs_company_labor_codes" (
+	"cof_s_company_id" integer,
+	"labor_code_id" integer,
+	PRIMARY KEY ("cof_s_company_id", "labor_code_id")
+);
+ALTER TABLE "cofs_company_labor_codes" ADD CONSTRAINT cofs_company_labor_codes_cof_s_company_id_cof_s_companies_id_foreign
+	FOREIGN KEY (cof_s_company_id) REFERENCES cof_s_companies(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE "cofs_company_labor_codes" ADD CONSTRAINT cofs_company_labor_codes_labor_code_id_labor_codes_id_foreign
+	FOREIGN KEY (labor_code_id) REFERENCES labor_codes(id) ON DELETE CASCADE ON UPDATE CASCADE;
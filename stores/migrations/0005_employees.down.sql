@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "employee_leave_requests";
+DROP TABLE IF EXISTS "employee_leaves";
+DROP TABLE IF EXISTS "employee_balances";
+DROP TABLE IF EXISTS "employee_variations";
+DROP TABLE IF EXISTS "employee_assignments";
+DROP TABLE IF EXISTS "employees";
@@ -0,0 +1,103 @@
+CREATE TABLE "employees" (
+	"id" char(24),
+	"team_id" char(24),
+	"site_id" text,
+	"user_id" char(24),
+	"email" text,
+	"first_name" text,
+	"middle_name" text,
+	"last_name" text,
+	"suffix" text,
+	"company" text,
+	"company_employee_id" text,
+	"alternate_id" text,
+	"job_title" text,
+	"rank" text,
+	"cost_center" text,
+	"division" text,
+	"labor_codes" jsonb,
+	"contact_info" jsonb,
+	"specialties" jsonb,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_employees_team_id ON "employees"(team_id);
+CREATE INDEX idx_employees_site_id ON "employees"(site_id);
+CREATE INDEX idx_employees_user_id ON "employees"(user_id);
+
+CREATE TABLE "employee_assignments" (
+	"id" serial,
+	"employee_id" char(24),
+	"assignment_id" integer,
+	"site" text,
+	"workcenter" text,
+	"start_date" timestamp with time zone,
+	"end_date" timestamp with time zone,
+	"rotation_date" timestamp with time zone,
+	"rotation_days" integer,
+	"schedules" jsonb,
+	"labor_codes" jsonb,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_employee_assignments_employee_id ON "employee_assignments"(employee_id);
+ALTER TABLE "employee_assignments" ADD CONSTRAINT employee_assignments_employee_id_employees_id_foreign
+	FOREIGN KEY (employee_id) REFERENCES employees(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "employee_variations" (
+	"id" serial,
+	"employee_id" char(24),
+	"variation_id" integer,
+	"site" text,
+	"is_mids" boolean,
+	"start_date" timestamp with time zone,
+	"end_date" timestamp with time zone,
+	"schedule" jsonb,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_employee_variations_employee_id ON "employee_variations"(employee_id);
+ALTER TABLE "employee_variations" ADD CONSTRAINT employee_variations_employee_id_employees_id_foreign
+	FOREIGN KEY (employee_id) REFERENCES employees(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "employee_balances" (
+	"id" serial,
+	"employee_id" char(24),
+	"year" integer,
+	"annual" numeric,
+	"carryover" numeric,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_employee_balances_employee_id ON "employee_balances"(employee_id);
+ALTER TABLE "employee_balances" ADD CONSTRAINT employee_balances_employee_id_employees_id_foreign
+	FOREIGN KEY (employee_id) REFERENCES employees(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "employee_leaves" (
+	"id" serial,
+	"employee_id" char(24),
+	"leave_id" integer,
+	"leave_date" timestamp with time zone,
+	"code" text,
+	"hours" numeric,
+	"status" text,
+	"request_id" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_employee_leaves_employee_id ON "employee_leaves"(employee_id);
+ALTER TABLE "employee_leaves" ADD CONSTRAINT employee_leaves_employee_id_employees_id_foreign
+	FOREIGN KEY (employee_id) REFERENCES employees(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE TABLE "employee_leave_requests" (
+	"id" text,
+	"employee_id" char(24),
+	"request_date" timestamp with time zone,
+	"primary_code" text,
+	"start_date" timestamp with time zone,
+	"end_date" timestamp with time zone,
+	"status" text,
+	"approved_by" text,
+	"approval_date" timestamp with time zone,
+	"requested_days" jsonb,
+	"comments" jsonb,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_employee_leave_requests_employee_id ON "employee_leave_requests"(employee_id);
+ALTER TABLE "employee_leave_requests" ADD CONSTRAINT employee_leave_requests_employee_id_employees_id_foreign
+	FOREIGN KEY (employee_id) REFERENCES employees(id) ON DELETE CASCADE ON UPDATE CASCADE;
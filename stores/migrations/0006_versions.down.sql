@@ -0,0 +1,3 @@
+ALTER TABLE "teams" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "employees" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "version";
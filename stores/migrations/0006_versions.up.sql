@@ -0,0 +1,3 @@
+ALTER TABLE "users" ADD COLUMN "version" integer NOT NULL DEFAULT 0;
+ALTER TABLE "employees" ADD COLUMN "version" integer NOT NULL DEFAULT 0;
+ALTER TABLE "teams" ADD COLUMN "version" integer NOT NULL DEFAULT 0;
@@ -0,0 +1,25 @@
+CREATE TABLE "refresh_tokens" (
+	"id" char(24),
+	"user_id" char(24),
+	"family" char(24),
+	"token_hash" text,
+	"issued_at" timestamp with time zone,
+	"expires_at" timestamp with time zone,
+	"revoked_at" timestamp with time zone,
+	"replaced_by" text,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_refresh_tokens_user_id ON "refresh_tokens"(user_id);
+CREATE INDEX idx_refresh_tokens_family ON "refresh_tokens"(family);
+CREATE UNIQUE INDEX uix_refresh_tokens_token_hash ON "refresh_tokens"(token_hash);
+
+CREATE TABLE "revoked_tokens" (
+	"id" char(24),
+	"token_id" text,
+	"user_id" char(24),
+	"revoked_at" timestamp with time zone,
+	"expires_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_revoked_tokens_token_id ON "revoked_tokens"(token_id);
+CREATE INDEX idx_revoked_tokens_user_id ON "revoked_tokens"(user_id);
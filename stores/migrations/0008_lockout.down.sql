@@ -0,0 +1,2 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS "locked_until";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "last_bad_attempt";
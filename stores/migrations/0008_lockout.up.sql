@@ -0,0 +1,2 @@
+ALTER TABLE "users" ADD COLUMN "last_bad_attempt" timestamp with time zone;
+ALTER TABLE "users" ADD COLUMN "locked_until" timestamp with time zone;
@@ -0,0 +1,4 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS "mfa_recovery_codes";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "mfa_last_step";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "mfa_secret";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "mfa_enabled";
@@ -0,0 +1,4 @@
+ALTER TABLE "users" ADD COLUMN "mfa_enabled" boolean NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "mfa_secret" text;
+ALTER TABLE "users" ADD COLUMN "mfa_last_step" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN "mfa_recovery_codes" text[];
@@ -0,0 +1,4 @@
+ALTER TABLE "users" ADD COLUMN "roles" text[];
+UPDATE "users" SET "roles" = ARRAY(
+	SELECT lower(w) FROM unnest("workgroups") AS w WHERE w ~ '^[^-@]+-[^@]+$'
+) WHERE "workgroups" IS NOT NULL;
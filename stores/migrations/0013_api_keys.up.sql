@@ -0,0 +1,16 @@
+CREATE TABLE "api_keys" (
+	"id" char(24),
+	"name" text,
+	"application" text,
+	"roles" text[],
+	"prefix" text,
+	"key_hash" text,
+	"created_by" text,
+	"created_at" timestamp with time zone,
+	"expires_at" timestamp with time zone,
+	"last_used" timestamp with time zone,
+	"revoked_at" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_api_keys_application ON "api_keys"(application);
+CREATE UNIQUE INDEX uix_api_keys_key_hash ON "api_keys"(key_hash);
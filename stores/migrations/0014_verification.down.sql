@@ -0,0 +1,3 @@
+ALTER TABLE "users" DROP COLUMN IF EXISTS "verify_token_exp";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "verify_token";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "unverified";
@@ -0,0 +1,3 @@
+ALTER TABLE "users" ADD COLUMN "unverified" boolean NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "verify_token" text;
+ALTER TABLE "users" ADD COLUMN "verify_token_exp" timestamp with time zone;
@@ -0,0 +1,15 @@
+ALTER TABLE "users" ADD COLUMN "last_login" timestamp with time zone;
+
+CREATE TABLE "login_events" (
+	"id" char(24),
+	"user_id" text,
+	"email_address" text,
+	"type" text,
+	"detail" text,
+	"ip_address" text,
+	"user_agent" text,
+	"date_time" timestamp with time zone,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX idx_login_events_user_id ON "login_events"(user_id);
+CREATE INDEX idx_login_events_date_time ON "login_events"(date_time);
@@ -0,0 +1 @@
+ALTER TABLE "login_events" ADD COLUMN "actor_id" text;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_teams_object_id;
+ALTER TABLE "teams" DROP COLUMN IF EXISTS "object_id";
@@ -0,0 +1,2 @@
+ALTER TABLE "teams" ADD COLUMN "object_id" char(24);
+CREATE UNIQUE INDEX idx_teams_object_id ON "teams"(object_id);
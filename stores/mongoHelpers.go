@@ -0,0 +1,42 @@
+package stores
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mongoVersionFilter matches the document with the id only while it is still
+// at the version the caller read.  Documents saved before versioning have no
+// version field, which counts as version zero.
+func mongoVersionFilter(id primitive.ObjectID, version uint) bson.M {
+	if version == 0 {
+		return bson.M{
+			"_id": id,
+			"$or": bson.A{
+				bson.M{"version": 0},
+				bson.M{"version": bson.M{"$exists": false}},
+			},
+		}
+	}
+	return bson.M{
+		"_id":     id,
+		"version": version,
+	}
+}
+
+// mongoConflict explains a conditional update which matched nothing, either
+// because the document is gone or because its version has moved on.
+func mongoConflict(ctx context.Context, coll *mongo.Collection, kind string,
+	id primitive.ObjectID, version uint) error {
+	count, err := coll.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return &ConflictError{Kind: kind, ID: id.Hex(), Version: version}
+}
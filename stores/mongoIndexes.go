@@ -0,0 +1,27 @@
+package stores
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mongoIndexer is a mongo store which relies on indexes of its collections.
+type mongoIndexer interface {
+	EnsureIndexes(ctx context.Context) error
+}
+
+// EnsureMongoIndexes creates the indexes the mongo stores rely on, leaving
+// those which already exist in place.
+func EnsureMongoIndexes(ctx context.Context, client *mongo.Client) error {
+	indexers := []mongoIndexer{
+		NewMongoUserStore(client),
+		NewMongoTokenStore(client),
+	}
+	for _, indexer := range indexers {
+		if err := indexer.EnsureIndexes(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
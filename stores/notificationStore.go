@@ -0,0 +1,297 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/notifications"
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NotificationStore is the storage for the scheduler's notification messages.
+// Messages can't be updated, only created and removed once acknowledged.
+type NotificationStore interface {
+	CreateMessage(ctx context.Context, msg *notifications.Notification) error
+	GetMessage(ctx context.Context, id string) (*notifications.Notification, error)
+	GetAllMessages(ctx context.Context) ([]notifications.Notification, error)
+	GetMessagesByEmployee(ctx context.Context, id string) ([]notifications.Notification, error)
+	DeleteMessage(ctx context.Context, id string) error
+}
+
+// MongoNotificationStore keeps the messages in the scheduler database's
+// notifications collection.
+type MongoNotificationStore struct {
+	Client *mongo.Client
+}
+
+func NewMongoNotificationStore(client *mongo.Client) *MongoNotificationStore {
+	return &MongoNotificationStore{Client: client}
+}
+
+func (s *MongoNotificationStore) collection() *mongo.Collection {
+	return config.GetCollection(s.Client, "scheduler", "notifications")
+}
+
+func (s *MongoNotificationStore) CreateMessage(ctx context.Context,
+	msg *notifications.Notification) error {
+	if msg.ID.IsZero() {
+		msg.ID = primitive.NewObjectID()
+	}
+	result, err := s.collection().InsertOne(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if result.InsertedID == primitive.NilObjectID {
+		return errors.New("not created")
+	}
+	return nil
+}
+
+func (s *MongoNotificationStore) GetMessage(ctx context.Context,
+	id string) (*notifications.Notification, error) {
+	mid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"_id": mid,
+	}
+
+	var msg notifications.Notification
+	if err = s.collection().FindOne(ctx, filter).Decode(&msg); err != nil {
+		return nil, storeError(err)
+	}
+	return &msg, nil
+}
+
+func (s *MongoNotificationStore) find(ctx context.Context,
+	filter bson.M) ([]notifications.Notification, error) {
+	var list []notifications.Notification
+
+	cursor, err := s.collection().Find(ctx, filter)
+	if err != nil {
+		return list, err
+	}
+
+	if err = cursor.All(ctx, &list); err != nil {
+		return list, err
+	}
+
+	sort.Sort(notifications.ByNofication(list))
+	return list, nil
+}
+
+func (s *MongoNotificationStore) GetAllMessages(ctx context.Context) ([]notifications.Notification, error) {
+	return s.find(ctx, bson.M{})
+}
+
+func (s *MongoNotificationStore) GetMessagesByEmployee(ctx context.Context,
+	id string) ([]notifications.Notification, error) {
+	return s.find(ctx, bson.M{"to": id})
+}
+
+func (s *MongoNotificationStore) DeleteMessage(ctx context.Context, id string) error {
+	mid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id": mid,
+	}
+
+	result, err := s.collection().DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount <= 0 {
+		return errors.New("no message deleted")
+	}
+	return nil
+}
+
+// pgNotification is the postgres row for a notification.  The to and from
+// fields are stored as recipient and sender, since both are sql keywords.
+type pgNotification struct {
+	ID        string `gorm:"primary_key;type:char(24)"`
+	Date      time.Time
+	Recipient string `gorm:"index"`
+	Sender    string
+	Message   string
+}
+
+func (pgNotification) TableName() string {
+	return "notifications"
+}
+
+func toPgNotification(msg notifications.Notification) *pgNotification {
+	return &pgNotification{
+		ID:        msg.ID.Hex(),
+		Date:      msg.Date,
+		Recipient: msg.To,
+		Sender:    msg.From,
+		Message:   msg.Message,
+	}
+}
+
+func (n *pgNotification) toNotification() *notifications.Notification {
+	id, _ := primitive.ObjectIDFromHex(n.ID)
+	return &notifications.Notification{
+		ID:      id,
+		Date:    n.Date,
+		To:      n.Recipient,
+		From:    n.Sender,
+		Message: n.Message,
+	}
+}
+
+// PgNotificationStore keeps the messages in the postgres notifications table.
+type PgNotificationStore struct {
+	DB *gorm.DB
+}
+
+func NewPgNotificationStore(db *gorm.DB) *PgNotificationStore {
+	return &PgNotificationStore{DB: db}
+}
+
+func (s *PgNotificationStore) CreateMessage(ctx context.Context,
+	msg *notifications.Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if msg.ID.IsZero() {
+		msg.ID = primitive.NewObjectID()
+	}
+	return s.DB.Create(toPgNotification(*msg)).Error
+}
+
+func (s *PgNotificationStore) GetMessage(ctx context.Context,
+	id string) (*notifications.Notification, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var row pgNotification
+	if err := s.DB.Where("id = ?", id).First(&row).Error; err != nil {
+		return nil, storeError(err)
+	}
+	return row.toNotification(), nil
+}
+
+func (s *PgNotificationStore) find(ctx context.Context,
+	query *gorm.DB) ([]notifications.Notification, error) {
+	var list []notifications.Notification
+	if err := ctx.Err(); err != nil {
+		return list, err
+	}
+	var rows []pgNotification
+	if err := query.Find(&rows).Error; err != nil {
+		return list, err
+	}
+	for _, row := range rows {
+		list = append(list, *row.toNotification())
+	}
+	sort.Sort(notifications.ByNofication(list))
+	return list, nil
+}
+
+func (s *PgNotificationStore) GetAllMessages(ctx context.Context) ([]notifications.Notification, error) {
+	return s.find(ctx, s.DB)
+}
+
+func (s *PgNotificationStore) GetMessagesByEmployee(ctx context.Context,
+	id string) ([]notifications.Notification, error) {
+	return s.find(ctx, s.DB.Where("recipient = ?", id))
+}
+
+func (s *PgNotificationStore) DeleteMessage(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	result := s.DB.Where("id = ?", id).Delete(&pgNotification{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected <= 0 {
+		return errors.New("no message deleted")
+	}
+	return nil
+}
+
+// MemoryNotificationStore keeps the messages in memory.
+type MemoryNotificationStore struct {
+	mutex    sync.RWMutex
+	messages map[string]notifications.Notification
+}
+
+func NewMemoryNotificationStore() *MemoryNotificationStore {
+	return &MemoryNotificationStore{
+		messages: make(map[string]notifications.Notification),
+	}
+}
+
+func (s *MemoryNotificationStore) CreateMessage(ctx context.Context,
+	msg *notifications.Notification) error {
+	if msg.ID.IsZero() {
+		msg.ID = primitive.NewObjectID()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages[msg.ID.Hex()] = *msg
+	return nil
+}
+
+func (s *MemoryNotificationStore) GetMessage(ctx context.Context,
+	id string) (*notifications.Notification, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	msg, ok := s.messages[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &msg, nil
+}
+
+func (s *MemoryNotificationStore) find(
+	match func(msg notifications.Notification) bool) []notifications.Notification {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var list []notifications.Notification
+	for _, msg := range s.messages {
+		if match(msg) {
+			list = append(list, msg)
+		}
+	}
+	sort.Sort(notifications.ByNofication(list))
+	return list
+}
+
+func (s *MemoryNotificationStore) GetAllMessages(ctx context.Context) ([]notifications.Notification, error) {
+	return s.find(func(msg notifications.Notification) bool {
+		return true
+	}), nil
+}
+
+func (s *MemoryNotificationStore) GetMessagesByEmployee(ctx context.Context,
+	id string) ([]notifications.Notification, error) {
+	return s.find(func(msg notifications.Notification) bool {
+		return msg.To == id
+	}), nil
+}
+
+func (s *MemoryNotificationStore) DeleteMessage(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.messages[id]; !ok {
+		return errors.New("no message deleted")
+	}
+	delete(s.messages, id)
+	return nil
+}
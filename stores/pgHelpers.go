@@ -0,0 +1,93 @@
+package stores
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+// pgTransaction runs the function within a postgres transaction bound to the
+// context, committing when it returns without error and rolling back when it
+// doesn't or panics, the panic then carrying on.  When the connection is
+// already a transaction the function simply joins it.
+func pgTransaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := db.CommonDB().(*sql.Tx); ok {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(db)
+	}
+	tx := db.BeginTx(ctx, nil)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// pgPrune removes the child rows of the parent which are no longer part of the
+// parent's slice, given by the list of ids to keep.
+func pgPrune(tx *gorm.DB, model interface{}, foreignKey string, parent uint,
+	keep []uint) error {
+	query := tx.Unscoped().Where(foreignKey+" = ?", parent)
+	if len(keep) > 0 {
+		query = query.Where("id NOT IN (?)", keep)
+	}
+	return query.Delete(model).Error
+}
+
+// pgPreload adds the preload of each association, prefixed by the parent's
+// association name when given.
+func pgPreload(db *gorm.DB, prefix string, associations []string) *gorm.DB {
+	for _, assoc := range associations {
+		if prefix != "" {
+			assoc = prefix + "." + assoc
+		}
+		db = db.Preload(assoc)
+	}
+	return db
+}
+
+// pgReplace saves the row over the existing row with the same id, returning
+// ErrNotFound rather than inserting when there is no such row.
+func pgReplace(db *gorm.DB, row interface{}, id interface{}) error {
+	count := 0
+	if err := db.Model(row).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return db.Save(row).Error
+}
+
+// pgCheckVersion locks the row for the rest of the transaction and compares
+// its version with the one the caller read, returning ErrNotFound when the row
+// is gone and a ConflictError when it has been saved since.
+func pgCheckVersion(tx *gorm.DB, table, kind string, id interface{},
+	version uint) error {
+	var current uint
+	err := tx.Table(table).Set("gorm:query_option", "FOR UPDATE").
+		Select("version").Where("id = ?", id).Row().Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if current != version {
+		return &ConflictError{Kind: kind, ID: fmt.Sprint(id), Version: version}
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package stores
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// migrationFiles holds the numbered schema changes, each as a pair of files
+// named <version>_<name>.up.sql and <version>_<name>.down.sql.  New columns
+// and tables are added by a new pair with the next version number, never by
+// editing a version which may already have been applied.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLock is the postgres advisory lock key held while a migration is
+// applied or rolled back, so two processes can't change the schema at once.
+const migrationLock = 72616402
+
+// Migration is a single versioned change to the postgres schema, with the
+// statements to apply it and to roll it back.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied to the
+// database, and when.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type schemaMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations returns the schema migrations in version order.
+func Migrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		base := path.Base(file)
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: not an up or down file", base)
+		}
+		name := strings.TrimSuffix(base, "."+direction+".sql")
+		parts := strings.SplitN(name, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("migration %s: missing version", base)
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: bad version", base)
+		}
+		body, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = mig
+		} else if mig.Name != parts[1] {
+			return nil, fmt.Errorf("migration %d: named both %s and %s", version,
+				mig.Name, parts[1])
+		}
+		if direction == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	var answer []Migration
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: needs both up and down",
+				mig.Version, mig.Name)
+		}
+		answer = append(answer, *mig)
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[i].Version < answer[j].Version
+	})
+	return answer, nil
+}
+
+// MigratePostgres brings the postgres schema up to the latest migration.
+func MigratePostgres(ctx context.Context, db *gorm.DB) error {
+	_, err := MigrateUp(ctx, db, 0)
+	return err
+}
+
+// MigrateUp applies the pending migrations up to and including the target
+// version, or all of them when the target is zero, each within its own
+// transaction.  The migrations applied are returned.
+func MigrateUp(ctx context.Context, db *gorm.DB, target int) ([]Migration,
+	error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := createMigrationTable(db); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, mig := range migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		done := false
+		err := pgTransaction(ctx, db, func(tx *gorm.DB) error {
+			current, err := lockMigrations(tx)
+			if err != nil {
+				return err
+			}
+			if current[mig.Version] {
+				return nil
+			}
+			if err := tx.Exec(mig.Up).Error; err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name,
+					err)
+			}
+			done = true
+			return tx.Create(&schemaMigration{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		})
+		if err != nil {
+			return applied, err
+		}
+		if done {
+			applied = append(applied, mig)
+		}
+	}
+	return applied, nil
+}
+
+// MigrateDown rolls back the given number of the most recently applied
+// migrations, newest first, returning those rolled back.
+func MigrateDown(ctx context.Context, db *gorm.DB, steps int) ([]Migration,
+	error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := createMigrationTable(db); err != nil {
+		return nil, err
+	}
+
+	var rolledBack []Migration
+	for i := len(migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		mig := migrations[i]
+		done := false
+		err := pgTransaction(ctx, db, func(tx *gorm.DB) error {
+			current, err := lockMigrations(tx)
+			if err != nil {
+				return err
+			}
+			if !current[mig.Version] {
+				return nil
+			}
+			if err := tx.Exec(mig.Down).Error; err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name,
+					err)
+			}
+			done = true
+			return tx.Where("version = ?", mig.Version).
+				Delete(&schemaMigration{}).Error
+		})
+		if err != nil {
+			return rolledBack, err
+		}
+		if done {
+			rolledBack = append(rolledBack, mig)
+		}
+	}
+	return rolledBack, nil
+}
+
+// MarkMigrated records the migrations up to and including the version as
+// applied without running them.  It is for databases whose tables were
+// created before the schema was versioned.
+func MarkMigrated(ctx context.Context, db *gorm.DB, version int) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if err := createMigrationTable(db); err != nil {
+		return err
+	}
+	return pgTransaction(ctx, db, func(tx *gorm.DB) error {
+		current, err := lockMigrations(tx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range migrations {
+			if mig.Version > version || current[mig.Version] {
+				continue
+			}
+			err := tx.Create(&schemaMigration{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetMigrationStatus returns every known migration with whether it has been
+// applied.
+func GetMigrationStatus(ctx context.Context, db *gorm.DB) ([]MigrationStatus,
+	error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := createMigrationTable(db); err != nil {
+		return nil, err
+	}
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]time.Time)
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+
+	var answer []MigrationStatus
+	for _, mig := range migrations {
+		at, ok := applied[mig.Version]
+		answer = append(answer, MigrationStatus{
+			Migration: mig,
+			Applied:   ok,
+			AppliedAt: at,
+		})
+	}
+	return answer, nil
+}
+
+func createMigrationTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS "schema_migrations" (
+		"version" integer PRIMARY KEY,
+		"name" text NOT NULL,
+		"applied_at" timestamp with time zone NOT NULL
+	)`).Error
+}
+
+// lockMigrations takes the migration lock for the rest of the transaction and
+// returns the versions already applied.
+func lockMigrations(tx *gorm.DB) (map[int]bool, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)",
+		migrationLock).Error; err != nil {
+		return nil, err
+	}
+	var versions []int
+	if err := tx.Model(&schemaMigration{}).
+		Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+	answer := make(map[int]bool)
+	for _, version := range versions {
+		answer[version] = true
+	}
+	return answer, nil
+}
@@ -0,0 +1,228 @@
+package stores
+
+import (
+	"context"
+	"sort"
+
+	"github.com/erneap/go-pg-models/sites"
+	"github.com/jinzhu/gorm"
+)
+
+// the associations loaded with a site, from the workcenters down to the
+// report's labor codes.
+var siteAssociations = []string{
+	"Workcenters",
+	"Workcenters.Shifts",
+	"Workcenters.Positions",
+	"LaborCodes",
+	"ForecastReports",
+	"ForecastReports.Periods",
+	"ForecastReports.LaborCodes",
+	"CofSReports",
+	"CofSReports.Companies",
+	"CofSReports.Companies.LaborCodes",
+}
+
+// PgSiteStore provides the postgres CRUD functions for sites, which are saved
+// and retrieved along with their workcenters, shifts, positions and reports.
+type PgSiteStore struct {
+	DB *gorm.DB
+}
+
+func NewPgSiteStore(db *gorm.DB) *PgSiteStore {
+	return &PgSiteStore{DB: db}
+}
+
+func (s *PgSiteStore) CreateSite(ctx context.Context, site *sites.Site) error {
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		return pgSaveSite(tx, site)
+	})
+}
+
+func (s *PgSiteStore) GetSite(ctx context.Context, id uint) (*sites.Site, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var site sites.Site
+	err := pgPreload(s.DB, "", siteAssociations).First(&site, id).Error
+	if err != nil {
+		return nil, err
+	}
+	sortSite(&site)
+	return &site, nil
+}
+
+func (s *PgSiteStore) GetSiteByCode(ctx context.Context, teamID uint,
+	code string) (*sites.Site, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var site sites.Site
+	err := pgPreload(s.DB, "", siteAssociations).
+		Where("team_id = ? AND code = ?", teamID, code).First(&site).Error
+	if err != nil {
+		return nil, err
+	}
+	sortSite(&site)
+	return &site, nil
+}
+
+func (s *PgSiteStore) GetSites(ctx context.Context, teamID uint) ([]sites.Site, error) {
+	var list []sites.Site
+	if err := ctx.Err(); err != nil {
+		return list, err
+	}
+	err := pgPreload(s.DB, "", siteAssociations).
+		Where("team_id = ?", teamID).Find(&list).Error
+	if err != nil {
+		return list, err
+	}
+	for i := range list {
+		sortSite(&list[i])
+	}
+	sort.Sort(sites.BySites(list))
+	return list, nil
+}
+
+// UpdateSite saves the site and its children, removing any child rows which
+// were taken out of the site's slices.
+func (s *PgSiteStore) UpdateSite(ctx context.Context, site *sites.Site) error {
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		return pgSaveSite(tx, site)
+	})
+}
+
+func (s *PgSiteStore) DeleteSite(ctx context.Context, id uint) error {
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		return tx.Unscoped().Delete(&sites.Site{}, id).Error
+	})
+}
+
+// pgSaveSite saves the site and each of its child rows on their own.  Labor
+// codes are shared between sites and reports, so gorm is kept from writing
+// them through the associations; only the join rows are replaced.
+func pgSaveSite(tx *gorm.DB, site *sites.Site) error {
+	tx = tx.Set("gorm:association_autoupdate", false).
+		Set("gorm:association_autocreate", false)
+	if err := tx.Save(site).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(site).Association("LaborCodes").
+		Replace(site.LaborCodes).Error; err != nil {
+		return err
+	}
+
+	var keep []uint
+	for i := range site.Workcenters {
+		wc := &site.Workcenters[i]
+		wc.SiteID = site.ID
+		if err := tx.Save(wc).Error; err != nil {
+			return err
+		}
+		keep = append(keep, wc.ID)
+		var shifts []uint
+		for j := range wc.Shifts {
+			wc.Shifts[j].WorkcenterID = wc.ID
+			if err := tx.Save(&wc.Shifts[j]).Error; err != nil {
+				return err
+			}
+			shifts = append(shifts, wc.Shifts[j].ID)
+		}
+		if err := pgPrune(tx, &sites.Shift{}, "workcenter_id", wc.ID,
+			shifts); err != nil {
+			return err
+		}
+		var positions []uint
+		for j := range wc.Positions {
+			wc.Positions[j].WorkcenterID = wc.ID
+			if err := tx.Save(&wc.Positions[j]).Error; err != nil {
+				return err
+			}
+			positions = append(positions, wc.Positions[j].ID)
+		}
+		if err := pgPrune(tx, &sites.Position{}, "workcenter_id", wc.ID,
+			positions); err != nil {
+			return err
+		}
+	}
+	if err := pgPrune(tx, &sites.Workcenter{}, "site_id", site.ID,
+		keep); err != nil {
+		return err
+	}
+
+	keep = keep[:0]
+	for i := range site.ForecastReports {
+		rpt := &site.ForecastReports[i]
+		rpt.SiteID = site.ID
+		if err := tx.Save(rpt).Error; err != nil {
+			return err
+		}
+		keep = append(keep, rpt.ID)
+		var periods []uint
+		for j := range rpt.Periods {
+			rpt.Periods[j].ForecastReportID = rpt.ID
+			if err := tx.Save(&rpt.Periods[j]).Error; err != nil {
+				return err
+			}
+			periods = append(periods, rpt.Periods[j].ID)
+		}
+		if err := pgPrune(tx, &sites.ForecastPeriod{}, "forecast_report_id",
+			rpt.ID, periods); err != nil {
+			return err
+		}
+		if err := tx.Model(rpt).Association("LaborCodes").
+			Replace(rpt.LaborCodes).Error; err != nil {
+			return err
+		}
+	}
+	if err := pgPrune(tx, &sites.ForecastReport{}, "site_id", site.ID,
+		keep); err != nil {
+		return err
+	}
+
+	keep = keep[:0]
+	for i := range site.CofSReports {
+		rpt := &site.CofSReports[i]
+		rpt.SiteID = site.ID
+		if err := tx.Save(rpt).Error; err != nil {
+			return err
+		}
+		keep = append(keep, rpt.ID)
+		var companies []uint
+		for j := range rpt.Companies {
+			co := &rpt.Companies[j]
+			co.CofSReportID = rpt.ID
+			if err := tx.Save(co).Error; err != nil {
+				return err
+			}
+			companies = append(companies, co.ID)
+			if err := tx.Model(co).Association("LaborCodes").
+				Replace(co.LaborCodes).Error; err != nil {
+				return err
+			}
+		}
+		if err := pgPrune(tx, &sites.CofSCompany{}, "cof_s_report_id", rpt.ID,
+			companies); err != nil {
+			return err
+		}
+	}
+	return pgPrune(tx, &sites.CofSReport{}, "site_id", site.ID, keep)
+}
+
+// sortSite puts the site's children back into the order the models expect,
+// since postgres returns them in no particular order.
+func sortSite(site *sites.Site) {
+	sort.Sort(sites.ByWorkcenter(site.Workcenters))
+	for _, wc := range site.Workcenters {
+		sort.Sort(sites.ByShift(wc.Shifts))
+		sort.Sort(sites.ByPosition(wc.Positions))
+	}
+	sort.Sort(sites.ByForecastReport(site.ForecastReports))
+	for _, rpt := range site.ForecastReports {
+		sort.Sort(sites.ByForecastPeriod(rpt.Periods))
+	}
+	sort.Sort(sites.ByCofSReport(site.CofSReports))
+	for _, rpt := range site.CofSReports {
+		sort.Sort(sites.ByCofSCompany(rpt.Companies))
+	}
+}
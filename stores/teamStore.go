@@ -0,0 +1,165 @@
+package stores
+
+import (
+	"context"
+	"sort"
+
+	"github.com/erneap/go-pg-models/sites"
+	"github.com/erneap/go-pg-models/teams"
+	"github.com/jinzhu/gorm"
+)
+
+// PgTeamStore provides the postgres CRUD functions for teams, which are saved
+// and retrieved along with their workcodes, sites, companies and contact and
+// specialty types.
+type PgTeamStore struct {
+	DB *gorm.DB
+}
+
+func NewPgTeamStore(db *gorm.DB) *PgTeamStore {
+	return &PgTeamStore{DB: db}
+}
+
+func (s *PgTeamStore) preload() *gorm.DB {
+	db := s.DB.Preload("Workcodes").
+		Preload("Companies").
+		Preload("Companies.Holidays").
+		Preload("ContactTypes").
+		Preload("SpecialtyTypes").
+		Preload("Sites")
+	return pgPreload(db, "Sites", siteAssociations)
+}
+
+func (s *PgTeamStore) CreateTeam(ctx context.Context, team *teams.Team) error {
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		return pgSaveTeam(tx, team)
+	})
+}
+
+func (s *PgTeamStore) GetTeam(ctx context.Context, id uint) (*teams.Team, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var team teams.Team
+	if err := s.preload().First(&team, id).Error; err != nil {
+		return nil, err
+	}
+	sortTeam(&team)
+	return &team, nil
+}
+
+func (s *PgTeamStore) GetTeams(ctx context.Context) ([]teams.Team, error) {
+	var list []teams.Team
+	if err := ctx.Err(); err != nil {
+		return list, err
+	}
+	if err := s.preload().Find(&list).Error; err != nil {
+		return list, err
+	}
+	for i := range list {
+		sortTeam(&list[i])
+	}
+	sort.Sort(teams.ByTeam(list))
+	return list, nil
+}
+
+// UpdateTeam saves the team and all of its children, removing any child rows
+// which were taken out of the team's slices.  The team is only saved while
+// the stored version is the one it was read with, otherwise a ConflictError
+// is returned; on success the team's version is advanced.
+func (s *PgTeamStore) UpdateTeam(ctx context.Context, team *teams.Team) error {
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		err := pgCheckVersion(tx, "teams", "team", team.ID, team.Version)
+		if err != nil {
+			return err
+		}
+		team.Version++
+		if err := pgSaveTeam(tx, team); err != nil {
+			team.Version--
+			return err
+		}
+		return nil
+	})
+}
+
+func (s *PgTeamStore) DeleteTeam(ctx context.Context, id uint) error {
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		return tx.Unscoped().Delete(&teams.Team{}, id).Error
+	})
+}
+
+// pgSaveTeam saves the team with its own children, then each site through
+// pgSaveSite so the sites' shared labor codes are left alone.
+func pgSaveTeam(tx *gorm.DB, team *teams.Team) error {
+	if err := tx.Omit("Sites").Save(team).Error; err != nil {
+		return err
+	}
+
+	var keep []uint
+	for _, wc := range team.Workcodes {
+		keep = append(keep, wc.ID)
+	}
+	if err := pgPrune(tx, &teams.Workcode{}, "team_id", team.ID,
+		keep); err != nil {
+		return err
+	}
+
+	keep = keep[:0]
+	for _, ct := range team.ContactTypes {
+		keep = append(keep, ct.ID)
+	}
+	if err := pgPrune(tx, &teams.ContactType{}, "team_id", team.ID,
+		keep); err != nil {
+		return err
+	}
+
+	keep = keep[:0]
+	for _, st := range team.SpecialtyTypes {
+		keep = append(keep, st.ID)
+	}
+	if err := pgPrune(tx, &teams.SpecialtyType{}, "team_id", team.ID,
+		keep); err != nil {
+		return err
+	}
+
+	keep = keep[:0]
+	for _, co := range team.Companies {
+		keep = append(keep, co.ID)
+		var holidays []uint
+		for _, hol := range co.Holidays {
+			holidays = append(holidays, hol.ID)
+		}
+		if err := pgPrune(tx, &teams.CompanyHoliday{}, "company_id", co.ID,
+			holidays); err != nil {
+			return err
+		}
+	}
+	if err := pgPrune(tx, &teams.Company{}, "team_id", team.ID,
+		keep); err != nil {
+		return err
+	}
+
+	keep = keep[:0]
+	for i := range team.Sites {
+		team.Sites[i].TeamID = team.ID
+		if err := pgSaveSite(tx, &team.Sites[i]); err != nil {
+			return err
+		}
+		keep = append(keep, team.Sites[i].ID)
+	}
+	return pgPrune(tx, &sites.Site{}, "team_id", team.ID, keep)
+}
+
+func sortTeam(team *teams.Team) {
+	sort.Sort(teams.ByWorkcode(team.Workcodes))
+	sort.Sort(teams.ByCompany(team.Companies))
+	for _, co := range team.Companies {
+		sort.Sort(teams.ByCompanyHoliday(co.Holidays))
+	}
+	sort.Sort(teams.ByContactType(team.ContactTypes))
+	sort.Sort(teams.BySpecialtyType(team.SpecialtyTypes))
+	for i := range team.Sites {
+		sortSite(&team.Sites[i])
+	}
+	sort.Sort(sites.BySites(team.Sites))
+}
@@ -0,0 +1,453 @@
+package stores
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TokenStore is the storage for the refresh tokens and the access token
+// revocation list.  RevokeRefreshToken only revokes a token which is still
+// active, returning a ConflictError when another caller revoked it first, so
+// a refresh token can't be rotated twice.  RevokeRefreshTokens revokes the
+// user's active tokens in the family, or all of them when the family is blank.
+type TokenStore interface {
+	CreateRefreshToken(ctx context.Context, token *users.RefreshToken) error
+	GetRefreshToken(ctx context.Context, hash string) (*users.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, id, replacedBy string, at time.Time) error
+	RevokeRefreshTokens(ctx context.Context, userid, family string, at time.Time) error
+	RevokeToken(ctx context.Context, revoked *users.RevokedToken) error
+	IsTokenRevoked(ctx context.Context, tokenid, userid string, issued time.Time) (bool, error)
+	DeleteExpiredTokens(ctx context.Context, before time.Time) error
+}
+
+// MongoTokenStore keeps the tokens in the authenticate database's
+// refreshtokens and revokedtokens collections.
+type MongoTokenStore struct {
+	Client *mongo.Client
+}
+
+func NewMongoTokenStore(client *mongo.Client) *MongoTokenStore {
+	return &MongoTokenStore{Client: client}
+}
+
+func (s *MongoTokenStore) refreshTokens() *mongo.Collection {
+	return config.GetCollection(s.Client, "authenticate", "refreshtokens")
+}
+
+func (s *MongoTokenStore) revokedTokens() *mongo.Collection {
+	return config.GetCollection(s.Client, "authenticate", "revokedtokens")
+}
+
+// EnsureIndexes creates the indexes behind the lookup of a refresh token by
+// its hash and the revocation check made on every request, along with those
+// letting mongo remove the expired tokens itself.
+func (s *MongoTokenStore) EnsureIndexes(ctx context.Context) error {
+	expiring := options.Index().SetExpireAfterSeconds(0)
+	_, err := s.refreshTokens().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenhash", Value: 1}},
+			Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "family", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: expiring},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.revokedTokens().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "tokenid", Value: 1}}},
+		{Keys: bson.D{{Key: "tokenid", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: expiring},
+	})
+	return err
+}
+
+func (s *MongoTokenStore) CreateRefreshToken(ctx context.Context,
+	token *users.RefreshToken) error {
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	_, err := s.refreshTokens().InsertOne(ctx, token)
+	return err
+}
+
+func (s *MongoTokenStore) GetRefreshToken(ctx context.Context,
+	hash string) (*users.RefreshToken, error) {
+	filter := bson.M{
+		"tokenhash": hash,
+	}
+
+	var token users.RefreshToken
+	if err := s.refreshTokens().FindOne(ctx, filter).Decode(&token); err != nil {
+		return nil, storeError(err)
+	}
+	return &token, nil
+}
+
+func (s *MongoTokenStore) RevokeRefreshToken(ctx context.Context, id,
+	replacedBy string, at time.Time) error {
+	tokenid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{
+		"_id":       tokenid,
+		"revokedat": nil,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"revokedat":  at,
+			"replacedby": replacedBy,
+		},
+	}
+
+	result, err := s.refreshTokens().UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return &ConflictError{Kind: "refresh token", ID: id}
+	}
+	return nil
+}
+
+func (s *MongoTokenStore) RevokeRefreshTokens(ctx context.Context, userid,
+	family string, at time.Time) error {
+	filter := bson.M{
+		"userid":    userid,
+		"revokedat": nil,
+	}
+	if family != "" {
+		filter["family"] = family
+	}
+
+	_, err := s.refreshTokens().UpdateMany(ctx, filter,
+		bson.M{"$set": bson.M{"revokedat": at}})
+	return err
+}
+
+func (s *MongoTokenStore) RevokeToken(ctx context.Context,
+	revoked *users.RevokedToken) error {
+	if revoked.ID.IsZero() {
+		revoked.ID = primitive.NewObjectID()
+	}
+	_, err := s.revokedTokens().InsertOne(ctx, revoked)
+	return err
+}
+
+func (s *MongoTokenStore) IsTokenRevoked(ctx context.Context, tokenid,
+	userid string, issued time.Time) (bool, error) {
+	matches := bson.A{
+		bson.M{
+			"tokenid":   "",
+			"userid":    userid,
+			"revokedat": bson.M{"$gt": issued},
+		},
+	}
+	if tokenid != "" {
+		matches = append(matches, bson.M{"tokenid": tokenid})
+	}
+
+	count, err := s.revokedTokens().CountDocuments(ctx, bson.M{"$or": matches})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *MongoTokenStore) DeleteExpiredTokens(ctx context.Context,
+	before time.Time) error {
+	filter := bson.M{
+		"expiresat": bson.M{"$lt": before},
+	}
+	if _, err := s.refreshTokens().DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	_, err := s.revokedTokens().DeleteMany(ctx, filter)
+	return err
+}
+
+// pgRefreshToken is the postgres row for a refresh token.
+type pgRefreshToken struct {
+	ID         string `gorm:"primary_key;type:char(24)"`
+	UserID     string `gorm:"index"`
+	Family     string `gorm:"index"`
+	TokenHash  string `gorm:"unique_index"`
+	IssuedAt   time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy string
+}
+
+func (pgRefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+func toPgRefreshToken(token users.RefreshToken) *pgRefreshToken {
+	return &pgRefreshToken{
+		ID:         token.ID.Hex(),
+		UserID:     token.UserID,
+		Family:     token.Family,
+		TokenHash:  token.TokenHash,
+		IssuedAt:   token.IssuedAt,
+		ExpiresAt:  token.ExpiresAt,
+		RevokedAt:  token.RevokedAt,
+		ReplacedBy: token.ReplacedBy,
+	}
+}
+
+func (t *pgRefreshToken) toRefreshToken() *users.RefreshToken {
+	id, _ := primitive.ObjectIDFromHex(t.ID)
+	return &users.RefreshToken{
+		ID:         id,
+		UserID:     t.UserID,
+		Family:     t.Family,
+		TokenHash:  t.TokenHash,
+		IssuedAt:   t.IssuedAt,
+		ExpiresAt:  t.ExpiresAt,
+		RevokedAt:  t.RevokedAt,
+		ReplacedBy: t.ReplacedBy,
+	}
+}
+
+// pgRevokedToken is the postgres row for an access token revocation.
+type pgRevokedToken struct {
+	ID        string `gorm:"primary_key;type:char(24)"`
+	TokenID   string `gorm:"index"`
+	UserID    string `gorm:"index"`
+	RevokedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (pgRevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// PgTokenStore keeps the tokens in the postgres refresh_tokens and
+// revoked_tokens tables.
+type PgTokenStore struct {
+	DB *gorm.DB
+}
+
+func NewPgTokenStore(db *gorm.DB) *PgTokenStore {
+	return &PgTokenStore{DB: db}
+}
+
+func (s *PgTokenStore) CreateRefreshToken(ctx context.Context,
+	token *users.RefreshToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	return s.DB.Create(toPgRefreshToken(*token)).Error
+}
+
+func (s *PgTokenStore) GetRefreshToken(ctx context.Context,
+	hash string) (*users.RefreshToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var row pgRefreshToken
+	if err := s.DB.Where("token_hash = ?", hash).First(&row).Error; err != nil {
+		return nil, storeError(err)
+	}
+	return row.toRefreshToken(), nil
+}
+
+func (s *PgTokenStore) RevokeRefreshToken(ctx context.Context, id,
+	replacedBy string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	result := s.DB.Model(&pgRefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at":  at,
+			"replaced_by": replacedBy,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &ConflictError{Kind: "refresh token", ID: id}
+	}
+	return nil
+}
+
+func (s *PgTokenStore) RevokeRefreshTokens(ctx context.Context, userid,
+	family string, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	query := s.DB.Model(&pgRefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userid)
+	if family != "" {
+		query = query.Where("family = ?", family)
+	}
+	return query.Update("revoked_at", at).Error
+}
+
+func (s *PgTokenStore) RevokeToken(ctx context.Context,
+	revoked *users.RevokedToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if revoked.ID.IsZero() {
+		revoked.ID = primitive.NewObjectID()
+	}
+	return s.DB.Create(&pgRevokedToken{
+		ID:        revoked.ID.Hex(),
+		TokenID:   revoked.TokenID,
+		UserID:    revoked.UserID,
+		RevokedAt: revoked.RevokedAt,
+		ExpiresAt: revoked.ExpiresAt,
+	}).Error
+}
+
+func (s *PgTokenStore) IsTokenRevoked(ctx context.Context, tokenid,
+	userid string, issued time.Time) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	query := s.DB.Model(&pgRevokedToken{}).
+		Where("token_id = '' AND user_id = ? AND revoked_at > ?", userid, issued)
+	if tokenid != "" {
+		query = query.Or("token_id = ?", tokenid)
+	}
+	count := 0
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *PgTokenStore) DeleteExpiredTokens(ctx context.Context,
+	before time.Time) error {
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		err := tx.Where("expires_at < ?", before).
+			Delete(&pgRefreshToken{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("expires_at < ?", before).
+			Delete(&pgRevokedToken{}).Error
+	})
+}
+
+// MemoryTokenStore keeps the tokens in memory.
+type MemoryTokenStore struct {
+	mutex   sync.RWMutex
+	refresh map[string]users.RefreshToken
+	revoked []users.RevokedToken
+}
+
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{refresh: make(map[string]users.RefreshToken)}
+}
+
+func (s *MemoryTokenStore) CreateRefreshToken(ctx context.Context,
+	token *users.RefreshToken) error {
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refresh[token.ID.Hex()] = *token
+	return nil
+}
+
+func (s *MemoryTokenStore) GetRefreshToken(ctx context.Context,
+	hash string) (*users.RefreshToken, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, token := range s.refresh {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryTokenStore) RevokeRefreshToken(ctx context.Context, id,
+	replacedBy string, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	token, ok := s.refresh[id]
+	if !ok || token.RevokedAt != nil {
+		return &ConflictError{Kind: "refresh token", ID: id}
+	}
+	token.RevokedAt = &at
+	token.ReplacedBy = replacedBy
+	s.refresh[id] = token
+	return nil
+}
+
+func (s *MemoryTokenStore) RevokeRefreshTokens(ctx context.Context, userid,
+	family string, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, token := range s.refresh {
+		if token.UserID == userid && token.RevokedAt == nil &&
+			(family == "" || token.Family == family) {
+			revokedAt := at
+			token.RevokedAt = &revokedAt
+			s.refresh[id] = token
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTokenStore) RevokeToken(ctx context.Context,
+	revoked *users.RevokedToken) error {
+	if revoked.ID.IsZero() {
+		revoked.ID = primitive.NewObjectID()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.revoked = append(s.revoked, *revoked)
+	return nil
+}
+
+func (s *MemoryTokenStore) IsTokenRevoked(ctx context.Context, tokenid,
+	userid string, issued time.Time) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, revoked := range s.revoked {
+		if tokenid != "" && revoked.TokenID == tokenid {
+			return true, nil
+		}
+		if revoked.TokenID == "" && revoked.UserID == userid &&
+			revoked.RevokedAt.After(issued) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryTokenStore) DeleteExpiredTokens(ctx context.Context,
+	before time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, token := range s.refresh {
+		if token.ExpiresAt.Before(before) {
+			delete(s.refresh, id)
+		}
+	}
+	var kept []users.RevokedToken
+	for _, revoked := range s.revoked {
+		if !revoked.ExpiresAt.Before(before) {
+			kept = append(kept, revoked)
+		}
+	}
+	s.revoked = kept
+	return nil
+}
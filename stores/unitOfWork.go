@@ -0,0 +1,113 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Stores are the stores given to a unit of work's function, bound to its
+// transaction.
+type Stores struct {
+	Users         UserStore
+	Logs          LogStore
+	Notifications NotificationStore
+	Employees     EmployeeStore
+	Tokens        TokenStore
+	APIKeys       APIKeyStore
+	LoginEvents   LoginEventStore
+}
+
+// UnitOfWork runs a function's reads and writes as a single transaction.  The
+// writes are kept when the function returns nil and discarded when it returns
+// an error.  The function may be run more than once when the database asks
+// for the transaction to be retried, so it must not have effects outside of
+// the stores.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context, s Stores) error) error
+}
+
+// MongoUnitOfWork runs the function within a mongo session transaction.  The
+// mongo stores join the session through the context passed to the function.
+// Mongo only supports transactions on a replica set or sharded cluster.
+type MongoUnitOfWork struct {
+	Client *mongo.Client
+}
+
+func NewMongoUnitOfWork(client *mongo.Client) *MongoUnitOfWork {
+	return &MongoUnitOfWork{Client: client}
+}
+
+func (u *MongoUnitOfWork) stores() Stores {
+	return Stores{
+		Users:         NewMongoUserStore(u.Client),
+		Logs:          NewMongoLogStore(u.Client),
+		Notifications: NewMongoNotificationStore(u.Client),
+		Employees:     NewMongoEmployeeStore(u.Client),
+		Tokens:        NewMongoTokenStore(u.Client),
+		APIKeys:       NewMongoAPIKeyStore(u.Client),
+		LoginEvents:   NewMongoLoginEventStore(u.Client),
+	}
+}
+
+func (u *MongoUnitOfWork) Do(ctx context.Context,
+	fn func(ctx context.Context, s Stores) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx, u.stores())
+	}
+	session, err := u.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(ctx,
+		func(sc mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sc, u.stores())
+		})
+	return err
+}
+
+// PgUnitOfWork runs the function within a postgres transaction, giving it
+// postgres stores which write through the transaction.
+type PgUnitOfWork struct {
+	DB *gorm.DB
+}
+
+func NewPgUnitOfWork(db *gorm.DB) *PgUnitOfWork {
+	return &PgUnitOfWork{DB: db}
+}
+
+func (u *PgUnitOfWork) Do(ctx context.Context,
+	fn func(ctx context.Context, s Stores) error) error {
+	return pgTransaction(ctx, u.DB, func(tx *gorm.DB) error {
+		return fn(ctx, Stores{
+			Users:         NewPgUserStore(tx),
+			Logs:          NewPgLogStore(tx),
+			Notifications: NewPgNotificationStore(tx),
+			Employees:     NewPgEmployeeStore(tx),
+			Tokens:        NewPgTokenStore(tx),
+			APIKeys:       NewPgAPIKeyStore(tx),
+			LoginEvents:   NewPgLoginEventStore(tx),
+		})
+	})
+}
+
+// MemoryUnitOfWork gives the function the memory stores it was created with.
+// It has no rollback, so it only suits unit tests of a service's flow.
+type MemoryUnitOfWork struct {
+	Stores Stores
+}
+
+func NewMemoryUnitOfWork(s Stores) *MemoryUnitOfWork {
+	return &MemoryUnitOfWork{Stores: s}
+}
+
+func (u *MemoryUnitOfWork) Do(ctx context.Context,
+	fn func(ctx context.Context, s Stores) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(ctx, u.Stores)
+}
@@ -0,0 +1,467 @@
+package stores
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserStore is the storage for the authentication users.  CreateUser returns
+// ErrDuplicate when another user has the email address.  UpdateUser only
+// saves the user while the stored version is the one it was read with,
+// returning a ConflictError otherwise, and advances the user's version.
+type UserStore interface {
+	CreateUser(ctx context.Context, user *users.User) error
+	GetUserByID(ctx context.Context, id string) (*users.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*users.User, error)
+	GetUsers(ctx context.Context) ([]users.User, error)
+	UpdateUser(ctx context.Context, user *users.User) error
+	DeleteUser(ctx context.Context, id string) error
+}
+
+// MongoUserStore keeps the users in the authenticate database's users
+// collection.
+type MongoUserStore struct {
+	Client *mongo.Client
+}
+
+func NewMongoUserStore(client *mongo.Client) *MongoUserStore {
+	return &MongoUserStore{Client: client}
+}
+
+func (s *MongoUserStore) collection() *mongo.Collection {
+	return config.GetCollection(s.Client, "authenticate", "users")
+}
+
+// EnsureIndexes creates the unique index on the email address, so two
+// accounts can't be created for one address at the same time.
+func (s *MongoUserStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "emailAddress", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (s *MongoUserStore) CreateUser(ctx context.Context, user *users.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	_, err := s.collection().InsertOne(ctx, user)
+	return storeError(err)
+}
+
+func (s *MongoUserStore) GetUserByID(ctx context.Context, id string) (*users.User, error) {
+	userid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"_id": userid,
+	}
+
+	var user users.User
+	if err := s.collection().FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, storeError(err)
+	}
+	return &user, nil
+}
+
+func (s *MongoUserStore) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	filter := bson.M{
+		"emailAddress": email,
+	}
+
+	var user users.User
+	if err := s.collection().FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, storeError(err)
+	}
+	return &user, nil
+}
+
+func (s *MongoUserStore) GetUsers(ctx context.Context) ([]users.User, error) {
+	var list []users.User
+
+	cursor, err := s.collection().Find(ctx, bson.M{})
+	if err != nil {
+		return list, err
+	}
+
+	if err = cursor.All(ctx, &list); err != nil {
+		return list, err
+	}
+	return list, nil
+}
+
+func (s *MongoUserStore) UpdateUser(ctx context.Context, user *users.User) error {
+	filter := mongoVersionFilter(user.ID, user.Version)
+
+	updated := *user
+	updated.Version++
+	result, err := s.collection().ReplaceOne(ctx, filter, updated)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongoConflict(ctx, s.collection(), "user", user.ID,
+			user.Version)
+	}
+	user.Version = updated.Version
+	return nil
+}
+
+func (s *MongoUserStore) DeleteUser(ctx context.Context, id string) error {
+	userid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id": userid,
+	}
+
+	_, err = s.collection().DeleteOne(ctx, filter)
+	return err
+}
+
+// pgUser is the postgres row for a user, keyed by the user's object id in
+// hexadecimal.
+type pgUser struct {
+	ID               string `gorm:"primary_key;type:char(24)"`
+	EmailAddress     string `gorm:"unique_index"`
+	Password         string
+	PasswordExpires  time.Time
+	PasswordHistory  pq.StringArray `gorm:"type:text[]"`
+	BadAttempts      uint
+	LastBadAttempt   *time.Time
+	LockedUntil      *time.Time
+	FirstName        string
+	MiddleName       string
+	LastName         string
+	Workgroups       pq.StringArray `gorm:"type:text[]"`
+	Roles            pq.StringArray `gorm:"type:text[]"`
+	ResetToken       string
+	ResetTokenExp    *time.Time
+	Unverified       bool
+	VerifyToken      string
+	VerifyTokenExp   *time.Time
+	LastLogin        *time.Time
+	MFAEnabled       bool
+	MFASecret        string
+	MFALastStep      int64
+	MFARecoveryCodes pq.StringArray `gorm:"type:text[]"`
+	Profiles         *string        `gorm:"type:jsonb"`
+	Version          uint
+}
+
+func (pgUser) TableName() string {
+	return "users"
+}
+
+func toPgUser(user users.User) (*pgUser, error) {
+	profiles, err := pgProfiles(user.Profiles)
+	if err != nil {
+		return nil, err
+	}
+	return &pgUser{
+		ID:               user.ID.Hex(),
+		EmailAddress:     user.EmailAddress,
+		Password:         user.Password,
+		PasswordExpires:  user.PasswordExpires,
+		PasswordHistory:  pq.StringArray(user.PasswordHistory),
+		BadAttempts:      user.BadAttempts,
+		LastBadAttempt:   user.LastBadAttempt,
+		LockedUntil:      user.LockedUntil,
+		FirstName:        user.FirstName,
+		MiddleName:       user.MiddleName,
+		LastName:         user.LastName,
+		Workgroups:       pq.StringArray(user.Workgroups),
+		Roles:            pgRoles(user.Roles),
+		ResetToken:       user.ResetToken,
+		ResetTokenExp:    user.ResetTokenExp,
+		Unverified:       user.Unverified,
+		VerifyToken:      user.VerifyToken,
+		VerifyTokenExp:   user.VerifyTokenExp,
+		LastLogin:        user.LastLogin,
+		MFAEnabled:       user.MFAEnabled,
+		MFASecret:        user.MFASecret,
+		MFALastStep:      user.MFALastStep,
+		MFARecoveryCodes: pq.StringArray(user.MFARecoveryCodes),
+		Profiles:         profiles,
+		Version:          user.Version,
+	}, nil
+}
+
+func (u *pgUser) toUser() (*users.User, error) {
+	id, _ := primitive.ObjectIDFromHex(u.ID)
+	profiles, err := pgProfilesToUser(u.Profiles)
+	if err != nil {
+		return nil, err
+	}
+	return &users.User{
+		Identity: users.Identity{
+			ID:               id,
+			EmailAddress:     u.EmailAddress,
+			Password:         u.Password,
+			PasswordExpires:  u.PasswordExpires,
+			PasswordHistory:  []string(u.PasswordHistory),
+			BadAttempts:      u.BadAttempts,
+			LastBadAttempt:   u.LastBadAttempt,
+			LockedUntil:      u.LockedUntil,
+			FirstName:        u.FirstName,
+			MiddleName:       u.MiddleName,
+			LastName:         u.LastName,
+			ResetToken:       u.ResetToken,
+			ResetTokenExp:    u.ResetTokenExp,
+			Unverified:       u.Unverified,
+			VerifyToken:      u.VerifyToken,
+			VerifyTokenExp:   u.VerifyTokenExp,
+			LastLogin:        u.LastLogin,
+			MFAEnabled:       u.MFAEnabled,
+			MFASecret:        u.MFASecret,
+			MFALastStep:      u.MFALastStep,
+			MFARecoveryCodes: []string(u.MFARecoveryCodes),
+			Profiles:         profiles,
+			Version:          u.Version,
+		},
+		Workgroups: []string(u.Workgroups),
+		Roles:      pgRolesToUser(u.Roles),
+	}, nil
+}
+
+// pgProfiles stores the applications' profiles as canonical extended json,
+// which keeps their bson types, such as object ids, when read back.
+func pgProfiles(profiles map[string]bson.Raw) (*string, error) {
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+	data, err := bson.MarshalExtJSON(profiles, true, false)
+	if err != nil {
+		return nil, err
+	}
+	answer := string(data)
+	return &answer, nil
+}
+
+func pgProfilesToUser(value *string) (map[string]bson.Raw, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	var profiles map[string]bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(*value), true,
+		&profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// pgRoles stores the user's roles in their string form.
+func pgRoles(roles []users.Role) pq.StringArray {
+	var answer pq.StringArray
+	for _, role := range roles {
+		answer = append(answer, role.String())
+	}
+	return answer
+}
+
+func pgRolesToUser(values pq.StringArray) []users.Role {
+	roles, _ := users.ParseRoles([]string(values))
+	return roles
+}
+
+// PgUserStore keeps the users in the postgres users table.
+type PgUserStore struct {
+	DB *gorm.DB
+}
+
+func NewPgUserStore(db *gorm.DB) *PgUserStore {
+	return &PgUserStore{DB: db}
+}
+
+func (s *PgUserStore) CreateUser(ctx context.Context, user *users.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	row, err := toPgUser(*user)
+	if err != nil {
+		return err
+	}
+	return storeError(s.DB.Create(row).Error)
+}
+
+func (s *PgUserStore) GetUserByID(ctx context.Context, id string) (*users.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var row pgUser
+	if err := s.DB.Where("id = ?", id).First(&row).Error; err != nil {
+		return nil, storeError(err)
+	}
+	return row.toUser()
+}
+
+func (s *PgUserStore) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var row pgUser
+	if err := s.DB.Where("email_address = ?", email).First(&row).Error; err != nil {
+		return nil, storeError(err)
+	}
+	return row.toUser()
+}
+
+func (s *PgUserStore) GetUsers(ctx context.Context) ([]users.User, error) {
+	var list []users.User
+	if err := ctx.Err(); err != nil {
+		return list, err
+	}
+	var rows []pgUser
+	if err := s.DB.Find(&rows).Error; err != nil {
+		return list, err
+	}
+	for _, row := range rows {
+		user, err := row.toUser()
+		if err != nil {
+			return list, err
+		}
+		list = append(list, *user)
+	}
+	return list, nil
+}
+
+func (s *PgUserStore) UpdateUser(ctx context.Context, user *users.User) error {
+	return pgTransaction(ctx, s.DB, func(tx *gorm.DB) error {
+		err := pgCheckVersion(tx, "users", "user", user.ID.Hex(), user.Version)
+		if err != nil {
+			return err
+		}
+		row, err := toPgUser(*user)
+		if err != nil {
+			return err
+		}
+		row.Version++
+		if err := tx.Save(row).Error; err != nil {
+			return err
+		}
+		user.Version = row.Version
+		return nil
+	})
+}
+
+func (s *PgUserStore) DeleteUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.DB.Where("id = ?", id).Delete(&pgUser{}).Error
+}
+
+// MemoryUserStore keeps the users in memory, for unit tests and applications
+// without a database.
+type MemoryUserStore struct {
+	mutex sync.RWMutex
+	users map[string]users.User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[string]users.User)}
+}
+
+func copyUser(user users.User) users.User {
+	user.Workgroups = append([]string(nil), user.Workgroups...)
+	user.PasswordHistory = append([]string(nil), user.PasswordHistory...)
+	user.MFARecoveryCodes = append([]string(nil), user.MFARecoveryCodes...)
+	user.Roles = append([]users.Role(nil), user.Roles...)
+	if user.Profiles != nil {
+		profiles := make(map[string]bson.Raw, len(user.Profiles))
+		for app, raw := range user.Profiles {
+			profiles[app] = raw
+		}
+		user.Profiles = profiles
+	}
+	return user
+}
+
+func (s *MemoryUserStore) CreateUser(ctx context.Context, user *users.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, current := range s.users {
+		if current.EmailAddress == user.EmailAddress {
+			return ErrDuplicate
+		}
+	}
+	s.users[user.ID.Hex()] = copyUser(*user)
+	return nil
+}
+
+func (s *MemoryUserStore) GetUserByID(ctx context.Context, id string) (*users.User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user = copyUser(user)
+	return &user, nil
+}
+
+func (s *MemoryUserStore) GetUserByEmail(ctx context.Context, email string) (*users.User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, user := range s.users {
+		if user.EmailAddress == email {
+			user = copyUser(user)
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryUserStore) GetUsers(ctx context.Context) ([]users.User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var list []users.User
+	for _, user := range s.users {
+		list = append(list, copyUser(user))
+	}
+	return list, nil
+}
+
+func (s *MemoryUserStore) UpdateUser(ctx context.Context, user *users.User) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	current, ok := s.users[user.ID.Hex()]
+	if !ok {
+		return ErrNotFound
+	}
+	if current.Version != user.Version {
+		return &ConflictError{Kind: "user", ID: user.ID.Hex(),
+			Version: user.Version}
+	}
+	user.Version++
+	s.users[user.ID.Hex()] = copyUser(*user)
+	return nil
+}
+
+func (s *MemoryUserStore) DeleteUser(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.users, id)
+	return nil
+}
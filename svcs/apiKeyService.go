@@ -0,0 +1,137 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erneap/go-pg-models/logs"
+	"github.com/erneap/go-pg-models/users"
+)
+
+// API key functions.  An api key lets a batch job or another service call the
+// applications without logging in as a user.  The key is only shown when it
+// is created, and only its hash is kept.
+
+// apiKeyPrefix starts each api key, so a leaked key is easily recognized.
+const apiKeyPrefix = "ak_"
+
+// apiKeyTouchInterval is how often a key's last use is saved, so a busy job
+// doesn't write on every request.
+const apiKeyTouchInterval = time.Minute
+
+var ErrInvalidAPIKey = errors.New("invalid, expired or revoked api key")
+
+// CreateAPIKey issues a key named name for the application holding the
+// roles, which must be the application's, returning the key and its record.
+// A key has no employee record to give its unscoped roles a home team, so
+// each role must be scoped to a team, or to users.AnyTeam, for the key to
+// pass AuthorizeScope and CheckScope.  The key expires after the ttl, or the
+// configured api key lifetime when the ttl isn't positive, and never when
+// that is zero too.
+func CreateAPIKey(ctx context.Context, app, name string, roles []users.Role,
+	ttl time.Duration, by string) (string, *users.APIKey, error) {
+	for _, role := range roles {
+		if !strings.EqualFold(role.Application, app) {
+			return "", nil, fmt.Errorf("role %s is not a role of %s", role,
+				app)
+		}
+		if role.TeamID == "" {
+			return "", nil, fmt.Errorf("role %s of an api key needs a team, "+
+				"such as %s@%s", role, role, users.AnyTeam)
+		}
+	}
+	store, err := getAPIKeyStore(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	secret, _, err := newSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	value := apiKeyPrefix + secret
+	now := time.Now().UTC()
+	key := &users.APIKey{
+		Name:        name,
+		Application: strings.ToLower(app),
+		Roles:       roles,
+		Prefix:      value[:len(apiKeyPrefix)+6],
+		KeyHash:     hashSecret(value),
+		CreatedBy:   by,
+		CreatedAt:   now,
+	}
+	if ttl <= 0 {
+		ttl = getSettings().APIKeyTTL
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+	if err := store.CreateAPIKey(ctx, key); err != nil {
+		return "", nil, err
+	}
+	addAuditEntry(ctx, "API Key", by, "created "+key.Name+" ("+key.Prefix+") for "+
+		key.Application)
+	return value, key, nil
+}
+
+// ValidateAPIKey returns the record of the key when it is neither expired
+// nor revoked, recording its use.
+func ValidateAPIKey(ctx context.Context, value string) (*users.APIKey, error) {
+	store, err := getAPIKeyStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	key, err := store.GetAPIKeyByHash(ctx, hashSecret(strings.TrimSpace(value)))
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now().UTC()
+	if key.IsRevoked() || key.IsExpired(now) {
+		return nil, ErrInvalidAPIKey
+	}
+	if key.LastUsed == nil || now.Sub(*key.LastUsed) >= apiKeyTouchInterval {
+		if err := store.TouchAPIKey(ctx, key.ID.Hex(), now); err != nil {
+			AddLogEntry(ctx, key.Application, logs.Minimal,
+				"ValidateAPIKey: Last use not saved: "+err.Error())
+		} else {
+			key.LastUsed = &now
+		}
+	}
+	return key, nil
+}
+
+// GetAPIKeys lists the application's keys, or all of them when the
+// application is blank.
+func GetAPIKeys(ctx context.Context, app string) ([]users.APIKey, error) {
+	store, err := getAPIKeyStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetAPIKeys(ctx, app)
+}
+
+// RevokeAPIKey revokes the key, recording the administrator who revoked it.
+func RevokeAPIKey(ctx context.Context, id, by string) error {
+	store, err := getAPIKeyStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if err := store.RevokeAPIKey(ctx, id, time.Now().UTC()); err != nil {
+		return err
+	}
+	addAuditEntry(ctx, "API Key", by, "revoked "+id)
+	return nil
+}
@@ -0,0 +1,91 @@
+package svcs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erneap/go-pg-models/users"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateAPIKeyRoles(t *testing.T) {
+	useTestServices(t)
+	tests := []struct {
+		name    string
+		role    string
+		wantErr bool
+	}{
+		{"unscoped role", "scheduler-scheduler", true},
+		{"role of another application", "soap-admin@*", true},
+		{"team scoped role", "scheduler-scheduler@dcgs", false},
+		{"cross-team role", "scheduler-scheduler@*", false},
+	}
+	for _, tt := range tests {
+		role, err := users.ParseRole(tt.role)
+		if err != nil {
+			t.Fatalf("ParseRole(%q): %v", tt.role, err)
+		}
+		_, _, err = CreateAPIKey(context.Background(), "scheduler", tt.name,
+			[]users.Role{role}, 0, "admin@example.com")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: CreateAPIKey error = %v, wantErr %v", tt.name, err,
+				tt.wantErr)
+		}
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	useTestServices(t)
+	ctx := context.Background()
+	role, _ := users.ParseRole("scheduler-scheduler@*")
+	value, _, err := CreateAPIKey(ctx, "scheduler", "nightly",
+		[]users.Role{role}, 0, "admin@example.com")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	revoked, record, err := CreateAPIKey(ctx, "scheduler", "revoked",
+		[]users.Role{role}, 0, "admin@example.com")
+	if err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if err := RevokeAPIKey(ctx, record.ID.Hex(), "admin@example.com"); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router := gin.New()
+	router.GET("/scheduler", Authenticate("scheduler"), ok)
+	router.GET("/journal", Authenticate("soap"), ok)
+	router.GET("/both", Authenticate("scheduler"), Authorize("soap"), ok)
+	router.GET("/team/:team", CheckScope("scheduler", users.PermEditSchedule,
+		ScopeFromParams("team", "")), ok)
+
+	tests := []struct {
+		name string
+		path string
+		key  string
+		want int
+	}{
+		{"key for the application", "/scheduler", value, http.StatusOK},
+		{"key for another application", "/journal", value,
+			http.StatusUnauthorized},
+		{"cached key checked again for another application", "/both", value,
+			http.StatusUnauthorized},
+		{"revoked key", "/scheduler", revoked, http.StatusUnauthorized},
+		{"unknown key", "/scheduler", apiKeyPrefix + "unknown",
+			http.StatusUnauthorized},
+		{"cross-team key passes CheckScope", "/team/dcgs", value,
+			http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("X-API-Key", tt.key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package svcs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/erneap/go-pg-models/logs"
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/gin-gonic/gin"
+)
+
+// Authentication middleware.  Authenticate validates the request's access
+// token or api key once, caching its claims or key and the user in the gin
+// context for the authorizers and handlers after it, which read them with
+// GetClaims, GetAPIKey and GetUser.  For an impersonation token the user is
+// the one impersonated, and GetImpersonation also gives the administrator.
+
+// the gin context keys of the cached claims, api key and user.
+const (
+	claimsKey = "svcs.claims"
+	apiKeyKey = "svcs.apikey"
+	userKey   = "svcs.user"
+)
+
+var (
+	ErrNoToken    = errors.New("request does not contain an access token")
+	ErrNotInGroup = errors.New("user not in group")
+
+	// errBadResource marks an authorizer's failure to read the resource
+	// from the request.
+	errBadResource = errors.New("bad resource")
+)
+
+// bearerToken returns the access token of the request's Authorization
+// header, which is given with the Bearer scheme or, by older clients, alone.
+func bearerToken(c *gin.Context) string {
+	header := strings.TrimSpace(c.GetHeader("Authorization"))
+	scheme, token, ok := strings.Cut(header, " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return header
+}
+
+// requestAPIKey returns the api key given in the X-API-Key header or with the
+// ApiKey scheme of the Authorization header.
+func requestAPIKey(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader("X-API-Key")); key != "" {
+		return key
+	}
+	header := strings.TrimSpace(c.GetHeader("Authorization"))
+	scheme, key, ok := strings.Cut(header, " ")
+	if ok && strings.EqualFold(scheme, "ApiKey") {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
+
+// GetClaims returns the access token claims cached by Authenticate.
+func GetClaims(c *gin.Context) (*users.JWTClaim, bool) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*users.JWTClaim)
+	return claims, ok
+}
+
+// GetAPIKey returns the api key cached by Authenticate.
+func GetAPIKey(c *gin.Context) (*users.APIKey, bool) {
+	value, ok := c.Get(apiKeyKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := value.(*users.APIKey)
+	return key, ok
+}
+
+// GetUser returns the authenticated user cached by Authenticate, which for a
+// request made with an api key is the key's user.
+func GetUser(c *gin.Context) (*users.User, bool) {
+	value, ok := c.Get(userKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := value.(*users.User)
+	return user, ok
+}
+
+// Authenticate validates the request's access token and reads its user, or
+// validates its api key for the application, caching them in the context, or
+// aborts the request.  A request already authenticated by an earlier handler
+// isn't validated again.
+func Authenticate(app string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authenticate(c, app) {
+			c.Next()
+		}
+	}
+}
+
+// authenticate does the work of Authenticate, reporting whether the request
+// may go on.  A request already authenticated is still checked against the
+// application, as an api key or impersonation token only acts in its own.
+func authenticate(c *gin.Context, app string) bool {
+	ctx := c.Request.Context()
+	if _, ok := GetUser(c); ok {
+		return authenticatedFor(c, app)
+	}
+	if value := requestAPIKey(c); value != "" {
+		return authenticateAPIKey(c, app, value)
+	}
+	tokenString := bearerToken(c)
+	if tokenString == "" {
+		AddLogEntry(ctx, app, logs.Minimal,
+			"Authenticate: No Authentication Token passed")
+		c.AbortWithStatusJSON(http.StatusUnauthorized,
+			gin.H{"error": ErrNoToken.Error()})
+		return false
+	}
+	claims, err := ValidateToken(ctx, tokenString)
+	if err != nil {
+		AddLogEntry(ctx, app, logs.Minimal, "Authenticate: Validation Error: "+
+			err.Error())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return false
+	}
+	user, err := GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		AddLogEntry(ctx, app, logs.Minimal, "Authenticate: User Not Found: "+
+			err.Error())
+		c.AbortWithStatusJSON(http.StatusNotFound,
+			gin.H{"error": "user not found: " + err.Error()})
+		return false
+	}
+	if claims.IsImpersonation() {
+		actor, err := impersonationActor(ctx, app, claims, user)
+		if err != nil {
+			AddLogEntry(ctx, app, logs.Minimal,
+				"Authenticate: Impersonation Error: "+err.Error())
+			c.AbortWithStatusJSON(http.StatusUnauthorized,
+				gin.H{"error": err.Error()})
+			return false
+		}
+		imp := &Impersonation{Actor: actor, User: user}
+		c.Set(impersonationKey, imp)
+		c.Request = c.Request.WithContext(withImpersonation(ctx, imp))
+	}
+	c.Set(claimsKey, claims)
+	c.Set(userKey, user)
+	AddLogEntry(ctx, app, logs.Debug, "Authenticate: Token Verified")
+	return true
+}
+
+// authenticatedFor reports whether the cached api key or impersonation token
+// of a request already authenticated acts in the application, aborting the
+// request when it doesn't.
+func authenticatedFor(c *gin.Context, app string) bool {
+	ctx := c.Request.Context()
+	if key, ok := GetAPIKey(c); ok && !strings.EqualFold(key.Application, app) {
+		AddLogEntry(ctx, app, logs.Minimal, "Authenticate: API Key "+key.Prefix+
+			" is for "+key.Application)
+		c.AbortWithStatusJSON(http.StatusUnauthorized,
+			gin.H{"error": ErrInvalidAPIKey.Error()})
+		return false
+	}
+	if claims, ok := GetClaims(c); ok && claims.IsImpersonation() &&
+		!strings.EqualFold(claims.Audience, app) {
+		err := fmt.Errorf("%w: impersonation token is for %s", ErrForbidden,
+			claims.Audience)
+		AddLogEntry(ctx, app, logs.Minimal,
+			"Authenticate: Impersonation Error: "+err.Error())
+		c.AbortWithStatusJSON(http.StatusUnauthorized,
+			gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// authenticateAPIKey does the work of authenticate for a request made with an
+// api key.
+func authenticateAPIKey(c *gin.Context, app, value string) bool {
+	ctx := c.Request.Context()
+	key, err := ValidateAPIKey(ctx, value)
+	if err != nil {
+		AddLogEntry(ctx, app, logs.Minimal, "Authenticate: API Key Error: "+
+			err.Error())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return false
+	}
+	if !strings.EqualFold(key.Application, app) {
+		AddLogEntry(ctx, app, logs.Minimal, "Authenticate: API Key "+key.Prefix+
+			" is for "+key.Application)
+		c.AbortWithStatusJSON(http.StatusUnauthorized,
+			gin.H{"error": ErrInvalidAPIKey.Error()})
+		return false
+	}
+	c.Set(apiKeyKey, key)
+	c.Set(userKey, key.User())
+	AddLogEntry(ctx, app, logs.Debug, "Authenticate: API Key Verified: "+
+		key.Name)
+	return true
+}
+
+// Authorizer checks that the authenticated user may make the request,
+// returning ErrNotInGroup or ErrForbidden when not.
+type Authorizer func(c *gin.Context, user *users.User) error
+
+// Authorize authenticates the request, when no earlier handler did, and lets
+// it through when all the authorizers do.
+func Authorize(app string, authorizers ...Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticate(c, app) {
+			return
+		}
+		user, _ := GetUser(c)
+		for _, authorize := range authorizers {
+			if err := authorize(c, user); err != nil {
+				AddLogEntry(c.Request.Context(), app, logs.Minimal,
+					"Authorize: "+err.Error()+": "+user.LastName)
+				c.AbortWithStatusJSON(authorizationStatus(err),
+					gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// authorizationStatus returns the http status for the authorizer's error.
+func authorizationStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotInGroup):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, stores.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errBadResource):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// RequireRole lets through a user holding the role in the application at any
+// scope.
+func RequireRole(app, role string) Authorizer {
+	return func(c *gin.Context, user *users.User) error {
+		if !user.HasRoleAnywhere(app, role) {
+			return ErrNotInGroup
+		}
+		return nil
+	}
+}
+
+// RequireAnyRole lets through a user holding any of the roles in the
+// application.
+func RequireAnyRole(app string, roles ...string) Authorizer {
+	authorizers := make([]Authorizer, len(roles))
+	for i, role := range roles {
+		authorizers[i] = RequireRole(app, role)
+	}
+	return AnyOf(authorizers...)
+}
+
+// RequireRoleList lets through a user holding any of the roles given in their
+// string form.  A role with a scope must be held over that scope, and
+// malformed roles are logged and skipped.
+func RequireRoleList(app string, roles []string) Authorizer {
+	return func(c *gin.Context, user *users.User) error {
+		for _, value := range roles {
+			role, err := users.ParseRole(value)
+			if err != nil {
+				AddLogEntry(c.Request.Context(), app, logs.Minimal,
+					"RequireRoleList: "+err.Error())
+				continue
+			}
+			if role.TeamID == "" {
+				if user.HasRoleAnywhere(role.Application, role.Name) {
+					return nil
+				}
+			} else if user.HasRole(role.Application, role.Name, role.Scope) {
+				return nil
+			}
+		}
+		return ErrNotInGroup
+	}
+}
+
+// RequireScope lets through a user with the application's permission over the
+// team and site the resolver gives for the resource, through AuthorizeScope.
+func RequireScope(app, permission string, resolve ScopeResolver) Authorizer {
+	return func(c *gin.Context, user *users.User) error {
+		resource, err := resolve(c)
+		if errors.Is(err, stores.ErrNotFound) {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("%w: %v", errBadResource, err)
+		}
+		return AuthorizeScope(c.Request.Context(), user, app, permission,
+			resource)
+	}
+}
+
+// AnyOf lets through a user any of the authorizers lets through, returning
+// the last failure otherwise.
+func AnyOf(authorizers ...Authorizer) Authorizer {
+	return func(c *gin.Context, user *users.User) error {
+		err := ErrNotInGroup
+		for _, authorize := range authorizers {
+			if err = authorize(c, user); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// AllOf lets through a user all of the authorizers let through.
+func AllOf(authorizers ...Authorizer) Authorizer {
+	return func(c *gin.Context, user *users.User) error {
+		for _, authorize := range authorizers {
+			if err := authorize(c, user); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,245 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/users"
+)
+
+// Login functions.  A login is a password check, followed for a user with
+// multi-factor authentication by a code from the user's authenticator app or
+// a recovery code, and only then are the user's tokens issued.
+
+// mfaPurpose is the purpose of the token given between the two login steps.
+const mfaPurpose = "mfa"
+
+var (
+	ErrLoginFailed    = users.ErrPasswordMismatch
+	ErrInvalidMFACode = errors.New("invalid authentication code")
+)
+
+// mfaAttempts limits the failed codes for a user's multi-factor challenge.
+var mfaAttempts = newRateLimiter()
+
+// Login checks the request's email address and password, saving the user's
+// failed login count.  A user without multi-factor authentication is given
+// the access and refresh tokens, while one with it is given the challenge
+// token to send back with the code to VerifyMFA.
+func Login(ctx context.Context,
+	req users.AuthenticationRequest) (*users.AuthenticationResponse, error) {
+	var user *users.User
+	var authErr error
+	err := RetryOnConflict(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = GetUserByEMail(ctx, req.EmailAddress)
+		if err != nil {
+			return err
+		}
+		authErr = user.Authenticate(req.Password)
+		return UpdateUser(ctx, user)
+	})
+	if errors.Is(err, stores.ErrNotFound) {
+		addAuditEntry(ctx, "Login", req.EmailAddress, "unknown address")
+		recordLoginEvent(ctx, "", req.EmailAddress, users.LoginFailure,
+			"unknown address")
+		return nil, ErrLoginFailed
+	} else if err != nil {
+		return nil, err
+	}
+	if authErr != nil {
+		addAuditEntry(ctx, "Login", user.EmailAddress, authErr.Error())
+		eventType := users.LoginFailure
+		if errors.Is(authErr, users.ErrAccountLocked) {
+			eventType = users.LoginLockout
+		}
+		recordLoginEvent(ctx, user.ID.Hex(), user.EmailAddress, eventType,
+			authErr.Error())
+		return nil, authErr
+	}
+	if user.Unverified {
+		addAuditEntry(ctx, "Login", user.EmailAddress, "email not verified")
+		recordLoginEvent(ctx, user.ID.Hex(), user.EmailAddress,
+			users.LoginFailure, "email not verified")
+		return nil, ErrEmailNotVerified
+	}
+
+	if user.MFAEnabled {
+		challenge, err := signToken(user.ID, user.EmailAddress, mfaPurpose,
+			getSettings().MFAChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &users.AuthenticationResponse{
+			MFARequired: true,
+			MFAToken:    challenge,
+		}, nil
+	}
+	return completeLogin(ctx, user)
+}
+
+// VerifyMFA completes the login begun by Login when the request's code is
+// the user's current authenticator code or one of the recovery codes.
+func VerifyMFA(ctx context.Context,
+	req users.MFARequest) (*users.AuthenticationResponse, error) {
+	claims, err := parseToken(req.Token, mfaPurpose)
+	if err != nil {
+		return nil, err
+	}
+	cfg := getSettings()
+	if !mfaAttempts.allow(claims.UserID, cfg.MFAAttemptLimit,
+		cfg.MFAChallengeTTL) {
+		addAuditEntry(ctx, "Login", claims.EmailAddress, "mfa rate limited")
+		return nil, ErrTooManyRequests
+	}
+
+	var user *users.User
+	valid := false
+	err = RetryOnConflict(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = GetUserByID(ctx, claims.UserID)
+		if err != nil {
+			return err
+		}
+		if !user.MFAEnabled {
+			return errors.New("multi-factor authentication not enabled")
+		}
+		valid = user.VerifyTOTP(req.Code, cfg.MFASkew, time.Now()) ||
+			user.UseRecoveryCode(req.Code)
+		if !valid {
+			return nil
+		}
+		return UpdateUser(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		addAuditEntry(ctx, "Login", user.EmailAddress, "invalid mfa code")
+		recordLoginEvent(ctx, user.ID.Hex(), user.EmailAddress,
+			users.LoginFailure, "invalid mfa code")
+		return nil, ErrInvalidMFACode
+	}
+	mfaAttempts.reset(claims.UserID)
+	return completeLogin(ctx, user)
+}
+
+// completeLogin issues the tokens for the authenticated user and saves the
+// time of the login as the user's last.
+func completeLogin(ctx context.Context,
+	user *users.User) (*users.AuthenticationResponse, error) {
+	access, refresh, err := IssueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	id := user.ID.Hex()
+	now := time.Now().UTC()
+	err = RetryOnConflict(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		user.LastLogin = &now
+		return UpdateUser(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	addAuditEntry(ctx, "Login", user.EmailAddress, "logged in")
+	recordLoginEvent(ctx, user.ID.Hex(), user.EmailAddress, users.LoginSuccess,
+		"")
+	return &users.AuthenticationResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		User:         *user,
+	}, nil
+}
+
+// StartMFAEnrollment gives the user a new authenticator secret, returning it
+// and its otpauth uri.  The secret isn't required to log in until confirmed,
+// and a user with multi-factor authentication enabled must disable it first.
+func StartMFAEnrollment(ctx context.Context,
+	user *users.User) (*users.MFAEnrollmentResponse, error) {
+	if err := user.StartMFAEnrollment(); err != nil {
+		return nil, err
+	}
+	if err := UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return &users.MFAEnrollmentResponse{
+		Secret: user.MFASecret,
+		URI:    user.MFAURI(getSettings().MFAIssuer),
+	}, nil
+}
+
+// ConfirmMFAEnrollment turns on the user's multi-factor authentication when
+// the code comes from the new secret, returning the recovery codes.
+func ConfirmMFAEnrollment(ctx context.Context, user *users.User,
+	code string) (*users.MFAEnrollmentResponse, error) {
+	codes, err := user.ConfirmMFAEnrollment(code, getSettings().MFASkew,
+		time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	addAuditEntry(ctx, "MFA", user.EmailAddress, "enrolled")
+	return &users.MFAEnrollmentResponse{RecoveryCodes: codes}, nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, after checking
+// a current authenticator code.
+func RegenerateRecoveryCodes(ctx context.Context, user *users.User,
+	code string) (*users.MFAEnrollmentResponse, error) {
+	if !user.MFAEnabled ||
+		!user.VerifyTOTP(code, getSettings().MFASkew, time.Now()) {
+		return nil, ErrInvalidMFACode
+	}
+	codes, err := user.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	addAuditEntry(ctx, "MFA", user.EmailAddress, "recovery codes replaced")
+	return &users.MFAEnrollmentResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableMFA turns off the user's multi-factor authentication after checking
+// a current authenticator code or one of the recovery codes.
+func DisableMFA(ctx context.Context, user *users.User, code string) error {
+	if err := user.DisableMFA(code, getSettings().MFASkew,
+		time.Now()); err != nil {
+		return ErrInvalidMFACode
+	}
+	if err := UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	addAuditEntry(ctx, "MFA", user.EmailAddress, "disabled")
+	return nil
+}
+
+// ResetMFA turns off the multi-factor authentication of a user who has lost
+// the authenticator, recording the administrator who reset it.
+func ResetMFA(ctx context.Context, id, admin string) (*users.User, error) {
+	var user *users.User
+	err := RetryOnConflict(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		user.ResetMFA()
+		return UpdateUser(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	addAuditEntry(ctx, "MFA", user.EmailAddress, "reset by "+admin)
+	return user, nil
+}
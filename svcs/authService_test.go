@@ -0,0 +1,61 @@
+package svcs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestLoginRehashesPassword(t *testing.T) {
+	cfg := useTestServices(t)
+	ctx := context.Background()
+	bcryptUser := testUser(t, "bcrypt@example.com", primitive.NilObjectID)
+	wrongUser := testUser(t, "wrong@example.com", primitive.NilObjectID)
+
+	cfg.PasswordHasher = config.HasherArgon2id
+	cfg.Argon2Memory = 1024
+	cfg.Argon2Iterations = 1
+	Configure(cfg)
+	argonUser := testUser(t, "argon@example.com", primitive.NilObjectID)
+
+	tests := []struct {
+		name       string
+		user       *users.User
+		password   string
+		wantPrefix string
+		wantSame   bool
+	}{
+		{"bcrypt hash rehashed", bcryptUser, ownerPassword, "$argon2id$", false},
+		{"current hash kept", argonUser, ownerPassword, "$argon2id$", true},
+		{"wrong password leaves the hash", wrongUser, "Wrong-Password-1",
+			"$2a$", true},
+	}
+	for _, tt := range tests {
+		_, err := Login(ctx, users.AuthenticationRequest{
+			EmailAddress: tt.user.EmailAddress,
+			Password:     tt.password,
+		})
+		if (err == nil) != (tt.password == ownerPassword) {
+			t.Errorf("%s: Login = %v", tt.name, err)
+		}
+		saved, err := GetUserByID(ctx, tt.user.ID.Hex())
+		if err != nil {
+			t.Fatalf("%s: GetUserByID: %v", tt.name, err)
+		}
+		if !strings.HasPrefix(saved.Password, tt.wantPrefix) {
+			t.Errorf("%s: saved hash %.12s..., want %s", tt.name,
+				saved.Password, tt.wantPrefix)
+		}
+		if (saved.Password == tt.user.Password) != tt.wantSame {
+			t.Errorf("%s: hash changed = %v, want %v", tt.name,
+				saved.Password != tt.user.Password, !tt.wantSame)
+		}
+		if err := saved.Authenticate(ownerPassword); err != nil {
+			t.Errorf("%s: password refused after login: %v", tt.name, err)
+		}
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"net/smtp"
 	"strings"
 
-	"github.com/erneap/go-models/config"
+	"github.com/erneap/go-pg-models/config"
 )
 
 type SmtpServer struct {
@@ -32,13 +32,19 @@ func (s *SmtpServer) Send(to []string, subject, body string) error {
 	return err
 }
 
-func SendMail(to []string, subject, body string) error {
-	smtpServer := SmtpServer{
-		Host:     config.Config("SMTP_SERVER"),
-		Port:     config.Config("SMTP_PORT"),
-		Password: config.Config("SMTP_PASS"),
-		From:     config.Config("SMTP_FROM"),
+// NewSmtpServer returns the mail server given by the configuration's smtp
+// settings.
+func NewSmtpServer(cfg *config.Config) *SmtpServer {
+	return &SmtpServer{
+		Host:     cfg.SmtpServer,
+		Port:     cfg.SmtpPort,
+		Password: cfg.SmtpPassword,
+		From:     cfg.SmtpFrom,
 	}
+}
+
+func SendMail(to []string, subject, body string) error {
+	smtpServer := NewSmtpServer(getSettings())
 
 	err := smtpServer.Send(to, subject, body)
 	if err != nil {
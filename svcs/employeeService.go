@@ -0,0 +1,178 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/erneap/go-pg-models/employees"
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/users"
+)
+
+// Crud Functions for retrieving and updating the scheduler's employees.
+// Updates are conditional on the employee's version, so changes made from a
+// stale copy return a stores.ConflictError instead of overwriting someone
+// else's work.
+
+// ConflictRetries is the number of attempts RetryOnConflict makes before
+// giving up and returning the conflict.
+const ConflictRetries = 5
+
+func GetEmployee(ctx context.Context, id string) (*employees.Employee, error) {
+	store, err := getEmployeeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetEmployee(ctx, id)
+}
+
+func GetEmployeesBySite(ctx context.Context, teamID,
+	siteID string) ([]employees.Employee, error) {
+	store, err := getEmployeeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetEmployeesBySite(ctx, teamID, siteID)
+}
+
+// GetEmployeeByUserID returns the employee record of the user, or
+// stores.ErrNotFound when the user isn't an employee.
+func GetEmployeeByUserID(ctx context.Context,
+	userid string) (*employees.Employee, error) {
+	store, err := getEmployeeStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetEmployeeByUserID(ctx, userid)
+}
+
+// UpdateEmployee saves the employee, advancing its version, or returns a
+// stores.ConflictError when it was changed since it was read.
+func UpdateEmployee(ctx context.Context, emp *employees.Employee) error {
+	store, err := getEmployeeStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.UpdateEmployee(ctx, emp)
+}
+
+// RetryOnConflict runs a read-modify-write function again whenever it fails
+// with a version conflict, up to ConflictRetries attempts, waiting a short
+// random time between attempts.  The function must re-read the record each
+// time it is called.
+func RetryOnConflict(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 1; attempt <= ConflictRetries; attempt++ {
+		err = fn(ctx)
+		if !errors.Is(err, stores.ErrConflict) {
+			return err
+		}
+		wait := time.Duration(attempt*10+rand.Intn(40)) * time.Millisecond
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return err
+}
+
+// UpdateLeaveRequest changes a field of one of the employee's leave requests
+// and saves the employee, re-reading and re-applying the change when another
+// update got in first.  It returns the message from the employee's update,
+// the changed request and the saved employee.
+func UpdateLeaveRequest(ctx context.Context, empID, request, field,
+	value string, offset float64) (string, *employees.LeaveRequest,
+	*employees.Employee, error) {
+	var message string
+	var req *employees.LeaveRequest
+	var emp *employees.Employee
+	err := RetryOnConflict(ctx, func(ctx context.Context) error {
+		var err error
+		emp, err = GetEmployee(ctx, empID)
+		if err != nil {
+			return err
+		}
+		message, req, err = emp.UpdateLeaveRequest(request, field, value,
+			offset)
+		if err != nil {
+			return err
+		}
+		return UpdateEmployee(ctx, emp)
+	})
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return message, req, emp, nil
+}
+
+// ApproveLeaveRequest approves the employee's leave request, which rewrites
+// the employee's leave days, and notifies the employee of the approval.  The
+// employee and the notification are saved in one transaction, so neither is
+// kept without the other, and the whole transaction is retried when another
+// update to the employee got in first.
+func ApproveLeaveRequest(ctx context.Context, empID, request,
+	approver string) (*employees.Employee, error) {
+	return approveLeaveRequest(ctx, empID, request, approver, nil)
+}
+
+// ApproveLeaveRequestAs approves the employee's leave request like
+// ApproveLeaveRequest, once AuthorizeEmployee has checked the approver may
+// approve leave for the employee's team and site.  An administrator
+// impersonating the approver is recorded as the approver.
+func ApproveLeaveRequestAs(ctx context.Context, approver *users.User, app,
+	empID, request string) (*employees.Employee, error) {
+	return approveLeaveRequest(ctx, empID, request,
+		attributedID(ctx, approver.ID.Hex()),
+		func(ctx context.Context, emp *employees.Employee) error {
+			return AuthorizeEmployee(ctx, approver, app, users.PermApproveLeave,
+				emp)
+		})
+}
+
+func approveLeaveRequest(ctx context.Context, empID, request, approver string,
+	authorize func(context.Context, *employees.Employee) error) (
+	*employees.Employee, error) {
+	var emp *employees.Employee
+	err := RetryOnConflict(ctx, func(ctx context.Context) error {
+		return InTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			emp, err = GetEmployee(ctx, empID)
+			if err != nil {
+				return err
+			}
+			if authorize != nil {
+				if err := authorize(ctx, emp); err != nil {
+					return err
+				}
+			}
+			message, req, err := emp.UpdateLeaveRequest(request, "approve",
+				approver, 0.0)
+			if err != nil {
+				return err
+			}
+			if req == nil {
+				return fmt.Errorf("leave request %s not found", request)
+			}
+			if err := UpdateEmployee(ctx, emp); err != nil {
+				return err
+			}
+			return CreateMessage(ctx, emp.ID.Hex(), approver, message)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return emp, nil
+}
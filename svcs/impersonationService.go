@@ -0,0 +1,210 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+// Impersonation functions.  An administrator can be given a short-lived
+// access token acting as another user, to see the application exactly as the
+// user does.  The token is only good in the application it was issued for and
+// can't be renewed, and the start and end of each impersonation are recorded
+// as login events of the user and in the authenticate log.  The changes made
+// through the token are attributed to the administrator by GetRequestor,
+// CreateMessage and AddLogEntry2Context.
+
+var ErrNotImpersonation = errors.New("token is not an impersonation")
+
+// the gin context key of the cached impersonation.
+const impersonationKey = "svcs.impersonation"
+
+type impersonationCtxKey struct{}
+
+// Impersonation is the administrator, the Actor, acting as the User.
+type Impersonation struct {
+	Actor *users.User
+	User  *users.User
+}
+
+// GetImpersonation returns the impersonation cached by Authenticate for a
+// request made with an impersonation token.
+func GetImpersonation(c *gin.Context) (*Impersonation, bool) {
+	value, ok := c.Get(impersonationKey)
+	if !ok {
+		return nil, false
+	}
+	imp, ok := value.(*Impersonation)
+	return imp, ok
+}
+
+// withImpersonation returns the context carrying the impersonation, so the
+// services called with the request's context can attribute its changes.
+func withImpersonation(ctx context.Context,
+	imp *Impersonation) context.Context {
+	return context.WithValue(ctx, impersonationCtxKey{}, imp)
+}
+
+// impersonationFrom returns the impersonation of the request the context
+// belongs to, or nil when it isn't made with an impersonation token.
+func impersonationFrom(ctx context.Context) *Impersonation {
+	if ctx == nil {
+		return nil
+	}
+	if imp, ok := ctx.Value(impersonationCtxKey{}).(*Impersonation); ok {
+		return imp
+	}
+	if c, ok := ctx.Value(gin.ContextKey).(*gin.Context); ok {
+		imp, _ := GetImpersonation(c)
+		return imp
+	}
+	return nil
+}
+
+// attributedID returns the id to record for a change made by the user, which
+// is the administrator's when the administrator is impersonating the user.
+func attributedID(ctx context.Context, userid string) string {
+	if imp := impersonationFrom(ctx); imp != nil &&
+		imp.User.ID.Hex() == userid {
+		return imp.Actor.ID.Hex()
+	}
+	return userid
+}
+
+// Impersonate gives the administrator an access token acting as the user in
+// the application, lasting the configured impersonation lifetime, and records
+// the reason given.  The administrator may only impersonate a user within the
+// reach of the administrator's impersonate permission, and never another
+// administrator at any scope.
+func Impersonate(ctx context.Context, admin *users.User, app, userid,
+	reason string) (*users.AuthenticationResponse, error) {
+	if admin.ID.Hex() == userid {
+		return nil, fmt.Errorf("%w: can't impersonate yourself", ErrForbidden)
+	}
+	user, err := GetUserByID(ctx, userid)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeImpersonation(ctx, admin, app, user); err != nil {
+		return nil, err
+	}
+	if user.Unverified {
+		return nil, ErrEmailNotVerified
+	}
+
+	ttl := getSettings().ImpersonationTTL
+	token, err := signClaims(&users.JWTClaim{
+		UserID:       user.ID.Hex(),
+		EmailAddress: user.EmailAddress,
+		ActorID:      admin.ID.Hex(),
+		StandardClaims: jwt.StandardClaims{
+			Audience: strings.ToLower(app),
+		},
+	}, ttl)
+	if err != nil {
+		return nil, err
+	}
+	saveLoginEvent(ctx, &users.LoginEvent{
+		UserID:       user.ID.Hex(),
+		EmailAddress: user.EmailAddress,
+		Type:         users.LoginImpersonate,
+		Detail:       reason,
+		ActorID:      admin.ID.Hex(),
+	})
+	msg := "impersonated by " + admin.EmailAddress + " in " + app +
+		" until " + time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	if reason != "" {
+		msg += ": " + reason
+	}
+	addAuditEntry(ctx, "Impersonation", user.EmailAddress, msg)
+	return &users.AuthenticationResponse{
+		Token: token,
+		User:  *user,
+	}, nil
+}
+
+// EndImpersonation revokes the impersonation token before it expires,
+// recording the end of the impersonation.
+func EndImpersonation(ctx context.Context, claims *users.JWTClaim) error {
+	if !claims.IsImpersonation() {
+		return ErrNotImpersonation
+	}
+	if err := RevokeAccessToken(ctx, claims); err != nil {
+		return err
+	}
+	saveLoginEvent(ctx, &users.LoginEvent{
+		UserID:       claims.UserID,
+		EmailAddress: claims.EmailAddress,
+		Type:         users.LoginImpersonateEnd,
+		ActorID:      claims.ActorID,
+	})
+	actor := claims.ActorID
+	if imp := impersonationFrom(ctx); imp != nil {
+		actor = imp.Actor.EmailAddress
+	}
+	addAuditEntry(ctx, "Impersonation", claims.EmailAddress,
+		"impersonation by "+actor+" ended")
+	return nil
+}
+
+// impersonationActor returns the administrator using the impersonation token
+// with the claims, who must still be allowed to impersonate the user in the
+// application the token was issued for.
+func impersonationActor(ctx context.Context, app string, claims *users.JWTClaim,
+	user *users.User) (*users.User, error) {
+	if !strings.EqualFold(claims.Audience, app) {
+		return nil, fmt.Errorf("%w: impersonation token is for %s",
+			ErrForbidden, claims.Audience)
+	}
+	actor, err := GetUserByID(ctx, claims.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeImpersonation(ctx, actor, app, user); err != nil {
+		return nil, err
+	}
+	return actor, nil
+}
+
+// authorizeImpersonation checks that the administrator may impersonate the
+// user in the application.  The administrator's impersonate permission must
+// reach the team and site of the user's employee record, through
+// AuthorizeScope, or every team for a user who isn't an employee.  A user
+// granted the permission at any scope is an administrator too, and is
+// refused.
+func authorizeImpersonation(ctx context.Context, admin *users.User, app string,
+	user *users.User) error {
+	if !admin.CanAnywhere(app, users.PermImpersonate) {
+		return ErrForbidden
+	}
+	if user.CanAnywhere(app, users.PermImpersonate) {
+		return fmt.Errorf("%w: can't impersonate another administrator",
+			ErrForbidden)
+	}
+	resource := users.Scope{TeamID: users.AnyTeam}
+	emp, err := GetEmployeeByUserID(ctx, user.ID.Hex())
+	if err == nil {
+		resource = EmployeeScope(emp)
+	} else if !errors.Is(err, stores.ErrNotFound) {
+		return err
+	}
+	return AuthorizeScope(ctx, admin, app, users.PermImpersonate, resource)
+}
+
+// ForbidImpersonation refuses a request made while impersonating a user, for
+// changes only the user may make, such as to the user's password.
+func ForbidImpersonation() Authorizer {
+	return func(c *gin.Context, user *users.User) error {
+		if _, ok := GetImpersonation(c); ok {
+			return fmt.Errorf("%w: not while impersonating", ErrForbidden)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,179 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/erneap/go-pg-models/employees"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testUser creates a verified user holding the workgroups, with an employee
+// record on the team when the team isn't blank.
+func testUser(t *testing.T, email string, team primitive.ObjectID,
+	workgroups ...string) *users.User {
+	t.Helper()
+	ctx := context.Background()
+	user, err := CreateUser(ctx, email, "Pat", "", email, ownerPassword)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	roles, malformed := users.ParseRoles(workgroups)
+	if len(malformed) > 0 {
+		t.Fatalf("malformed workgroups: %v", malformed)
+	}
+	user.SetRoles(roles)
+	if err := UpdateUser(ctx, user); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if !team.IsZero() {
+		store, err := getEmployeeStore(ctx)
+		if err != nil {
+			t.Fatalf("getEmployeeStore: %v", err)
+		}
+		if err := store.CreateEmployee(ctx, &employees.Employee{
+			TeamID: team,
+			SiteID: "site",
+			UserID: user.ID,
+			Email:  email,
+		}); err != nil {
+			t.Fatalf("CreateEmployee: %v", err)
+		}
+	}
+	return user
+}
+
+func TestImpersonateScope(t *testing.T) {
+	useTestServices(t)
+	teamA, teamB := primitive.NewObjectID(), primitive.NewObjectID()
+	none := primitive.NilObjectID
+	adminA := testUser(t, "admin-a@example.com", teamA, "scheduler-admin")
+	scopedA := testUser(t, "scoped-a@example.com", none,
+		"scheduler-admin@"+teamA.Hex())
+	global := testUser(t, "global@example.com", none, "scheduler-admin@*")
+	adminB := testUser(t, "admin-b@example.com", teamB,
+		"scheduler-admin@"+teamB.Hex())
+	empA := testUser(t, "emp-a@example.com", teamA, "scheduler-employee")
+	empB := testUser(t, "emp-b@example.com", teamB, "scheduler-employee")
+	outsider := testUser(t, "outsider@example.com", none)
+
+	tests := []struct {
+		name  string
+		admin *users.User
+		user  *users.User
+		want  bool
+	}{
+		{"unscoped admin on own team", adminA, empA, true},
+		{"unscoped admin on another team", adminA, empB, false},
+		{"unscoped admin on a user without a team", adminA, outsider, false},
+		{"team scoped admin on the team", scopedA, empA, true},
+		{"team scoped admin on another team", scopedA, empB, false},
+		{"cross-team admin on another team", global, empB, true},
+		{"cross-team admin on a user without a team", global, outsider, true},
+		{"team scoped administrator as target", global, adminB, false},
+		{"unscoped administrator as target", global, adminA, false},
+		{"employee", empA, empB, false},
+		{"self", global, global, false},
+	}
+	for _, tt := range tests {
+		_, err := Impersonate(context.Background(), tt.admin, "scheduler",
+			tt.user.ID.Hex(), "support call")
+		if got := err == nil; got != tt.want {
+			t.Errorf("%s: Impersonate error = %v, want allowed %v", tt.name,
+				err, tt.want)
+		}
+		if err != nil && !errors.Is(err, ErrForbidden) {
+			t.Errorf("%s: Impersonate error = %v, want %v", tt.name, err,
+				ErrForbidden)
+		}
+	}
+}
+
+func TestImpersonationToken(t *testing.T) {
+	cfg := useTestServices(t)
+	ctx := context.Background()
+	team := primitive.NewObjectID()
+	admin := testUser(t, "admin@example.com", team, "scheduler-admin")
+	user := testUser(t, "user@example.com", team, "scheduler-employee")
+
+	requestor := func(c *gin.Context) {
+		c.String(http.StatusOK, GetRequestor(c))
+	}
+	router := gin.New()
+	router.GET("/scheduler", Authenticate("scheduler"), requestor)
+	router.GET("/journal", Authenticate("soap"), requestor)
+	router.GET("/requestor", requestor)
+	call := func(path, token string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	resp, err := Impersonate(ctx, admin, "scheduler", user.ID.Hex(), "")
+	if err != nil {
+		t.Fatalf("Impersonate: %v", err)
+	}
+	if code, body := call("/scheduler", resp.Token); code != http.StatusOK ||
+		body != admin.ID.Hex() {
+		t.Errorf("scheduler request = %d %q, want %d %q", code, body,
+			http.StatusOK, admin.ID.Hex())
+	}
+	if code, _ := call("/journal", resp.Token); code != http.StatusUnauthorized {
+		t.Errorf("journal request = %d, want %d", code,
+			http.StatusUnauthorized)
+	}
+	if _, body := call("/requestor", resp.Token); body != admin.ID.Hex() {
+		t.Errorf("unauthenticated requestor = %q, want %q", body,
+			admin.ID.Hex())
+	}
+
+	// the administrator losing the role ends the impersonation.
+	demoted, err := GetUserByID(ctx, admin.ID.Hex())
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	demoted.SetRoles(nil)
+	if err := UpdateUser(ctx, demoted); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if code, _ := call("/scheduler", resp.Token); code != http.StatusUnauthorized {
+		t.Errorf("request after demotion = %d, want %d", code,
+			http.StatusUnauthorized)
+	}
+	if _, body := call("/requestor", resp.Token); body != "" {
+		t.Errorf("requestor after demotion = %q, want none", body)
+	}
+
+	// logging the user out outlasts the impersonation token.
+	demoted.SetRoles([]users.Role{{Application: "scheduler", Name: "admin"}})
+	if err := UpdateUser(ctx, demoted); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	resp, err = Impersonate(ctx, demoted, "scheduler", user.ID.Hex(), "")
+	if err != nil {
+		t.Fatalf("Impersonate: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := LogoutAllSessions(ctx, user); err != nil {
+		t.Fatalf("LogoutAllSessions: %v", err)
+	}
+	store, err := getTokenStore(ctx)
+	if err != nil {
+		t.Fatalf("getTokenStore: %v", err)
+	}
+	if err := store.DeleteExpiredTokens(ctx,
+		time.Now().Add(cfg.JWTAccessTTL+time.Minute)); err != nil {
+		t.Fatalf("DeleteExpiredTokens: %v", err)
+	}
+	if _, err := ValidateToken(ctx, resp.Token); err == nil {
+		t.Error("impersonation token valid after the user's logout")
+	}
+}
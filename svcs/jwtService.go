@@ -1,44 +1,117 @@
 package svcs
 
 import (
+	"context"
 	"errors"
-	"net/http"
-	"os"
-	"strings"
 	"time"
 
-	"github.com/erneap/go-models/logs"
-	"github.com/erneap/go-models/users"
+	"github.com/erneap/go-pg-models/users"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreateToken(userid primitive.ObjectID, email string) (string, error) {
-	key := []byte(os.Getenv("JWT_SECRET"))
-	expireTime := time.Now().Add(6 * time.Hour)
-	claims := &users.JWTClaim{
+// CreateToken returns a short-lived access token for the user, which lasts the
+// configured access token lifetime.  It is signed with the key set's current
+// key, named by its kid header, and carries a unique id so it can be put on
+// the revocation list.  It is renewed through RefreshTokens.  A user whose
+// email address isn't verified is refused with ErrEmailNotVerified.
+func CreateToken(user *users.User) (string, error) {
+	if user.Unverified {
+		return "", ErrEmailNotVerified
+	}
+	return signToken(user.ID, user.EmailAddress, "", getSettings().JWTAccessTTL)
+}
+
+// signToken returns a token for the user with the purpose, lasting the ttl.
+func signToken(userid primitive.ObjectID, email, purpose string,
+	ttl time.Duration) (string, error) {
+	return signClaims(&users.JWTClaim{
 		UserID:       userid.Hex(),
 		EmailAddress: email,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expireTime.Unix(),
-		},
+		Purpose:      purpose,
+	}, ttl)
+}
+
+// signClaims returns a token with the claims, given a unique id and lasting
+// the ttl from now.
+func signClaims(claims *users.JWTClaim, ttl time.Duration) (string, error) {
+	ks, err := getKeySet()
+	if err != nil {
+		return "", err
+	}
+	key, err := ks.signingKey()
+	if err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(key)
+	method, err := key.method()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.Id = primitive.NewObjectID().Hex()
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+	token := jwt.NewWithClaims(method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	tokenString, err := token.SignedString(key.Private)
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
 
-func ValidateToken(signedToken string) (*users.JWTClaim, error) {
-	token, err := jwt.ParseWithClaims(
+// ValidateToken checks the access token's signature, against the key named by
+// its kid header and with only that key's algorithm, and its expiry, and that
+// it hasn't been revoked, returning its claims.  An impersonation token is
+// also revoked by revoking the sessions of the administrator using it.
+func ValidateToken(ctx context.Context, signedToken string) (*users.JWTClaim,
+	error) {
+	claims, err := parseToken(signedToken, "")
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := getTokenStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	revoked, err := store.IsTokenRevoked(ctx, claims.Id, claims.UserID,
+		time.Unix(claims.IssuedAt, 0).UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !revoked && claims.IsImpersonation() {
+		revoked, err = store.IsTokenRevoked(ctx, "", claims.ActorID,
+			time.Unix(claims.IssuedAt, 0).UTC())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if revoked {
+		return nil, errors.New("token revoked")
+	}
+	return claims, nil
+}
+
+// parseToken checks the token's signature and expiry, and that it was issued
+// for the purpose, returning its claims.
+func parseToken(signedToken, purpose string) (*users.JWTClaim, error) {
+	ks, err := getKeySet()
+	if err != nil {
+		return nil, err
+	}
+	parser := &jwt.Parser{
+		ValidMethods: []string{AlgHS256, AlgRS256, AlgEdDSA},
+	}
+	token, err := parser.ParseWithClaims(
 		signedToken,
 		&users.JWTClaim{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		},
+		ks.verificationKey,
 	)
 	if err != nil {
 		return nil, err
@@ -50,169 +123,74 @@ func ValidateToken(signedToken string) (*users.JWTClaim, error) {
 	if claims.ExpiresAt < time.Now().Local().Unix() {
 		return nil, errors.New("token expired")
 	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("token not valid for this use")
+	}
 	return claims, nil
 }
 
-func GetRequestor(context *gin.Context) string {
-	tokenString := context.GetHeader("Authorization")
+// GetRequestor returns the id of the user making the request, or of the api
+// key, from the user Authenticate cached, or else from the request's access
+// token, and is blank when neither is valid.  For a request made while
+// impersonating a user, it is the id of the administrator, so the request's
+// changes are attributed to the administrator.
+func GetRequestor(c *gin.Context) string {
+	if imp, ok := GetImpersonation(c); ok {
+		return imp.Actor.ID.Hex()
+	}
+	if user, ok := GetUser(c); ok {
+		return user.ID.Hex()
+	}
+	tokenString := bearerToken(c)
 	if tokenString == "" {
 		return ""
 	}
-	claims, err := ValidateToken(tokenString)
+	ctx := c.Request.Context()
+	claims, err := ValidateToken(ctx, tokenString)
 	if err != nil {
 		return ""
 	}
-	return claims.UserID
-}
-
-func CheckJWT(app string) gin.HandlerFunc {
-	return func(context *gin.Context) {
-		tokenString := context.GetHeader("Authorization")
-		if tokenString == "" {
-			AddLogEntry(app, logs.Minimal,
-				"CheckJWT: No Authentication Token passed")
-			context.JSON(http.StatusUnauthorized, gin.H{"error": "request does not contain an access token"})
-			context.Abort()
-			return
+	if claims.IsImpersonation() {
+		user, err := GetUserByID(ctx, claims.UserID)
+		if err != nil {
+			return ""
 		}
-		claims, err := ValidateToken(tokenString)
+		actor, err := impersonationActor(ctx, claims.Audience, claims, user)
 		if err != nil {
-			AddLogEntry(app, logs.Minimal, "CheckJWT: Validation Error: "+
-				err.Error())
-			context.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			context.Abort()
-			return
+			return ""
 		}
-
-		// replace token by passing a new token in the response header
-		AddLogEntry(app, logs.Debug, "CheckJWT: Token Verified")
-		id, _ := primitive.ObjectIDFromHex(claims.UserID)
-		tokenString, _ = CreateToken(id, claims.EmailAddress)
-		context.Writer.Header().Set("Token", tokenString)
-		context.Next()
+		return actor.ID.Hex()
 	}
+	return claims.UserID
+}
+
+// CheckJWT lets the request through with a valid access token or api key,
+// through Authenticate.
+func CheckJWT(app string) gin.HandlerFunc {
+	return Authenticate(app)
 }
 
+// CheckRole lets the request through when the user holds the role in the
+// program.
 func CheckRole(prog, role string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			AddLogEntry(prog, logs.Minimal,
-				"CheckRole: No Authentication Token passed")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "request does not contain an access token"})
-			c.Abort()
-			return
-		}
-		claims, err := ValidateToken(tokenString)
-		if err != nil {
-			AddLogEntry(prog, logs.Minimal, "CheckRole: Validation Error: "+
-				err.Error())
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
-			return
-		}
-		user, err := GetUserByID(claims.UserID)
-		if err != nil {
-			AddLogEntry(prog, logs.Minimal, "CheckRole: User Not Found: "+
-				err.Error())
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found: " + err.Error()})
-			c.Abort()
-			return
-		}
-		if !user.IsInGroup(prog, role) {
-			AddLogEntry(prog, logs.Minimal, "CheckRole: User Not in Group: "+
-				user.LastName)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not in group"})
-			c.Abort()
-			return
-		}
-		c.Next()
-	}
+	return Authorize(prog, RequireRole(prog, role))
 }
 
+// CheckRoles lets the request through when the user holds any of the roles in
+// the program.
 func CheckRoles(prog string, roles []string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			AddLogEntry(prog, logs.Minimal,
-				"CheckRoles: No Authentication Token passed")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "request does not contain an access token"})
-			c.Abort()
-			return
-		}
-		claims, err := ValidateToken(tokenString)
-		if err != nil {
-			AddLogEntry(prog, logs.Minimal, "CheckRoles: Validation Error: "+
-				err.Error())
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
-			return
-		}
-		user, err := GetUserByID(claims.UserID)
-		if err != nil {
-			AddLogEntry(prog, logs.Minimal, "CheckRoles: User Not Found: "+
-				err.Error())
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found: " + err.Error()})
-			c.Abort()
-			return
-		}
-		inRole := false
-		for i := 0; i < len(roles) && !inRole; i++ {
-			if user.IsInGroup(prog, roles[i]) {
-				inRole = true
-			}
-		}
-		if !inRole {
-			AddLogEntry(prog, logs.Minimal, "CheckRoles: User Not In Group: "+
-				user.LastName)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not in group"})
-			c.Abort()
-			return
-		}
-		c.Next()
-	}
+	return Authorize(prog, RequireAnyRole(prog, roles...))
 }
 
+// CheckRoleList lets the request through when the user holds any of the roles
+// given in their string form, such as "scheduler-admin@team".
 func CheckRoleList(app string, roles []string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			AddLogEntry(app, logs.Minimal,
-				"CheckRoleList: No Authentication Token passed")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "request does not contain an access token"})
-			c.Abort()
-			return
-		}
-		claims, err := ValidateToken(tokenString)
-		if err != nil {
-			AddLogEntry(app, logs.Minimal,
-				"CheckRoleList: Validation Error: "+err.Error())
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
-			c.Abort()
-			return
-		}
-		user, err := GetUserByID(claims.UserID)
-		if err != nil {
-			AddLogEntry(app, logs.Minimal, "CheckRoleList: User Not Found: "+
-				err.Error())
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found: " + err.Error()})
-			c.Abort()
-			return
-		}
-		inRole := false
-		for i := 0; i < len(roles) && !inRole; i++ {
-			parts := strings.Split(roles[i], "-")
-			if user.IsInGroup(parts[0], parts[1]) {
-				inRole = true
-			}
-		}
-		if !inRole {
-			AddLogEntry(app, logs.Minimal,
-				"CheckRoleList: User not in any of the roles provided: "+user.LastName)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not in group"})
-			c.Abort()
-			return
-		}
-		c.Next()
-	}
+	return Authorize(app, RequireRoleList(app, roles))
+}
+
+// CheckScope lets the request through when the user has the application's
+// permission over the team and site the resolver gives for the resource,
+// through AuthorizeScope.
+func CheckScope(app, permission string, resolve ScopeResolver) gin.HandlerFunc {
+	return Authorize(app, RequireScope(app, permission, resolve))
 }
@@ -0,0 +1,389 @@
+package svcs
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+// The signing algorithms supported for the access tokens.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey is a key used to sign or verify the access tokens, named by the
+// kid header of the tokens it signs.  HS256 keys are the shared secret as a
+// []byte, RS256 keys an *rsa.PrivateKey and *rsa.PublicKey, and EdDSA keys an
+// ed25519.PrivateKey and ed25519.PublicKey.  A key without its private half
+// can only verify.  A retired key verifies until RetireAt, when the tokens it
+// signed have expired.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   crypto.PrivateKey
+	Public    crypto.PublicKey
+	RetireAt  time.Time
+}
+
+func (k *SigningKey) method() (jwt.SigningMethod, error) {
+	switch k.Algorithm {
+	case AlgHS256:
+		return jwt.SigningMethodHS256, nil
+	case AlgRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm: %s", k.Algorithm)
+}
+
+// NewHMACKey returns the HS256 key for the shared secret.
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{
+		ID:        id,
+		Algorithm: AlgHS256,
+		Private:   secret,
+		Public:    secret,
+	}
+}
+
+// ParseSigningKeyPEM returns the RS256 or EdDSA key for the PEM encoded
+// private key, choosing the algorithm by the key's type.
+func ParseSigningKeyPEM(id string, data []byte) (*SigningKey, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return &SigningKey{
+			ID:        id,
+			Algorithm: AlgRS256,
+			Private:   key,
+			Public:    &key.PublicKey,
+		}, nil
+	}
+	key, err := jwt.ParseEdPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, errors.New("signing key is not an rsa or ed25519 private key")
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key is not an rsa or ed25519 private key")
+	}
+	return &SigningKey{
+		ID:        id,
+		Algorithm: AlgEdDSA,
+		Private:   edKey,
+		Public:    edKey.Public(),
+	}, nil
+}
+
+// KeySet holds the current signing key and the keys still accepted when
+// verifying a token.
+type KeySet struct {
+	mutex   sync.RWMutex
+	current string
+	keys    map[string]*SigningKey
+}
+
+func NewKeySet(keys ...*SigningKey) *KeySet {
+	ks := &KeySet{keys: make(map[string]*SigningKey)}
+	for _, key := range keys {
+		ks.Add(key)
+	}
+	return ks
+}
+
+// Add adds the key for verification, making it the signing key when the set
+// has none and the key has its private half.
+func (ks *KeySet) Add(key *SigningKey) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	_, hasCurrent := ks.keys[ks.current]
+	ks.keys[key.ID] = key
+	if !hasCurrent && key.Private != nil {
+		ks.current = key.ID
+	}
+}
+
+// Rotate makes the key the signing key.  The previous signing key stays valid
+// for verification for the overlap, which should be at least the access token
+// lifetime, so the tokens it signed are accepted until they expire.
+func (ks *KeySet) Rotate(key *SigningKey, overlap time.Duration) error {
+	if key.Private == nil {
+		return errors.New("signing key has no private key")
+	}
+	if key.ID == "" {
+		return errors.New("signing key has no key id")
+	}
+	if _, err := key.method(); err != nil {
+		return err
+	}
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	if previous, ok := ks.keys[ks.current]; ok && previous.ID != key.ID {
+		previous.RetireAt = time.Now().Add(overlap)
+	}
+	ks.keys[key.ID] = key
+	ks.current = key.ID
+	return nil
+}
+
+// Prune removes the retired keys whose tokens have all expired.
+func (ks *KeySet) Prune() {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+	now := time.Now()
+	for id, key := range ks.keys {
+		if id != ks.current && !key.RetireAt.IsZero() &&
+			now.After(key.RetireAt) {
+			delete(ks.keys, id)
+		}
+	}
+}
+
+func (ks *KeySet) signingKey() (*SigningKey, error) {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	key, ok := ks.keys[ks.current]
+	if !ok || key.Private == nil {
+		return nil, errors.New("no jwt signing key configured")
+	}
+	return key, nil
+}
+
+// verificationKey returns the key for the token's kid header, refusing a token
+// signed with an algorithm other than the key's, so a public key can't be
+// used as an HMAC secret.
+func (ks *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	// Rotate sets a key's RetireAt under the lock, so the fields are copied
+	// while holding it.
+	ks.mutex.RLock()
+	key, ok := ks.keys[kid]
+	var retireAt time.Time
+	var algorithm string
+	var public interface{}
+	if ok {
+		retireAt, algorithm, public = key.RetireAt, key.Algorithm, key.Public
+	}
+	ks.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if !retireAt.IsZero() && time.Now().After(retireAt) {
+		return nil, fmt.Errorf("signing key retired: %q", kid)
+	}
+	if token.Method.Alg() != algorithm {
+		return nil, fmt.Errorf("unexpected signing algorithm: %s",
+			token.Method.Alg())
+	}
+	return public, nil
+}
+
+// JSONWebKey is a public key in a JWKS document.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS is the document listing the public keys, which lets other services
+// verify the access tokens without holding a signing secret.
+type JWKS struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public halves of the RS256 and EdDSA keys still accepted.
+// HS256 keys are shared secrets, so they are never published.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mutex.RLock()
+	defer ks.mutex.RUnlock()
+	answer := JWKS{Keys: []JSONWebKey{}}
+	for _, key := range ks.keys {
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			answer.Keys = append(answer.Keys, JSONWebKey{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: AlgRS256,
+				N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(
+					big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			answer.Keys = append(answer.Keys, JSONWebKey{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: AlgEdDSA,
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(answer.Keys, func(i, j int) bool {
+		return answer.Keys[i].KeyID < answer.Keys[j].KeyID
+	})
+	return answer
+}
+
+// ParseJWKS returns a verification only key set from a JWKS document, for a
+// service which accepts the access tokens but doesn't issue them.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc JWKS
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	ks := NewKeySet()
+	for _, jwk := range doc.Keys {
+		key := &SigningKey{ID: jwk.KeyID}
+		switch jwk.KeyType {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", jwk.KeyID, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", jwk.KeyID, err)
+			}
+			key.Algorithm = AlgRS256
+			key.Public = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "OKP":
+			x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("key %s: bad ed25519 key", jwk.KeyID)
+			}
+			key.Algorithm = AlgEdDSA
+			key.Public = ed25519.PublicKey(x)
+		default:
+			continue
+		}
+		ks.Add(key)
+	}
+	return ks, nil
+}
+
+// the key set used by CreateToken and ValidateToken, which is built from the
+// settings unless the application gives one through UseKeySet.
+var (
+	keySetMutex      sync.Mutex
+	keySet           *KeySet
+	keySetFromConfig bool
+)
+
+// UseKeySet sets the keys the access tokens are signed and verified with.
+func UseKeySet(ks *KeySet) {
+	keySetMutex.Lock()
+	defer keySetMutex.Unlock()
+	keySet = ks
+	keySetFromConfig = false
+}
+
+// resetKeySet drops the key set built from the previous settings, so the
+// next token uses the new ones.
+func resetKeySet() {
+	keySetMutex.Lock()
+	defer keySetMutex.Unlock()
+	if keySetFromConfig {
+		keySet = nil
+	}
+}
+
+// RotateSigningKey makes the key the signing key, keeping the previous one
+// for verification until the tokens it signed have expired.
+func RotateSigningKey(key *SigningKey) error {
+	ks, err := getKeySet()
+	if err != nil {
+		return err
+	}
+	ks.Prune()
+	return ks.Rotate(key, getSettings().JWTAccessTTL)
+}
+
+func getKeySet() (*KeySet, error) {
+	keySetMutex.Lock()
+	defer keySetMutex.Unlock()
+	if keySet == nil {
+		ks, err := newConfigKeySet(getSettings())
+		if err != nil {
+			return nil, err
+		}
+		keySet = ks
+		keySetFromConfig = true
+	}
+	return keySet, nil
+}
+
+// newConfigKeySet returns the key set given by the jwt settings, the private
+// key file when one is set and the jwt secret otherwise.  The secret is still
+// accepted alongside a key file, so tokens issued before the switch stay
+// valid, until the fixed JWT_SECRET_RETIRE_AT.
+func newConfigKeySet(cfg *config.Config) (*KeySet, error) {
+	ks := NewKeySet()
+	if cfg.JWTKeyFile != "" {
+		data, err := os.ReadFile(cfg.JWTKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseSigningKeyPEM(cfg.JWTKeyID, data)
+		if err != nil {
+			return nil, err
+		}
+		if key.ID == "" {
+			return nil, errors.New("JWT_KEY_ID is required with JWT_KEY_FILE")
+		}
+		ks.Add(key)
+	}
+	if cfg.JWTSecret != "" {
+		secret := NewHMACKey("", []byte(cfg.JWTSecret))
+		if cfg.JWTKeyFile != "" {
+			if cfg.JWTSecretRetireAt.IsZero() {
+				return nil, errors.New("JWT_SECRET_RETIRE_AT is required with " +
+					"JWT_SECRET and JWT_KEY_FILE")
+			}
+			secret.Private = nil
+			secret.RetireAt = cfg.JWTSecretRetireAt
+		}
+		ks.Add(secret)
+	}
+	if len(ks.keys) == 0 {
+		return nil, errors.New("no jwt secret configured")
+	}
+	return ks, nil
+}
+
+// JWKSHandler serves the JWKS document of the current keys, usually at
+// /.well-known/jwks.json.
+func JWKSHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ks, err := getKeySet()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ks.JWKS())
+	}
+}
@@ -0,0 +1,153 @@
+package svcs
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erneap/go-pg-models/users"
+	"github.com/golang-jwt/jwt"
+)
+
+func newEdKey(t *testing.T, id string) (*SigningKey, []byte) {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	key, err := ParseSigningKeyPEM(id, data)
+	if err != nil {
+		t.Fatalf("ParseSigningKeyPEM: %v", err)
+	}
+	return key, data
+}
+
+// signWith signs an access token with the key, whatever the current key.
+func signWith(t *testing.T, key *SigningKey) string {
+	t.Helper()
+	method, err := key.method()
+	if err != nil {
+		t.Fatalf("method: %v", err)
+	}
+	token := jwt.NewWithClaims(method, &users.JWTClaim{
+		UserID: "user",
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	})
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	signed, err := token.SignedString(key.Private)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+func useTestKeySet(t *testing.T, ks *KeySet) {
+	t.Helper()
+	UseKeySet(ks)
+	t.Cleanup(func() { UseKeySet(nil) })
+}
+
+func TestKeyRotation(t *testing.T) {
+	useTestServices(t)
+	retired, _ := newEdKey(t, "retired")
+	previous, _ := newEdKey(t, "previous")
+	current, _ := newEdKey(t, "current")
+	unknown, _ := newEdKey(t, "unknown")
+	ks := NewKeySet(retired)
+	if err := ks.Rotate(previous, -time.Second); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if err := ks.Rotate(current, time.Hour); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	useTestKeySet(t, ks)
+
+	// an HS256 token using the public key as its secret, under the kid of
+	// the ed25519 key.
+	confused := NewHMACKey("current",
+		[]byte(current.Public.(ed25519.PublicKey)))
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"current key", signWith(t, current), true},
+		{"previous key within its overlap", signWith(t, previous), true},
+		{"retired key", signWith(t, retired), false},
+		{"unknown key", signWith(t, unknown), false},
+		{"public key used as an hmac secret", signWith(t, confused), false},
+	}
+	for _, tt := range tests {
+		_, err := parseToken(tt.token, "")
+		if got := err == nil; got != tt.want {
+			t.Errorf("%s: parseToken error = %v, want valid %v", tt.name, err,
+				tt.want)
+		}
+	}
+
+	ks.Prune()
+	if _, err := parseToken(signWith(t, retired), ""); err == nil {
+		t.Error("pruned key still verifies")
+	}
+	if _, err := parseToken(signWith(t, previous), ""); err != nil {
+		t.Errorf("Prune removed a key within its overlap: %v", err)
+	}
+}
+
+func TestLegacySecretRetired(t *testing.T) {
+	cfg := useTestServices(t)
+	_, data := newEdKey(t, "")
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(keyFile, data, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	legacy := NewHMACKey("", []byte(cfg.JWTSecret))
+	cfg.JWTKeyFile = keyFile
+	cfg.JWTKeyID = "file"
+
+	if _, err := newConfigKeySet(cfg); err == nil {
+		t.Error("newConfigKeySet accepted the secret without a retirement time")
+	}
+
+	tests := []struct {
+		name     string
+		retireAt time.Time
+		want     bool
+	}{
+		{"before the retirement time", time.Now().Add(time.Hour), true},
+		{"after the retirement time", time.Now().Add(-time.Second), false},
+	}
+	for _, tt := range tests {
+		cfg.JWTSecretRetireAt = tt.retireAt
+		ks, err := newConfigKeySet(cfg)
+		if err != nil {
+			t.Fatalf("newConfigKeySet: %v", err)
+		}
+		useTestKeySet(t, ks)
+		_, err = parseToken(signWith(t, legacy), "")
+		if got := err == nil; got != tt.want {
+			t.Errorf("%s: parseToken error = %v, want valid %v", tt.name, err,
+				tt.want)
+		}
+		if key, err := ks.signingKey(); err != nil || key.ID != "file" {
+			t.Errorf("%s: signing key = %v %v, want the key file's", tt.name,
+				key, err)
+		}
+	}
+}
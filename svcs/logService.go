@@ -6,25 +6,21 @@ import (
 	"fmt"
 	"os"
 	"path"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/erneap/go-models/config"
-	"github.com/erneap/go-models/employees"
-	"github.com/erneap/go-models/logs"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/erneap/go-pg-models/employees"
+	"github.com/erneap/go-pg-models/logs"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Crud Functions for Creating, Retrieving, updating and deleting authentication
-// log database records
+// log database records.  Each takes the caller's context, and is given the
+// default service deadline when the context has none.
 
 // CRUD Create Function
-func CreateLogEntry(dt time.Time, app string, lvl logs.DebugLevel, msg string) error {
-	logCol := config.GetCollection(config.DB, "authenticate", "logs")
-
+func CreateLogEntry(ctx context.Context, dt time.Time, app string,
+	lvl logs.DebugLevel, msg string) error {
 	// new log entry
 	entry := &logs.LogEntry{
 		ID:          primitive.NewObjectID(),
@@ -34,168 +30,152 @@ func CreateLogEntry(dt time.Time, app string, lvl logs.DebugLevel, msg string) e
 		Message:     msg,
 	}
 
-	_, err := logCol.InsertOne(context.TODO(), entry)
-	return err
+	store, err := getLogStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.CreateLogEntry(ctx, entry)
 }
 
 // CRUD Retrieve Functions - one, between dates for application, by application,
 // and all records.
-func GetLogEntry(id string) (*logs.LogEntry, error) {
-	logCol := config.GetCollection(config.DB, "authenticate", "logs")
-
-	logid, err := primitive.ObjectIDFromHex(id)
+func GetLogEntry(ctx context.Context, id string) (*logs.LogEntry, error) {
+	store, err := getLogStore(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	filter := bson.M{
-		"_id": logid,
-	}
-
-	var entry logs.LogEntry
-	if err = logCol.FindOne(context.TODO(), filter).Decode(&entry); err != nil {
-		return nil, err
-	}
-	return &entry, nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetLogEntry(ctx, id)
 }
 
-func GetLogEntriesByApplication(app string) ([]logs.LogEntry, error) {
-	var entries []logs.LogEntry
-
-	logCol := config.GetCollection(config.DB, "authenticate", "logs")
-
-	filter := bson.M{
-		"application": app,
-	}
-
-	cursor, err := logCol.Find(context.TODO(), filter)
+func GetLogEntriesByApplication(ctx context.Context,
+	app string) ([]logs.LogEntry, error) {
+	store, err := getLogStore(ctx)
 	if err != nil {
-		return entries, err
-	}
-
-	if err = cursor.All(context.TODO(), &entries); err != nil {
-		return entries, err
+		return nil, err
 	}
-
-	sort.Sort(logs.ByLogEntry(entries))
-	return entries, nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetLogEntries(ctx, app, time.Time{},
+		time.Time{})
 }
 
-func GetLogEntriesByApplicationAndDates(app string, begin, end time.Time) ([]logs.LogEntry, error) {
-	var entries []logs.LogEntry
-
-	logCol := config.GetCollection(config.DB, "authenticate", "logs")
-
-	filter := bson.M{
-		"application": app,
-		"datetime":    bson.M{"$gte": begin, "$lt": end},
-	}
-
-	cursor, err := logCol.Find(context.TODO(), filter)
+func GetLogEntriesByApplicationAndDates(ctx context.Context, app string,
+	begin, end time.Time) ([]logs.LogEntry, error) {
+	store, err := getLogStore(ctx)
 	if err != nil {
-		return entries, err
-	}
-
-	if err = cursor.All(context.TODO(), &entries); err != nil {
-		return entries, err
+		return nil, err
 	}
-
-	sort.Sort(logs.ByLogEntry(entries))
-	return entries, nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetLogEntries(ctx, app, begin, end)
 }
 
-func GetLogEntries(app string, begin, end time.Time) ([]logs.LogEntry, error) {
-	var entries []logs.LogEntry
-
-	logCol := config.GetCollection(config.DB, "authenticate", "logs")
-
-	filter := bson.M{}
-
-	cursor, err := logCol.Find(context.TODO(), filter)
+func GetLogEntries(ctx context.Context, app string, begin,
+	end time.Time) ([]logs.LogEntry, error) {
+	store, err := getLogStore(ctx)
 	if err != nil {
-		return entries, err
-	}
-
-	if err = cursor.All(context.TODO(), &entries); err != nil {
-		return entries, err
+		return nil, err
 	}
-
-	sort.Sort(logs.ByLogEntry(entries))
-	return entries, nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetLogEntries(ctx, "", time.Time{},
+		time.Time{})
 }
 
 // CRUD Update
-func UpdateLogEntry(entry logs.LogEntry) error {
-	logCol := config.GetCollection(config.DB, "authenticate", "logs")
-
-	filter := bson.M{
-		"_id": entry.ID,
+func UpdateLogEntry(ctx context.Context, entry logs.LogEntry) error {
+	store, err := getLogStore(ctx)
+	if err != nil {
+		return err
 	}
-
-	_, err := logCol.ReplaceOne(context.TODO(), filter, entry)
-	return err
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.UpdateLogEntry(ctx, entry)
 }
 
 // CRUD Delete functions - delete one by id, delete before date, delete by
 // application before date
-func DeleteLogEntry(id string) error {
-	logCol := config.GetCollection(config.DB, "authenticate", "logs")
-
-	logid, err := primitive.ObjectIDFromHex(id)
+func DeleteLogEntry(ctx context.Context, id string) error {
+	store, err := getLogStore(ctx)
 	if err != nil {
 		return err
 	}
-
-	filter := bson.M{
-		"_id": logid,
-	}
-
-	_, err = logCol.DeleteOne(context.TODO(), filter)
-	return err
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.DeleteLogEntry(ctx, id)
 }
 
-func DeleteLogEntriesBeforeDate(dt time.Time) error {
-	logCol := config.GetCollection(config.DB, "authenticate", "logs")
-
-	filter := bson.M{
-		"datetime": bson.M{"lt": dt},
+func DeleteLogEntriesBeforeDate(ctx context.Context, dt time.Time) error {
+	store, err := getLogStore(ctx)
+	if err != nil {
+		return err
 	}
-
-	_, err := logCol.DeleteMany(context.TODO(), filter)
-	return err
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.DeleteLogEntriesBefore(ctx, "", dt)
 }
 
-func DeleteLogEntriesByApplicationBeforeDate(app string, dt time.Time) error {
-	logCol := config.GetCollection(config.DB, "authenticate", "logs")
-
-	filter := bson.M{
-		"application": app,
-		"datetime":    bson.M{"lt": dt},
+func DeleteLogEntriesByApplicationBeforeDate(ctx context.Context, app string,
+	dt time.Time) error {
+	store, err := getLogStore(ctx)
+	if err != nil {
+		return err
 	}
-
-	_, err := logCol.DeleteMany(context.TODO(), filter)
-	return err
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.DeleteLogEntriesBefore(ctx, app, dt)
 }
 
 // miscellanous functions for log entry work
-func AddLogEntry(app string, lvl logs.DebugLevel, msg string) {
-	if config.LogLevel >= int(lvl) {
-		CreateLogEntry(time.Now().UTC(), app, lvl, msg)
+func AddLogEntry(ctx context.Context, app string, lvl logs.DebugLevel,
+	msg string) {
+	if getSettings().LogLevel >= int(lvl) {
+		CreateLogEntry(ctx, time.Now().UTC(), app, lvl, msg)
 	}
 }
 
+// AddLogEntry2 adds the entry to the portion's log, naming the employee as
+// the requestor.  A handler should call AddLogEntry2Context with the
+// request's context instead, so an administrator impersonating a user is
+// named.
 func AddLogEntry2(portion, category, title, msg string, emp *employees.Employee) error {
 	name := ""
-	site := "General"
 	if emp != nil {
 		name = emp.Name.GetLastFirst()
-		if !strings.EqualFold(portion, "authenticate") {
-			site = emp.SiteID
-		}
 	}
-	logBase := os.Getenv("LOG_DIR")
+	return addLogEntry2(portion, category, title, msg, name, emp)
+}
+
+// AddLogEntry2Context adds the log entry like AddLogEntry2, except that an
+// entry for a request made while an administrator impersonates a user names
+// the administrator as the requestor, noting the user impersonated.
+func AddLogEntry2Context(ctx context.Context, portion, category, title,
+	msg string, emp *employees.Employee) error {
+	imp := impersonationFrom(ctx)
+	if imp == nil {
+		return AddLogEntry2(portion, category, title, msg, emp)
+	}
+	msg += " (impersonating " + imp.User.GetLastFirst() + ")"
+	return addLogEntry2(portion, category, title, msg,
+		imp.Actor.GetLastFirst(), emp)
+}
+
+// addLogEntry2 writes the entry by the named requestor to the log of the
+// portion, in the directory of the employee's site or the General one.
+func addLogEntry2(portion, category, title, msg, name string,
+	emp *employees.Employee) error {
+	site := "General"
+	if emp != nil && !strings.EqualFold(portion, "authenticate") {
+		site = emp.SiteID
+	}
+	cfg := getSettings()
+	logBase := cfg.LogDir
 	chgDate := time.Now()
-	logLevel, _ := strconv.Atoi(os.Getenv("LOGLEVEL"))
+	logLevel := cfg.LogLevel
 
 	if logLevel < 1 || !strings.EqualFold(category, "debug") {
 		logPath := path.Join(logBase, site, portion)
@@ -228,12 +208,21 @@ func AddLogEntry2(portion, category, title, msg string, emp *employees.Employee)
 	return nil
 }
 
+// addAuditEntry records the authentication security event in the
+// authenticate log, naming the administrator when the request is made while
+// impersonating a user.  Users have no employee record, so the email address
+// names them in the message.
+func addAuditEntry(ctx context.Context, title, email, msg string) {
+	AddLogEntry2Context(ctx, "authenticate", "security", title,
+		email+": "+msg, nil)
+}
+
 func GetLogEntries2(portion string, year int, emp *employees.Employee) ([]logs.LogEntry2, error) {
 	site := "General"
 	if emp != nil && !strings.EqualFold(portion, "authenticate") {
 		site = emp.SiteID
 	}
-	logBase := os.Getenv("LOG_DIR")
+	logBase := getSettings().LogDir
 	if strings.TrimSpace(site) == "" {
 		site = "General"
 	}
@@ -0,0 +1,140 @@
+package svcs
+
+import (
+	"context"
+	"time"
+
+	"github.com/erneap/go-pg-models/logs"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/gin-gonic/gin"
+)
+
+// Login event functions.  The logins, failed logins, lockouts, password
+// resets and token refreshes are recorded against the account, with the
+// address and user agent of the client taken from the gin request the
+// service was called with.
+
+// ClientInfo is the address and user agent of the client making a request.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+type clientKey struct{}
+
+// ClientFromRequest returns the client making the gin request.
+func ClientFromRequest(c *gin.Context) ClientInfo {
+	client := ClientInfo{IPAddress: c.ClientIP()}
+	if c.Request != nil {
+		client.UserAgent = c.Request.UserAgent()
+	}
+	return client
+}
+
+// WithClient returns the context carrying the client making the gin request,
+// for a handler calling the services with the request's context rather than
+// the gin context itself.
+func WithClient(ctx context.Context, c *gin.Context) context.Context {
+	return context.WithValue(ctx, clientKey{}, ClientFromRequest(c))
+}
+
+// clientFrom returns the client given to the context by WithClient, or the
+// client of the gin context the service was called with.
+func clientFrom(ctx context.Context) ClientInfo {
+	if ctx == nil {
+		return ClientInfo{}
+	}
+	if client, ok := ctx.Value(clientKey{}).(ClientInfo); ok {
+		return client
+	}
+	if c, ok := ctx.Value(gin.ContextKey).(*gin.Context); ok {
+		return ClientFromRequest(c)
+	}
+	return ClientInfo{}
+}
+
+// recordLoginEvent records the event for the account.
+func recordLoginEvent(ctx context.Context, userid, email, eventType,
+	detail string) {
+	saveLoginEvent(ctx, &users.LoginEvent{
+		UserID:       userid,
+		EmailAddress: email,
+		Type:         eventType,
+		Detail:       detail,
+	})
+}
+
+// saveLoginEvent saves the event with the time and the client making the
+// request.  A failure to save it is logged rather than returned, so it never
+// blocks the login itself, and an application without a login event store
+// records nothing.
+func saveLoginEvent(ctx context.Context, event *users.LoginEvent) {
+	store, err := getLoginEventStore(ctx)
+	if err != nil {
+		return
+	}
+	client := clientFrom(ctx)
+	event.IPAddress = client.IPAddress
+	event.UserAgent = client.UserAgent
+	event.DateTime = time.Now().UTC()
+	tctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if err := store.CreateLoginEvent(tctx, event); err != nil {
+		AddLogEntry(ctx, "authenticate", logs.Minimal,
+			"saveLoginEvent: "+err.Error())
+	}
+}
+
+// GetLoginEvents returns the user's login events of the type between the
+// dates, newest first.  A blank user id or type means all of them, and zero
+// dates leave that side of the range open.
+func GetLoginEvents(ctx context.Context, userid, eventType string, begin,
+	end time.Time) ([]users.LoginEvent, error) {
+	store, err := getLoginEventStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetLoginEvents(ctx, userid, eventType, begin, end)
+}
+
+// GetLastLoginEvent returns the user's most recent login event of the type,
+// or of any type when it is blank, returning nil when there is none.
+func GetLastLoginEvent(ctx context.Context, userid,
+	eventType string) (*users.LoginEvent, error) {
+	events, err := GetLoginEvents(ctx, userid, eventType, time.Time{},
+		time.Time{})
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// CountLoginFailures returns the number of the user's failed logins since the
+// time, including those refused while the account was locked.
+func CountLoginFailures(ctx context.Context, userid string,
+	since time.Time) (int, error) {
+	events, err := GetLoginEvents(ctx, userid, "", since, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, event := range events {
+		if event.Type == users.LoginFailure || event.Type == users.LoginLockout {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// PurgeLoginEvents removes the login events recorded before the time.
+func PurgeLoginEvents(ctx context.Context, before time.Time) error {
+	store, err := getLoginEventStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.DeleteLoginEventsBefore(ctx, before)
+}
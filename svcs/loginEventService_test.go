@@ -0,0 +1,113 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/erneap/go-pg-models/users"
+	"github.com/gin-gonic/gin"
+)
+
+const loginPassword = "Login-Password-2026"
+
+func TestLoginFailuresRecorded(t *testing.T) {
+	cfg := useTestServices(t)
+	ctx := context.Background()
+	user, err := CreateUser(ctx, "pat.doe@example.com", "Pat", "", "Doe",
+		loginPassword)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	if _, err := Login(ctx, users.AuthenticationRequest{
+		EmailAddress: "nobody@example.com", Password: loginPassword,
+	}); !errors.Is(err, ErrLoginFailed) {
+		t.Fatalf("Login of an unknown address = %v, want %v", err,
+			ErrLoginFailed)
+	}
+	events, err := GetLoginEvents(ctx, "", users.LoginFailure, time.Time{},
+		time.Time{})
+	if err != nil || len(events) != 1 ||
+		events[0].EmailAddress != "nobody@example.com" || events[0].UserID != "" {
+		t.Fatalf("unknown address events %+v, %v", events, err)
+	}
+
+	for i := 0; i < cfg.LockoutThreshold; i++ {
+		if _, err := Login(ctx, users.AuthenticationRequest{
+			EmailAddress: user.EmailAddress, Password: "wrong",
+		}); err == nil {
+			t.Fatalf("Login %d with a wrong password succeeded", i+1)
+		}
+	}
+	if _, err := Login(ctx, users.AuthenticationRequest{
+		EmailAddress: user.EmailAddress, Password: loginPassword,
+	}); !errors.Is(err, users.ErrAccountLocked) {
+		t.Fatalf("Login while locked = %v, want %v", err, users.ErrAccountLocked)
+	}
+	last, err := GetLastLoginEvent(ctx, user.ID.Hex(), "")
+	if err != nil || last == nil || last.Type != users.LoginLockout {
+		t.Errorf("last event %+v, %v, want a lockout", last, err)
+	}
+
+	tests := []struct {
+		name  string
+		since time.Time
+		want  int
+	}{
+		{"all failures", time.Time{}, cfg.LockoutThreshold + 1},
+		{"none since", time.Now().Add(time.Minute), 0},
+	}
+	for _, tt := range tests {
+		count, err := CountLoginFailures(ctx, user.ID.Hex(), tt.since)
+		if err != nil || count != tt.want {
+			t.Errorf("%s: CountLoginFailures = %d, %v, want %d", tt.name, count,
+				err, tt.want)
+		}
+	}
+}
+
+func TestLoginSuccessRecordsClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		context func(c *gin.Context) context.Context
+	}{
+		{"request context", func(c *gin.Context) context.Context {
+			return WithClient(context.Background(), c)
+		}},
+		{"gin context", func(c *gin.Context) context.Context { return c }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestServices(t)
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/api/login", nil)
+			c.Request.RemoteAddr = "192.0.2.10:52000"
+			c.Request.Header.Set("User-Agent", "scheduler-test")
+			ctx := tt.context(c)
+			user, err := CreateUser(ctx, "pat.doe@example.com", "Pat", "", "Doe",
+				loginPassword)
+			if err != nil {
+				t.Fatalf("CreateUser: %v", err)
+			}
+
+			if _, err := Login(ctx, users.AuthenticationRequest{
+				EmailAddress: user.EmailAddress, Password: loginPassword,
+			}); err != nil {
+				t.Fatalf("Login: %v", err)
+			}
+			event, err := GetLastLoginEvent(ctx, user.ID.Hex(),
+				users.LoginSuccess)
+			if err != nil || event == nil {
+				t.Fatalf("no login recorded: %v", err)
+			}
+			if event.IPAddress != "192.0.2.10" ||
+				event.UserAgent != "scheduler-test" {
+				t.Errorf("login from %q with %q, want 192.0.2.10 with "+
+					"scheduler-test", event.IPAddress, event.UserAgent)
+			}
+		})
+	}
+}
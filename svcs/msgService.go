@@ -2,85 +2,63 @@ package svcs
 
 import (
 	"context"
-	"errors"
-	"sort"
 	"time"
 
-	"github.com/erneap/go-models/config"
-	"github.com/erneap/go-models/notifications"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/erneap/go-pg-models/notifications"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // notification retrieve functions (All, by Employee, and single)
 
-func GetAllMessages() ([]notifications.Notification, error) {
-	noteCol := config.GetCollection(config.DB, "scheduler", "notifications")
-
-	var list []notifications.Notification
-
-	cursor, err := noteCol.Find(context.TODO(), bson.M{})
+func GetAllMessages(ctx context.Context) ([]notifications.Notification, error) {
+	store, err := getNoteStore(ctx)
 	if err != nil {
-		return list, err
+		return nil, err
 	}
-
-	if err = cursor.All(context.TODO(), &list); err != nil {
-		return list, err
-	}
-
-	sort.Sort(notifications.ByNofication(list))
-
-	return list, nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetAllMessages(ctx)
 }
 
-func GetMessagesByEmployee(id string) ([]notifications.Notification, error) {
-	noteCol := config.GetCollection(config.DB, "scheduler", "notifications")
-
-	var list []notifications.Notification
-
-	filter := bson.M{
-		"to": id,
-	}
-
-	cursor, err := noteCol.Find(context.TODO(), filter)
+func GetMessagesByEmployee(ctx context.Context,
+	id string) ([]notifications.Notification, error) {
+	store, err := getNoteStore(ctx)
 	if err != nil {
-		return list, err
-	}
-
-	if err = cursor.All(context.TODO(), &list); err != nil {
-		return list, err
+		return nil, err
 	}
-
-	sort.Sort(notifications.ByNofication(list))
-
-	return list, nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetMessagesByEmployee(ctx, id)
 }
 
-func GetMessage(id string) (notifications.Notification, error) {
-	noteCol := config.GetCollection(config.DB, "scheduler", "notifications")
-
+func GetMessage(ctx context.Context,
+	id string) (notifications.Notification, error) {
 	var answer notifications.Notification
-	mid, err := primitive.ObjectIDFromHex(id)
+	store, err := getNoteStore(ctx)
 	if err != nil {
 		return answer, err
 	}
-
-	filter := bson.M{
-		"_id": mid,
-	}
-
-	err = noteCol.FindOne(context.TODO(), filter).Decode(&answer)
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	msg, err := store.GetMessage(ctx, id)
 	if err != nil {
 		return answer, err
 	}
-
-	return answer, nil
+	return *msg, nil
 }
 
 // Create function which include receipent, sender and message.
-// the identifier and date are automatic.
-func CreateMessage(to, from, message string) error {
-	noteCol := config.GetCollection(config.DB, "scheduler", "notifications")
+// the identifier and date are automatic.  Within InTransaction the message is
+// only kept if the rest of the transaction is.  A message sent as a user an
+// administrator is impersonating is sent from the administrator.
+func CreateMessage(ctx context.Context, to, from, message string) error {
+	from = attributedID(ctx, from)
+	store, err := getNoteStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
 	msg := &notifications.Notification{
 		ID:      primitive.NewObjectID(),
@@ -90,40 +68,19 @@ func CreateMessage(to, from, message string) error {
 		Message: message,
 	}
 
-	result, err := noteCol.InsertOne(context.TODO(), msg)
-	if err != nil {
-		return err
-	}
-	if result.InsertedID == primitive.NilObjectID {
-		return errors.New("not created")
-	}
-	return nil
+	return store.CreateMessage(ctx, msg)
 }
 
 // There is no update routine because messages can't be updated manually.
 
 // After the message is viewed, it will be acknowledged and removed
 // from the database.  This is the only delete routine.
-func DeleteMessage(id string) error {
-	noteCol := config.GetCollection(config.DB, "scheduler", "notifications")
-
-	mid, err := primitive.ObjectIDFromHex(id)
+func DeleteMessage(ctx context.Context, id string) error {
+	store, err := getNoteStore(ctx)
 	if err != nil {
 		return err
 	}
-
-	filter := bson.M{
-		"_id": mid,
-	}
-
-	result, err := noteCol.DeleteOne(context.TODO(), filter)
-	if err != nil {
-		return err
-	}
-
-	if result.DeletedCount <= 0 {
-		return errors.New("no message deleted")
-	}
-
-	return nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.DeleteMessage(ctx, id)
 }
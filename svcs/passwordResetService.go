@@ -0,0 +1,132 @@
+package svcs
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/erneap/go-pg-models/logs"
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/users"
+)
+
+// Password reset functions.  The reset token emailed to the user is only kept
+// as its hash in the user's ResetToken, and is cleared once used or replaced.
+
+var (
+	ErrTooManyRequests   = errors.New("too many requests, try again later")
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+)
+
+// resetRequests limits the reset emails sent to an address, and resetAttempts
+// the failed attempts to complete a reset for it.
+var (
+	resetRequests = newRateLimiter()
+	resetAttempts = newRateLimiter()
+)
+
+// StartPasswordReset gives the user with the email address a single-use reset
+// token and emails it, as a link when a reset url is configured and as a code
+// otherwise.  An unknown address isn't reported to the caller, so the reset
+// can't be used to discover accounts.
+func StartPasswordReset(ctx context.Context, email string) error {
+	cfg := getSettings()
+	if !resetRequests.allow(email, cfg.ResetRequestLimit, cfg.ResetRateWindow) {
+		addAuditEntry(ctx, "Password Reset", email, "request rate limited")
+		return ErrTooManyRequests
+	}
+
+	user, err := GetUserByEMail(ctx, email)
+	if errors.Is(err, stores.ErrNotFound) {
+		addAuditEntry(ctx, "Password Reset", email, "requested for unknown address")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	secret, hash, err := newSecret()
+	if err != nil {
+		return err
+	}
+	expires := time.Now().UTC().Add(cfg.PasswordResetTTL)
+	user.ResetToken = hash
+	user.ResetTokenExp = &expires
+	if err := UpdateUser(ctx, user); err != nil {
+		return err
+	}
+
+	body := "A password reset was requested for your account.\n\n"
+	if cfg.PasswordResetURL != "" {
+		link := cfg.PasswordResetURL + "?" + url.Values{
+			"email": {user.EmailAddress},
+			"token": {secret},
+		}.Encode()
+		body += "Use the following link to choose a new password:\n\n" + link
+	} else {
+		body += "Enter the following reset code to choose a new password:\n\n" +
+			secret
+	}
+	body += "\n\nThe reset expires at " + expires.Format(time.RFC1123) +
+		".  If you didn't request it, you can ignore this message."
+	if err := SendMail([]string{user.EmailAddress}, "Password Reset",
+		body); err != nil {
+		return err
+	}
+	addAuditEntry(ctx, "Password Reset", user.EmailAddress, "reset token sent")
+	return nil
+}
+
+// CompletePasswordReset sets the user's new password when the request's token
+// matches the unexpired reset token, then clears the token and ends the
+// user's sessions.  A password failing the application's password policy is
+// refused with a *users.PasswordError, leaving the token to be tried again.
+func CompletePasswordReset(ctx context.Context,
+	req users.PasswordResetRequest) error {
+	cfg := getSettings()
+	if !resetAttempts.allow(req.EmailAddress, cfg.ResetAttemptLimit,
+		cfg.ResetRateWindow) {
+		addAuditEntry(ctx, "Password Reset", req.EmailAddress,
+			"completion rate limited")
+		return ErrTooManyRequests
+	}
+
+	user, err := GetUserByEMail(ctx, req.EmailAddress)
+	if errors.Is(err, stores.ErrNotFound) {
+		addAuditEntry(ctx, "Password Reset", req.EmailAddress,
+			"completion for unknown address")
+		return ErrInvalidResetToken
+	} else if err != nil {
+		return err
+	}
+	if user.ResetToken == "" || user.ResetTokenExp == nil ||
+		time.Now().UTC().After(*user.ResetTokenExp) ||
+		subtle.ConstantTimeCompare([]byte(hashSecret(strings.TrimSpace(req.Token))),
+			[]byte(user.ResetToken)) != 1 {
+		addAuditEntry(ctx, "Password Reset", user.EmailAddress,
+			"invalid or expired token")
+		return ErrInvalidResetToken
+	}
+
+	if err := user.SetPasswordWithPolicy(req.Password,
+		GetPasswordPolicy(req.Application)); err != nil {
+		return err
+	}
+	user.ResetToken = ""
+	user.ResetTokenExp = nil
+	if err := UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	resetAttempts.reset(req.EmailAddress)
+	addAuditEntry(ctx, "Password Reset", user.EmailAddress, "password reset")
+	recordLoginEvent(ctx, user.ID.Hex(), user.EmailAddress, users.LoginReset,
+		"")
+
+	if err := LogoutAllSessions(ctx, user); err != nil {
+		AddLogEntry(ctx, req.Application, logs.Minimal,
+			"CompletePasswordReset: Sessions not revoked: "+err.Error())
+	}
+	return nil
+}
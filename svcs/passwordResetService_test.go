@@ -0,0 +1,147 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/erneap/go-pg-models/users"
+)
+
+const (
+	resetPassword    = "Reset-Password-2026"
+	resetNewPassword = "Chosen-Password-2026"
+)
+
+// resetUser creates the user with the email address, and the reset password,
+// in the services' user store.
+func resetUser(t *testing.T, email string) {
+	t.Helper()
+	ctx := context.Background()
+	user := &users.User{}
+	user.EmailAddress = email
+	if err := user.SetPassword(resetPassword); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	store, err := getUserStore(ctx)
+	if err != nil {
+		t.Fatalf("getUserStore: %v", err)
+	}
+	if err := store.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+}
+
+func TestPasswordResetSingleUse(t *testing.T) {
+	cfg := useTestServices(t)
+	box := useTestMailbox(t, cfg)
+	ctx := context.Background()
+	email := "reset-once@example.com"
+	resetUser(t, email)
+	if err := StartPasswordReset(ctx, email); err != nil {
+		t.Fatalf("StartPasswordReset: %v", err)
+	}
+	req := users.PasswordResetRequest{EmailAddress: email,
+		Password: resetNewPassword, Token: box.lastCode(t)}
+
+	if err := CompletePasswordReset(ctx, req); err != nil {
+		t.Fatalf("CompletePasswordReset: %v", err)
+	}
+	user, err := GetUserByEMail(ctx, email)
+	if err != nil {
+		t.Fatalf("GetUserByEMail: %v", err)
+	}
+	if err := user.Authenticate(resetNewPassword); err != nil {
+		t.Errorf("new password refused: %v", err)
+	}
+	if user.ResetToken != "" || user.ResetTokenExp != nil {
+		t.Error("reset token kept after use")
+	}
+	req.Password = resetPassword
+	if err := CompletePasswordReset(ctx, req); !errors.Is(err,
+		ErrInvalidResetToken) {
+		t.Errorf("second use of the token = %v, want %v", err,
+			ErrInvalidResetToken)
+	}
+}
+
+func TestPasswordResetRefusesTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		token func(code string) string
+	}{
+		{"expired token", -time.Minute, func(code string) string { return code }},
+		{"wrong token", time.Hour, func(code string) string { return code + "x" }},
+		{"no token", time.Hour, func(code string) string { return "" }},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := useTestServices(t)
+			box := useTestMailbox(t, cfg)
+			cfg.PasswordResetTTL = tt.ttl
+			ctx := context.Background()
+			email := "reset-refused-" + string(rune('a'+i)) + "@example.com"
+			resetUser(t, email)
+			if err := StartPasswordReset(ctx, email); err != nil {
+				t.Fatalf("StartPasswordReset: %v", err)
+			}
+			err := CompletePasswordReset(ctx, users.PasswordResetRequest{
+				EmailAddress: email, Password: resetNewPassword,
+				Token: tt.token(box.lastCode(t)),
+			})
+			if !errors.Is(err, ErrInvalidResetToken) {
+				t.Fatalf("CompletePasswordReset = %v, want %v", err,
+					ErrInvalidResetToken)
+			}
+			user, err := GetUserByEMail(ctx, email)
+			if err != nil {
+				t.Fatalf("GetUserByEMail: %v", err)
+			}
+			if err := user.Authenticate(resetPassword); err != nil {
+				t.Errorf("password changed by a refused reset: %v", err)
+			}
+		})
+	}
+}
+
+func TestPasswordResetRateLimits(t *testing.T) {
+	cfg := useTestServices(t)
+	box := useTestMailbox(t, cfg)
+	cfg.ResetRequestLimit = 2
+	cfg.ResetAttemptLimit = 2
+	ctx := context.Background()
+	email := "reset-limited@example.com"
+	resetUser(t, email)
+
+	for i := 0; i < cfg.ResetRequestLimit; i++ {
+		if err := StartPasswordReset(ctx, email); err != nil {
+			t.Fatalf("request %d: StartPasswordReset: %v", i+1, err)
+		}
+	}
+	if err := StartPasswordReset(ctx, email); !errors.Is(err,
+		ErrTooManyRequests) {
+		t.Errorf("request over the limit = %v, want %v", err,
+			ErrTooManyRequests)
+	}
+	if err := StartPasswordReset(ctx,
+		"reset-unknown@example.com"); err != nil {
+		t.Errorf("request for another address: %v", err)
+	}
+
+	code := box.lastCode(t)
+	for i := 0; i < cfg.ResetAttemptLimit; i++ {
+		err := CompletePasswordReset(ctx, users.PasswordResetRequest{
+			EmailAddress: email, Password: resetNewPassword, Token: "wrong"})
+		if !errors.Is(err, ErrInvalidResetToken) {
+			t.Fatalf("attempt %d = %v, want %v", i+1, err, ErrInvalidResetToken)
+		}
+	}
+	err := CompletePasswordReset(ctx, users.PasswordResetRequest{
+		EmailAddress: email, Password: resetNewPassword, Token: code})
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("attempt over the limit = %v, want %v", err,
+			ErrTooManyRequests)
+	}
+}
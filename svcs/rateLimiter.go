@@ -0,0 +1,50 @@
+package svcs
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter counts the events for each key within a sliding window.  It is
+// kept in memory, so each running process applies its limits on its own.
+type rateLimiter struct {
+	mutex  sync.Mutex
+	events map[string][]time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{events: make(map[string][]time.Time)}
+}
+
+// allow records an event for the key and reports whether it is within the
+// limit of events in the window.  A limit of zero or less turns limiting off.
+func (r *rateLimiter) allow(key string, limit int, window time.Duration) bool {
+	if limit <= 0 {
+		return true
+	}
+	key = strings.ToLower(key)
+	now := time.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var recent []time.Time
+	for _, at := range r.events[key] {
+		if now.Sub(at) < window {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) >= limit {
+		r.events[key] = recent
+		return false
+	}
+	r.events[key] = append(recent, now)
+	return true
+}
+
+// reset forgets the key's events.
+func (r *rateLimiter) reset(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.events, strings.ToLower(key))
+}
@@ -0,0 +1,84 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/erneap/go-pg-models/employees"
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/users"
+	"github.com/gin-gonic/gin"
+)
+
+// Scoped authorization functions.  A user's roles without a scope only reach
+// the team and site of the user's own employee record, so a supervisor acts
+// on the employees of their own site, while a role scoped to another team or
+// site, or to users.AnyTeam, grants it explicitly.
+
+var ErrForbidden = errors.New("not authorized for this team or site")
+
+// EmployeeScope returns the team and site of the employee.
+func EmployeeScope(emp *employees.Employee) users.Scope {
+	return users.Scope{TeamID: emp.TeamID.Hex(), SiteID: emp.SiteID}
+}
+
+// AuthorizeScope checks that the user has the application's permission over
+// the resource's team and site, judged from the user's own employee record,
+// returning ErrForbidden when it doesn't.  A user who isn't an employee only
+// has the permissions of roles scoped explicitly.
+func AuthorizeScope(ctx context.Context, user *users.User, app,
+	permission string, resource users.Scope) error {
+	var home users.Scope
+	emp, err := GetEmployeeByUserID(ctx, user.ID.Hex())
+	if err == nil {
+		home = EmployeeScope(emp)
+	} else if !errors.Is(err, stores.ErrNotFound) {
+		return err
+	}
+	if !user.CanFrom(app, permission, home, resource) {
+		addAuditEntry(ctx, "Authorization", user.EmailAddress,
+			fmt.Sprintf("%s denied for team %s site %s", permission,
+				resource.TeamID, resource.SiteID))
+		return ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizeEmployee checks that the user has the application's permission
+// over the employee, through AuthorizeScope with the employee's team and site.
+func AuthorizeEmployee(ctx context.Context, user *users.User, app,
+	permission string, emp *employees.Employee) error {
+	return AuthorizeScope(ctx, user, app, permission, EmployeeScope(emp))
+}
+
+// ScopeResolver returns the team and site of the resource a request touches,
+// for CheckScope.
+type ScopeResolver func(c *gin.Context) (users.Scope, error)
+
+// ScopeFromParams reads the resource's team and site from the named path
+// parameters.  A blank name leaves that part of the scope blank.
+func ScopeFromParams(teamParam, siteParam string) ScopeResolver {
+	return func(c *gin.Context) (users.Scope, error) {
+		var scope users.Scope
+		if teamParam != "" {
+			scope.TeamID = c.Param(teamParam)
+		}
+		if siteParam != "" {
+			scope.SiteID = c.Param(siteParam)
+		}
+		return scope, nil
+	}
+}
+
+// EmployeeScopeFromParam reads the employee named by the id in the path
+// parameter, returning the employee's team and site.
+func EmployeeScopeFromParam(param string) ScopeResolver {
+	return func(c *gin.Context) (users.Scope, error) {
+		emp, err := GetEmployee(c.Request.Context(), c.Param(param))
+		if err != nil {
+			return users.Scope{}, err
+		}
+		return EmployeeScope(emp), nil
+	}
+}
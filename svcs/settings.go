@@ -0,0 +1,119 @@
+package svcs
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/employees"
+	"github.com/erneap/go-pg-models/users"
+)
+
+// the application settings used by the jwt, email and log services, which
+// start as the configuration defaults until the application calls Configure.
+// The password policies are those set for particular applications, which
+// otherwise use the configured default policy.
+var (
+	settingsMutex    sync.RWMutex
+	settings         = config.Defaults()
+	passwordPolicies = make(map[string]users.PasswordPolicy)
+)
+
+// Configure gives the services the application's loaded configuration.  It
+// also sets whether the employee methods still convert legacy Data blobs, and
+// the lockout and password policies and password hasher used by the users'
+// methods.
+func Configure(cfg *config.Config) {
+	settingsMutex.Lock()
+	settings = cfg
+	employees.ConvertLegacyData = cfg.EmployeeLegacyData
+	users.DefaultLockout = lockoutPolicy(cfg)
+	users.DefaultPasswordPolicy = passwordPolicy(cfg)
+	users.DefaultPasswordHasher = passwordHasher(cfg)
+	settingsMutex.Unlock()
+	resetKeySet()
+}
+
+// lockoutPolicy returns the account lockout policy given by the settings.
+func lockoutPolicy(cfg *config.Config) users.LockoutPolicy {
+	threshold := uint(0)
+	if cfg.LockoutThreshold > 0 {
+		threshold = uint(cfg.LockoutThreshold)
+	}
+	return users.LockoutPolicy{
+		Threshold:   threshold,
+		Duration:    cfg.LockoutDuration,
+		ResetWindow: cfg.LockoutResetWindow,
+	}
+}
+
+// passwordPolicy returns the default password policy given by the settings.
+func passwordPolicy(cfg *config.Config) users.PasswordPolicy {
+	banned := append([]string(nil), users.CommonPasswords...)
+	for _, word := range strings.Split(cfg.PasswordBanned, ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			banned = append(banned, word)
+		}
+	}
+	return users.PasswordPolicy{
+		MinLength:     cfg.PasswordMinLength,
+		RequireUpper:  cfg.PasswordRequireUpper,
+		RequireLower:  cfg.PasswordRequireLower,
+		RequireDigit:  cfg.PasswordRequireDigit,
+		RequireSymbol: cfg.PasswordRequireSymbol,
+		Banned:        banned,
+		HistorySize:   cfg.PasswordHistory,
+		Expiry:        cfg.PasswordExpiry,
+	}
+}
+
+// passwordHasher returns the password hasher given by the settings.
+func passwordHasher(cfg *config.Config) users.PasswordHasher {
+	if strings.EqualFold(cfg.PasswordHasher, config.HasherArgon2id) {
+		return users.NewArgon2idHasher(uint32(cfg.Argon2Memory),
+			uint32(cfg.Argon2Iterations), uint8(cfg.Argon2Parallelism))
+	}
+	return users.BcryptHasher{Cost: cfg.BcryptCost}
+}
+
+// UsePasswordPolicy sets the password policy for the application's users, for
+// an application needing, for example, a different password expiry.
+func UsePasswordPolicy(app string, policy users.PasswordPolicy) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	passwordPolicies[strings.ToLower(app)] = policy
+}
+
+// GetPasswordPolicy returns the application's password policy, or the default
+// policy when it has none of its own.
+func GetPasswordPolicy(app string) users.PasswordPolicy {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	if policy, ok := passwordPolicies[strings.ToLower(app)]; ok {
+		return policy
+	}
+	return users.DefaultPasswordPolicy
+}
+
+func getSettings() *config.Config {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+	return settings
+}
+
+// withTimeout gives a service call the configured default deadline, unless
+// the caller's context already carries one.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	timeout := getSettings().ServiceTimeout
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
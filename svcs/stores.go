@@ -0,0 +1,220 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/stores"
+)
+
+// ErrNoStore is returned by the services when the application hasn't given
+// them a database client or stores.
+var ErrNoStore = errors.New("no store configured, call UseClient or UseStores")
+
+// The stores used by the service functions, set by the application through
+// UseClient or UseStores.
+var (
+	storeMutex sync.RWMutex
+	userStore  stores.UserStore
+	logStore   stores.LogStore
+	noteStore  stores.NotificationStore
+	empStore   stores.EmployeeStore
+	tokenStore stores.TokenStore
+	keyStore   stores.APIKeyStore
+	eventStore stores.LoginEventStore
+	unitOfWork stores.UnitOfWork
+)
+
+// UseClient sets the services to use the stores for the opened database
+// client, the postgres stores when it has a postgres connection and the mongo
+// stores otherwise, creating the indexes the mongo stores rely on.
+func UseClient(client *config.Client) {
+	if client.Postgres != nil {
+		UseStores(stores.NewPgUserStore(client.Postgres),
+			stores.NewPgLogStore(client.Postgres),
+			stores.NewPgNotificationStore(client.Postgres))
+		UseEmployeeStore(stores.NewPgEmployeeStore(client.Postgres))
+		UseTokenStore(stores.NewPgTokenStore(client.Postgres))
+		UseAPIKeyStore(stores.NewPgAPIKeyStore(client.Postgres))
+		UseLoginEventStore(stores.NewPgLoginEventStore(client.Postgres))
+		UseUnitOfWork(stores.NewPgUnitOfWork(client.Postgres))
+	} else if client.Mongo != nil {
+		UseStores(stores.NewMongoUserStore(client.Mongo),
+			stores.NewMongoLogStore(client.Mongo),
+			stores.NewMongoNotificationStore(client.Mongo))
+		UseEmployeeStore(stores.NewMongoEmployeeStore(client.Mongo))
+		UseTokenStore(stores.NewMongoTokenStore(client.Mongo))
+		UseAPIKeyStore(stores.NewMongoAPIKeyStore(client.Mongo))
+		UseLoginEventStore(stores.NewMongoLoginEventStore(client.Mongo))
+		UseUnitOfWork(stores.NewMongoUnitOfWork(client.Mongo))
+
+		ctx, cancel := withTimeout(context.Background())
+		defer cancel()
+		if err := stores.EnsureMongoIndexes(ctx, client.Mongo); err != nil {
+			log.Printf("UseClient: mongo indexes not created: %s", err)
+		}
+	}
+}
+
+// UseStores sets the storage behind the user, log and notification services,
+// so an application can choose its backend and unit tests can use the memory
+// stores.  A nil store leaves the current one in place.
+func UseStores(users stores.UserStore, logs stores.LogStore,
+	notes stores.NotificationStore) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	if users != nil {
+		userStore = users
+	}
+	if logs != nil {
+		logStore = logs
+	}
+	if notes != nil {
+		noteStore = notes
+	}
+}
+
+// UseEmployeeStore sets the storage behind the employee services.
+func UseEmployeeStore(employees stores.EmployeeStore) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	if employees != nil {
+		empStore = employees
+	}
+}
+
+// UseTokenStore sets the storage for the refresh tokens and the access token
+// revocation list.
+func UseTokenStore(tokens stores.TokenStore) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	if tokens != nil {
+		tokenStore = tokens
+	}
+}
+
+// UseAPIKeyStore sets the storage for the api keys.
+func UseAPIKeyStore(keys stores.APIKeyStore) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	if keys != nil {
+		keyStore = keys
+	}
+}
+
+// UseLoginEventStore sets the storage for the accounts' login events.
+func UseLoginEventStore(events stores.LoginEventStore) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	if events != nil {
+		eventStore = events
+	}
+}
+
+// UseUnitOfWork sets the transactions used by InTransaction.
+func UseUnitOfWork(uow stores.UnitOfWork) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+	if uow != nil {
+		unitOfWork = uow
+	}
+}
+
+// The store getters return the transaction's store when the context was
+// given by InTransaction, and the configured store otherwise.
+
+func getUserStore(ctx context.Context) (stores.UserStore, error) {
+	if tx := txStores(ctx); tx != nil && tx.Users != nil {
+		return tx.Users, nil
+	}
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	if userStore == nil {
+		return nil, ErrNoStore
+	}
+	return userStore, nil
+}
+
+func getLogStore(ctx context.Context) (stores.LogStore, error) {
+	if tx := txStores(ctx); tx != nil && tx.Logs != nil {
+		return tx.Logs, nil
+	}
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	if logStore == nil {
+		return nil, ErrNoStore
+	}
+	return logStore, nil
+}
+
+func getNoteStore(ctx context.Context) (stores.NotificationStore, error) {
+	if tx := txStores(ctx); tx != nil && tx.Notifications != nil {
+		return tx.Notifications, nil
+	}
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	if noteStore == nil {
+		return nil, ErrNoStore
+	}
+	return noteStore, nil
+}
+
+func getEmployeeStore(ctx context.Context) (stores.EmployeeStore, error) {
+	if tx := txStores(ctx); tx != nil && tx.Employees != nil {
+		return tx.Employees, nil
+	}
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	if empStore == nil {
+		return nil, ErrNoStore
+	}
+	return empStore, nil
+}
+
+func getTokenStore(ctx context.Context) (stores.TokenStore, error) {
+	if tx := txStores(ctx); tx != nil && tx.Tokens != nil {
+		return tx.Tokens, nil
+	}
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	if tokenStore == nil {
+		return nil, ErrNoStore
+	}
+	return tokenStore, nil
+}
+
+func getAPIKeyStore(ctx context.Context) (stores.APIKeyStore, error) {
+	if tx := txStores(ctx); tx != nil && tx.APIKeys != nil {
+		return tx.APIKeys, nil
+	}
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	if keyStore == nil {
+		return nil, ErrNoStore
+	}
+	return keyStore, nil
+}
+
+func getLoginEventStore(ctx context.Context) (stores.LoginEventStore, error) {
+	if tx := txStores(ctx); tx != nil && tx.LoginEvents != nil {
+		return tx.LoginEvents, nil
+	}
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	if eventStore == nil {
+		return nil, ErrNoStore
+	}
+	return eventStore, nil
+}
+
+func getUnitOfWork() (stores.UnitOfWork, error) {
+	storeMutex.RLock()
+	defer storeMutex.RUnlock()
+	if unitOfWork == nil {
+		return nil, ErrNoStore
+	}
+	return unitOfWork, nil
+}
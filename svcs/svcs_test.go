@@ -0,0 +1,133 @@
+package svcs
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/erneap/go-pg-models/config"
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// useTestServices gives the services fresh memory stores and the default
+// settings, with the logs written to the test's temporary directory.
+func useTestServices(t *testing.T) *config.Config {
+	t.Helper()
+	cfg := config.Defaults()
+	cfg.JWTSecret = "test-secret"
+	cfg.LogDir = t.TempDir()
+	cfg.BcryptCost = 4
+	Configure(cfg)
+	t.Cleanup(func() { Configure(config.Defaults()) })
+
+	s := stores.Stores{
+		Users:         stores.NewMemoryUserStore(),
+		Logs:          stores.NewMemoryLogStore(),
+		Notifications: stores.NewMemoryNotificationStore(),
+		Employees:     stores.NewMemoryEmployeeStore(),
+		Tokens:        stores.NewMemoryTokenStore(),
+		APIKeys:       stores.NewMemoryAPIKeyStore(),
+		LoginEvents:   stores.NewMemoryLoginEventStore(),
+	}
+	UseStores(s.Users, s.Logs, s.Notifications)
+	UseEmployeeStore(s.Employees)
+	UseTokenStore(s.Tokens)
+	UseAPIKeyStore(s.APIKeys)
+	UseLoginEventStore(s.LoginEvents)
+	UseUnitOfWork(stores.NewMemoryUnitOfWork(s))
+	return cfg
+}
+
+// mailbox is a fake smtp server keeping the messages sent to it.
+type mailbox struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+// useTestMailbox points the configuration's smtp settings at a fake server,
+// returning its mailbox.
+func useTestMailbox(t *testing.T, cfg *config.Config) *mailbox {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	box := &mailbox{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go box.serve(conn)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	cfg.SmtpServer = "localhost"
+	cfg.SmtpPort = port
+	cfg.SmtpFrom = "scheduler@example.com"
+	return box
+}
+
+func (b *mailbox) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ready")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch verb := strings.ToUpper(strings.Fields(line + " x")[0]); verb {
+		case "EHLO", "HELO":
+			text.PrintfLine("250-localhost")
+			text.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			text.PrintfLine("235 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			b.mutex.Lock()
+			b.messages = append(b.messages, string(data))
+			b.mutex.Unlock()
+			text.PrintfLine("250 ok")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+// lastCode returns the code sent in the last message, its last line without
+// a space.
+func (b *mailbox) lastCode(t *testing.T) string {
+	t.Helper()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.messages) == 0 {
+		t.Fatal("no message sent")
+	}
+	var code string
+	scanner := bufio.NewScanner(strings.NewReader(b.messages[len(b.messages)-1]))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" &&
+			!strings.Contains(line, " ") {
+			code = line
+		}
+	}
+	return code
+}
@@ -0,0 +1,248 @@
+package svcs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Refresh token functions.  A login is given a short-lived access token from
+// CreateToken and a long-lived refresh token, which is kept server side only
+// as its hash.  Each refresh revokes the refresh token used and issues its
+// replacement, so a refresh token can only be used once.
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token already used, " +
+		"all sessions from its login have been revoked")
+)
+
+// newSecret returns a random url-safe token for the client along with the
+// hash kept in the database.
+func newSecret() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	return secret, hashSecret(secret), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueTokens starts a new session for the user, returning its access token
+// and refresh token.
+func IssueTokens(ctx context.Context, user *users.User) (string, string,
+	error) {
+	store, err := getTokenStore(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	access, err := CreateToken(user)
+	if err != nil {
+		return "", "", err
+	}
+	token, refresh, err := newRefreshToken(user.ID.Hex(), "")
+	if err != nil {
+		return "", "", err
+	}
+	if err := store.CreateRefreshToken(ctx, token); err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// newRefreshToken returns a new refresh token for the user in the family, or
+// as the start of a new family when the family is blank, along with the
+// secret given to the client.
+func newRefreshToken(userid, family string) (*users.RefreshToken, string,
+	error) {
+	secret, hash, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now().UTC()
+	token := &users.RefreshToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userid,
+		Family:    family,
+		TokenHash: hash,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(getSettings().JWTRefreshTTL),
+	}
+	if token.Family == "" {
+		token.Family = token.ID.Hex()
+	}
+	return token, secret, nil
+}
+
+// RefreshTokens exchanges the refresh token for a new access token and a new
+// refresh token.  A refresh token which was already used revokes every token
+// descended from the same login, since either the client or a thief holds a
+// copy it shouldn't.
+func RefreshTokens(ctx context.Context, refresh string) (string, string,
+	error) {
+	store, err := getTokenStore(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	token, err := store.GetRefreshToken(ctx, hashSecret(refresh))
+	if errors.Is(err, stores.ErrNotFound) {
+		return "", "", ErrInvalidRefreshToken
+	} else if err != nil {
+		return "", "", err
+	}
+	now := time.Now().UTC()
+	if token.IsRevoked() {
+		if err := store.RevokeRefreshTokens(ctx, token.UserID, token.Family,
+			now); err != nil {
+			return "", "", err
+		}
+		recordLoginEvent(ctx, token.UserID, "", users.LoginRefresh,
+			ErrRefreshTokenReused.Error())
+		return "", "", ErrRefreshTokenReused
+	}
+	if token.IsExpired(now) {
+		return "", "", ErrInvalidRefreshToken
+	}
+	user, err := GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.Unverified {
+		return "", "", ErrEmailNotVerified
+	}
+
+	next, replacement, err := newRefreshToken(token.UserID, token.Family)
+	if err != nil {
+		return "", "", err
+	}
+	// the replacement is saved before the token is revoked, so a token used
+	// twice at once revokes its family including any replacement saved.
+	// Revoking only an active token is atomic, so only one use succeeds.
+	if err := store.CreateRefreshToken(ctx, next); err != nil {
+		return "", "", err
+	}
+	err = store.RevokeRefreshToken(ctx, token.ID.Hex(), next.ID.Hex(), now)
+	if errors.Is(err, stores.ErrConflict) {
+		// another request rotated the token first, so it was used twice.
+		if err := store.RevokeRefreshTokens(ctx, token.UserID, token.Family,
+			now); err != nil {
+			return "", "", err
+		}
+		recordLoginEvent(ctx, user.ID.Hex(), user.EmailAddress,
+			users.LoginRefresh, ErrRefreshTokenReused.Error())
+		return "", "", ErrRefreshTokenReused
+	} else if err != nil {
+		return "", "", err
+	}
+
+	access, err := CreateToken(user)
+	if err != nil {
+		return "", "", err
+	}
+	recordLoginEvent(ctx, user.ID.Hex(), user.EmailAddress, users.LoginRefresh,
+		"")
+	return access, replacement, nil
+}
+
+// RevokeRefreshToken ends the refresh token's session, as on logging out.
+func RevokeRefreshToken(ctx context.Context, refresh string) error {
+	store, err := getTokenStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	token, err := store.GetRefreshToken(ctx, hashSecret(refresh))
+	if errors.Is(err, stores.ErrNotFound) {
+		return ErrInvalidRefreshToken
+	} else if err != nil {
+		return err
+	}
+	return store.RevokeRefreshTokens(ctx, token.UserID, token.Family,
+		time.Now().UTC())
+}
+
+// RevokeAccessToken puts the access token on the revocation list, so
+// ValidateToken rejects it for the rest of its life.
+func RevokeAccessToken(ctx context.Context, claims *users.JWTClaim) error {
+	store, err := getTokenStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if claims.Id == "" {
+		return errors.New("token has no identifier")
+	}
+	return store.RevokeToken(ctx, &users.RevokedToken{
+		ID:        primitive.NewObjectID(),
+		TokenID:   claims.Id,
+		UserID:    claims.UserID,
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	})
+}
+
+// LogoutAllSessions revokes every refresh token of the user and every access
+// token issued to the user before now.
+func LogoutAllSessions(ctx context.Context, user *users.User) error {
+	store, err := getTokenStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// token issue times are whole seconds, so the revocation also covers a
+	// token issued later within the current second.  It lasts as long as the
+	// longest lived access token, which may be an impersonation token.
+	now := time.Now().UTC()
+	if err := store.RevokeRefreshTokens(ctx, user.ID.Hex(), "",
+		now); err != nil {
+		return err
+	}
+	cfg := getSettings()
+	ttl := cfg.JWTAccessTTL
+	if cfg.ImpersonationTTL > ttl {
+		ttl = cfg.ImpersonationTTL
+	}
+	return store.RevokeToken(ctx, &users.RevokedToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    user.ID.Hex(),
+		RevokedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+}
+
+// PurgeExpiredTokens removes the refresh tokens and revocations which have
+// expired.
+func PurgeExpiredTokens(ctx context.Context) error {
+	store, err := getTokenStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.DeleteExpiredTokens(ctx, time.Now().UTC())
+}
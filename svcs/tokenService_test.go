@@ -0,0 +1,88 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRefreshTokenReuse(t *testing.T) {
+	useTestServices(t)
+	ctx := context.Background()
+	user := testUser(t, "refresh@example.com", primitive.NilObjectID)
+
+	_, first, err := IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+	_, other, err := IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+	_, second, err := RefreshTokens(ctx, first)
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	_, third, err := RefreshTokens(ctx, second)
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		refresh string
+		wantErr error
+	}{
+		{"replaced token reused", first, ErrRefreshTokenReused},
+		{"latest token of the revoked family", third, ErrRefreshTokenReused},
+		{"token of another login", other, nil},
+		{"unknown token", "unknown", ErrInvalidRefreshToken},
+	}
+	for _, tt := range tests {
+		_, _, err := RefreshTokens(ctx, tt.refresh)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: RefreshTokens = %v, want %v", tt.name, err,
+				tt.wantErr)
+		}
+	}
+}
+
+func TestRefreshTokenConcurrentReuse(t *testing.T) {
+	useTestServices(t)
+	ctx := context.Background()
+	user := testUser(t, "concurrent@example.com", primitive.NilObjectID)
+	_, refresh, err := IssueTokens(ctx, user)
+	if err != nil {
+		t.Fatalf("IssueTokens: %v", err)
+	}
+
+	const uses = 8
+	var wg sync.WaitGroup
+	replacements := make([]string, uses)
+	errs := make([]error, uses)
+	for i := 0; i < uses; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, replacements[i], errs[i] = RefreshTokens(ctx, refresh)
+		}(i)
+	}
+	wg.Wait()
+
+	// whichever uses succeeded, the reuse revoked the whole family.
+	for i := 0; i < uses; i++ {
+		if errs[i] != nil {
+			if !errors.Is(errs[i], ErrRefreshTokenReused) {
+				t.Errorf("RefreshTokens = %v, want %v", errs[i],
+					ErrRefreshTokenReused)
+			}
+			continue
+		}
+		if _, _, err := RefreshTokens(ctx, replacements[i]); err == nil {
+			t.Error("replacement valid after the token was reused")
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package svcs
+
+import (
+	"context"
+
+	"github.com/erneap/go-pg-models/stores"
+)
+
+type txStoresKey struct{}
+
+// txStores returns the transaction's stores carried by the context, or nil
+// outside of a transaction.
+func txStores(ctx context.Context) *stores.Stores {
+	if ctx == nil {
+		return nil
+	}
+	tx, _ := ctx.Value(txStoresKey{}).(*stores.Stores)
+	return tx
+}
+
+// InTransaction runs the function as a single unit of work.  The service
+// functions called with the context given to the function use the
+// transaction, so their writes are kept together when the function returns
+// nil and discarded together when it returns an error.  A call made within a
+// transaction joins it.
+func InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txStores(ctx) != nil {
+		return fn(ctx)
+	}
+	uow, err := getUnitOfWork()
+	if err != nil {
+		return err
+	}
+	return uow.Do(ctx, func(ctx context.Context, s stores.Stores) error {
+		return fn(context.WithValue(ctx, txStoresKey{}, &s))
+	})
+}
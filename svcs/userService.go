@@ -2,124 +2,147 @@ package svcs
 
 import (
 	"context"
+	"errors"
 
-	"github.com/erneap/go-models/config"
-	"github.com/erneap/go-models/users"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/users"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Crud Functions for Creating, Retrieving, updating and deleting user database
-// records
+// records.  Each takes the caller's context, and is given the default service
+// deadline when the context has none.
 
-// CRUD Create Function - New User
+// ErrUserExists is returned when creating an account for an email address
+// which already has one.
+var ErrUserExists = errors.New("an account already exists for this email address")
 
-func CreateUser(email, first, middle, last, password string) *users.User {
-	userCol := config.GetCollection(config.DB, "authenticate", "users")
+// CRUD Create Function - New User.  An existing account for the email address
+// is never changed, the call returning ErrUserExists instead, and a password
+// failing the default policy is refused with a *users.PasswordError.
 
-	filter := bson.M{
-		"emailAddress": email,
+func CreateUser(ctx context.Context, email, first, middle, last,
+	password string) (*users.User, error) {
+	store, err := getUserStore(ctx)
+	if err != nil {
+		return nil, err
 	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	var user users.User
-	if err := userCol.FindOne(context.TODO(), filter).Decode(&user); err != nil {
-		user = users.User{
-			ID:           primitive.NewObjectID(),
-			EmailAddress: email,
-			FirstName:    first,
-			MiddleName:   middle,
-			LastName:     last,
-		}
-		user.SetPassword(password)
-		userCol.InsertOne(context.TODO(), user)
-	} else {
-		user.EmailAddress = email
-		user.FirstName = first
-		user.MiddleName = middle
-		user.LastName = last
-		user.SetPassword(password)
-
-		userCol.ReplaceOne(context.TODO(), filter, user)
+	if _, err := store.GetUserByEmail(ctx, email); err == nil {
+		return nil, ErrUserExists
+	} else if !errors.Is(err, stores.ErrNotFound) {
+		return nil, err
+	}
+	user := &users.User{Identity: users.Identity{
+		ID:           primitive.NewObjectID(),
+		EmailAddress: email,
+		FirstName:    first,
+		MiddleName:   middle,
+		LastName:     last,
+	}}
+	if err := user.SetPassword(password); err != nil {
+		return nil, err
+	}
+	if err := store.CreateUser(ctx, user); errors.Is(err, stores.ErrDuplicate) {
+		return nil, ErrUserExists
+	} else if err != nil {
+		return nil, err
 	}
-	return &user
+	return user, nil
 }
 
 // Retrieve Functions for getting a user or users based on need.
-func GetUserByID(id string) (*users.User, error) {
-	userCol := config.GetCollection(config.DB, "authenticate", "users")
-	userid, err := primitive.ObjectIDFromHex(id)
+func GetUserByID(ctx context.Context, id string) (*users.User, error) {
+	store, err := getUserStore(ctx)
 	if err != nil {
 		return nil, err
 	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetUserByID(ctx, id)
+}
 
-	filter := bson.M{
-		"_id": userid,
-	}
-
-	var user users.User
-	err = userCol.FindOne(context.TODO(), filter).Decode(&user)
+func GetUserByEMail(ctx context.Context, email string) (*users.User, error) {
+	store, err := getUserStore(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetUserByEmail(ctx, email)
 }
 
-func GetUserByEMail(email string) (*users.User, error) {
-	userCol := config.GetCollection(config.DB, "authenticate", "users")
-
-	filter := bson.M{
-		"emailAddress": email,
-	}
-
-	var user users.User
-	err := userCol.FindOne(context.TODO(), filter).Decode(&user)
+func GetUsers(ctx context.Context) ([]users.User, error) {
+	store, err := getUserStore(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.GetUsers(ctx)
 }
 
-func GetUsers() ([]users.User, error) {
-	var users []users.User
-
-	userCol := config.GetCollection(config.DB, "authenticate", "users")
-
-	cursor, err := userCol.Find(context.TODO(), bson.M{})
+// CRUD Update Function, which advances the user's version or returns a
+// stores.ConflictError when the user was changed since it was read.  Changed
+// workgroups replace the user's roles, a malformed one refusing the update.
+func UpdateUser(ctx context.Context, user *users.User) error {
+	store, err := getUserStore(ctx)
 	if err != nil {
-		return users, err
+		return err
 	}
-
-	if err = cursor.All(context.TODO(), &users); err != nil {
-		return users, err
+	if err := user.SyncRoles(); err != nil {
+		return err
 	}
-	return users, nil
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.UpdateUser(ctx, user)
 }
 
-// CRUD Update Function
-func UpdateUser(user users.User) error {
-	userCol := config.GetCollection(config.DB, "authenticate", "users")
-
-	filter := bson.M{
-		"_id": user.ID,
+// ChangePassword sets the user's password under the application's password
+// policy and saves the user.  A password failing the policy is refused with a
+// *users.PasswordError listing its problems.
+func ChangePassword(ctx context.Context, user *users.User, app,
+	passwd string) error {
+	if err := user.SetPasswordWithPolicy(passwd,
+		GetPasswordPolicy(app)); err != nil {
+		return err
+	}
+	if err := UpdateUser(ctx, user); err != nil {
+		return err
 	}
+	addAuditEntry(ctx, "Password Change", user.EmailAddress, "password changed")
+	return nil
+}
 
-	_, err := userCol.ReplaceOne(context.TODO(), filter, user)
-	return err
+// UnlockUser clears the user's failed logins and lockout, recording the
+// administrator who unlocked the account.
+func UnlockUser(ctx context.Context, id, admin string) (*users.User, error) {
+	var user *users.User
+	err := RetryOnConflict(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		user.Unlock()
+		return UpdateUser(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	addAuditEntry(ctx, "Account Unlock", user.EmailAddress, "unlocked by "+admin)
+	return user, nil
 }
 
 // CRUD Delete Function
-func DeleteUser(id string) error {
-	userCol := config.GetCollection(config.DB, "authenticate", "users")
-
-	userid, err := primitive.ObjectIDFromHex(id)
+func DeleteUser(ctx context.Context, id string) error {
+	store, err := getUserStore(ctx)
 	if err != nil {
 		return err
 	}
-
-	filter := bson.M{
-		"_id": userid,
-	}
-
-	_, err = userCol.DeleteOne(context.TODO(), filter)
-	return err
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return store.DeleteUser(ctx, id)
 }
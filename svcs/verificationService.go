@@ -0,0 +1,349 @@
+package svcs
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/erneap/go-pg-models/stores"
+	"github.com/erneap/go-pg-models/users"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Email verification and invitation functions.  A self-registered account
+// and an account an administrator invites are both unverified, and can't be
+// given tokens, until the verify token emailed to the address comes back.
+// Only the token's hash is kept, in the user's VerifyToken.
+
+var (
+	ErrEmailNotVerified   = errors.New("email address not verified")
+	ErrInvalidVerifyToken = errors.New("invalid or expired verification token")
+)
+
+// verifyRequests limits the verification emails resent to an address, and
+// verifyAttempts the failed attempts to verify it.
+var (
+	verifyRequests = newRateLimiter()
+	verifyAttempts = newRateLimiter()
+)
+
+// InviteUser creates an unverified account for the invited email address with
+// the workgroups the administrator gives it, and emails the invitation.  The
+// invited user chooses a password on accepting it.  Inviting an address whose
+// account hasn't been verified replaces that account, along with any
+// password someone registered it with, while a verified address is refused
+// with ErrUserExists.  A malformed workgroup refuses the invitation.
+func InviteUser(ctx context.Context, req users.InvitationRequest,
+	by string) (*users.User, error) {
+	roles, malformed := users.ParseRoles(req.Workgroups)
+	if len(malformed) > 0 {
+		return nil, fmt.Errorf("malformed workgroups: %s",
+			strings.Join(malformed, ", "))
+	}
+	user, invited, err := unverifiedUser(ctx, req.EmailAddress)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+	user.FirstName = req.FirstName
+	user.MiddleName = req.MiddleName
+	user.LastName = req.LastName
+	user.SetRoles(roles)
+
+	cfg := getSettings()
+	secret, err := newVerifyToken(user, cfg.InviteTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveUnverifiedUser(ctx, user, invited); err != nil {
+		return nil, err
+	}
+	body := "You have been invited to open an account.\n\n" +
+		tokenInstructions(cfg.InviteURL, user.EmailAddress, secret,
+			"accept the invitation and choose your password") +
+		"\n\nThe invitation expires at " +
+		user.VerifyTokenExp.Format(time.RFC1123) + "."
+	if err := SendMail([]string{user.EmailAddress}, "Invitation",
+		body); err != nil {
+		return nil, err
+	}
+	addAuditEntry(ctx, "Invitation", user.EmailAddress, "invited by "+by)
+	return user, nil
+}
+
+// AcceptInvitation sets the invited user's password when the request's token
+// matches the unexpired invitation, verifying the account.  It also sets the
+// password of a registration which replaced another, from the token emailed
+// by RegisterUser.  A password failing the application's password policy is
+// refused with a *users.PasswordError, leaving the invitation to be tried
+// again.
+func AcceptInvitation(ctx context.Context,
+	req users.AcceptInvitationRequest) (*users.User, error) {
+	user, err := checkVerifyToken(ctx, "Invitation", req.EmailAddress,
+		req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if err := user.SetPasswordWithPolicy(req.Password,
+		GetPasswordPolicy(req.Application)); err != nil {
+		return nil, err
+	}
+	if err := verified(ctx, user); err != nil {
+		return nil, err
+	}
+	addAuditEntry(ctx, "Invitation", user.EmailAddress, "accepted")
+	return user, nil
+}
+
+// RegisterUser creates the self-registered user's account, unverified, and
+// emails the verify token to its address.  A verified or invited address is
+// refused with ErrUserExists, and a password failing the application's
+// password policy with a *users.PasswordError.
+//
+// Registering an address whose self-registered account hasn't been verified
+// replaces that account, so someone registering another's address first
+// can't keep the owner out.  Neither registration's password is kept, since
+// either could be someone else's: the replaced account has no password and
+// the email to the address asks for one, as an invitation does, so only the
+// address's owner can choose it.
+func RegisterUser(ctx context.Context,
+	req users.AddUserRequest) (*users.User, error) {
+	user, registered, err := unverifiedUser(ctx, req.EmailAddress)
+	if err != nil {
+		return nil, err
+	}
+	if !registered && user.Password == "" {
+		return nil, ErrUserExists
+	}
+	user.Password = ""
+	user.FirstName = req.FirstName
+	user.MiddleName = req.MiddleName
+	user.LastName = req.LastName
+	if err := user.SetPasswordWithPolicy(req.Password,
+		GetPasswordPolicy(req.Application)); err != nil {
+		return nil, err
+	}
+	if !registered {
+		if err := replaceRegistration(ctx, user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	secret, err := newVerifyToken(user, getSettings().VerifyTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveUnverifiedUser(ctx, user, registered); err != nil {
+		return nil, err
+	}
+	if err := sendVerification(user, secret); err != nil {
+		return nil, err
+	}
+	addAuditEntry(ctx, "Registration", user.EmailAddress, "registered")
+	return user, nil
+}
+
+// replaceRegistration saves the registration replacing an unverified one
+// without its password, and emails the address a token for choosing the
+// password with AcceptInvitation.
+func replaceRegistration(ctx context.Context, user *users.User) error {
+	user.Password = ""
+	user.PasswordHistory = nil
+	cfg := getSettings()
+	secret, err := newVerifyToken(user, cfg.VerifyTokenTTL)
+	if err != nil {
+		return err
+	}
+	if err := saveUnverifiedUser(ctx, user, false); err != nil {
+		return err
+	}
+	body := "Your email address was registered again before the account " +
+		"was verified.  So that only you can choose its password, " +
+		"please choose it now.\n\n" +
+		tokenInstructions(cfg.InviteURL, user.EmailAddress, secret,
+			"verify your email address and choose your password") +
+		"\n\nThe code expires at " + user.VerifyTokenExp.Format(time.RFC1123) +
+		".  If you didn't register, you can ignore this message."
+	if err := SendMail([]string{user.EmailAddress},
+		"Verify Your Email Address", body); err != nil {
+		return err
+	}
+	addAuditEntry(ctx, "Registration", user.EmailAddress,
+		"registered again, password to be chosen from the email")
+	return nil
+}
+
+// ResendVerification emails a new verify token to the unverified user with
+// the email address.  An unknown or verified address isn't reported to the
+// caller.
+func ResendVerification(ctx context.Context, email string) error {
+	cfg := getSettings()
+	if !verifyRequests.allow(email, cfg.ResetRequestLimit, cfg.ResetRateWindow) {
+		addAuditEntry(ctx, "Registration", email, "verification rate limited")
+		return ErrTooManyRequests
+	}
+	user, err := GetUserByEMail(ctx, email)
+	if errors.Is(err, stores.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !user.Unverified || user.Password == "" {
+		return nil
+	}
+	secret, err := newVerifyToken(user, cfg.VerifyTokenTTL)
+	if err != nil {
+		return err
+	}
+	if err := UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	return sendVerification(user, secret)
+}
+
+// VerifyEmail verifies the self-registered user's account when the request's
+// token matches the unexpired verify token.  An invited account has no
+// password until its invitation is accepted, so its token is refused here.
+func VerifyEmail(ctx context.Context, req users.VerifyEmailRequest) error {
+	user, err := checkVerifyToken(ctx, "Registration", req.EmailAddress,
+		req.Token)
+	if err != nil {
+		return err
+	}
+	if user.Password == "" {
+		addAuditEntry(ctx, "Registration", user.EmailAddress,
+			"invitation token used to verify")
+		return ErrInvalidVerifyToken
+	}
+	if err := verified(ctx, user); err != nil {
+		return err
+	}
+	addAuditEntry(ctx, "Registration", user.EmailAddress, "email verified")
+	return nil
+}
+
+// newVerifyToken gives the user a new verify token lasting the ttl,
+// returning the token to send.
+func newVerifyToken(user *users.User, ttl time.Duration) (string, error) {
+	secret, hash, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+	expires := time.Now().UTC().Add(ttl)
+	user.VerifyToken = hash
+	user.VerifyTokenExp = &expires
+	return secret, nil
+}
+
+// checkVerifyToken returns the unverified user with the email address when
+// the token matches the user's unexpired verify token.
+func checkVerifyToken(ctx context.Context, title, email,
+	token string) (*users.User, error) {
+	cfg := getSettings()
+	if !verifyAttempts.allow(email, cfg.ResetAttemptLimit, cfg.ResetRateWindow) {
+		addAuditEntry(ctx, title, email, "verification rate limited")
+		return nil, ErrTooManyRequests
+	}
+	user, err := GetUserByEMail(ctx, email)
+	if errors.Is(err, stores.ErrNotFound) {
+		addAuditEntry(ctx, title, email, "verification for unknown address")
+		return nil, ErrInvalidVerifyToken
+	} else if err != nil {
+		return nil, err
+	}
+	if !user.Unverified || user.VerifyToken == "" ||
+		user.VerifyTokenExp == nil ||
+		time.Now().UTC().After(*user.VerifyTokenExp) ||
+		subtle.ConstantTimeCompare([]byte(hashSecret(strings.TrimSpace(token))),
+			[]byte(user.VerifyToken)) != 1 {
+		addAuditEntry(ctx, title, user.EmailAddress, "invalid or expired token")
+		return nil, ErrInvalidVerifyToken
+	}
+	verifyAttempts.reset(email)
+	return user, nil
+}
+
+// verified marks the user's account verified and saves it.
+func verified(ctx context.Context, user *users.User) error {
+	user.Unverified = false
+	user.VerifyToken = ""
+	user.VerifyTokenExp = nil
+	return UpdateUser(ctx, user)
+}
+
+func sendVerification(user *users.User, secret string) error {
+	body := "Please confirm the email address of your new account.\n\n" +
+		tokenInstructions(getSettings().EmailVerifyURL, user.EmailAddress,
+			secret, "verify your email address") +
+		"\n\nThe code expires at " + user.VerifyTokenExp.Format(time.RFC1123) +
+		".  If you didn't register, you can ignore this message."
+	return SendMail([]string{user.EmailAddress}, "Verify Your Email Address",
+		body)
+}
+
+// tokenInstructions tells the user how to use the emailed token, as a link
+// when the url is configured and as a code otherwise.
+func tokenInstructions(link, email, secret, purpose string) string {
+	if link != "" {
+		return "Use the following link to " + purpose + ":\n\n" + link + "?" +
+			url.Values{
+				"email": {email},
+				"token": {secret},
+			}.Encode()
+	}
+	return "Enter the following code to " + purpose + ":\n\n" + secret
+}
+
+// unverifiedUser returns a new unverified account for the email address,
+// reporting that it is new, or, in place of an account which hasn't been
+// verified, an unverified account keeping only its id and version.  An
+// address with a verified account is refused with ErrUserExists.
+func unverifiedUser(ctx context.Context,
+	email string) (*users.User, bool, error) {
+	current, err := GetUserByEMail(ctx, email)
+	if errors.Is(err, stores.ErrNotFound) {
+		return &users.User{Identity: users.Identity{
+			ID:           primitive.NewObjectID(),
+			EmailAddress: email,
+			Unverified:   true,
+		}}, true, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	if !current.Unverified {
+		return nil, false, ErrUserExists
+	}
+	return &users.User{Identity: users.Identity{
+		ID:           current.ID,
+		EmailAddress: current.EmailAddress,
+		Password:     current.Password,
+		Unverified:   true,
+		Version:      current.Version,
+	}}, false, nil
+}
+
+// saveUnverifiedUser creates the new unverified account, or replaces the
+// unverified account it takes the place of.  An account created for the
+// address at the same time is refused with ErrUserExists.
+func saveUnverifiedUser(ctx context.Context, user *users.User,
+	created bool) error {
+	if !created {
+		return UpdateUser(ctx, user)
+	}
+	store, err := getUserStore(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	if err := store.CreateUser(ctx, user); errors.Is(err, stores.ErrDuplicate) {
+		return ErrUserExists
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
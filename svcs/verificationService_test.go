@@ -0,0 +1,217 @@
+package svcs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/erneap/go-pg-models/users"
+)
+
+const (
+	ownerPassword    = "Owner-Password-2026"
+	squatterPassword = "Squatter-Password-2026"
+)
+
+func register(t *testing.T, box *mailbox, email,
+	password string) (*users.User, string) {
+	t.Helper()
+	user, err := RegisterUser(context.Background(), users.AddUserRequest{
+		EmailAddress: email,
+		FirstName:    "Pat",
+		LastName:     "Doe",
+		Password:     password,
+	})
+	if err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	return user, box.lastCode(t)
+}
+
+func invite(t *testing.T, box *mailbox, email string) (*users.User, string) {
+	t.Helper()
+	user, err := InviteUser(context.Background(), users.InvitationRequest{
+		EmailAddress: email,
+		FirstName:    "Pat",
+		LastName:     "Doe",
+		Workgroups:   []string{"scheduler-employee"},
+	}, "admin@example.com")
+	if err != nil {
+		t.Fatalf("InviteUser: %v", err)
+	}
+	return user, box.lastCode(t)
+}
+
+func TestVerifyEmailRefusesInvitation(t *testing.T) {
+	cfg := useTestServices(t)
+	box := useTestMailbox(t, cfg)
+	ctx := context.Background()
+	email := "invited@example.com"
+	_, token := invite(t, box, email)
+
+	err := VerifyEmail(ctx, users.VerifyEmailRequest{EmailAddress: email,
+		Token: token})
+	if !errors.Is(err, ErrInvalidVerifyToken) {
+		t.Fatalf("VerifyEmail with an invitation = %v, want %v", err,
+			ErrInvalidVerifyToken)
+	}
+	user, err := GetUserByEMail(ctx, email)
+	if err != nil || !user.Unverified {
+		t.Fatalf("invited account verified without a password: %v", err)
+	}
+	if _, err := AcceptInvitation(ctx, users.AcceptInvitationRequest{
+		EmailAddress: email, Password: ownerPassword, Token: token,
+	}); err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+}
+
+func TestSquattedRegistrationReplaced(t *testing.T) {
+	tests := []struct {
+		name     string
+		takeOver func(t *testing.T, box *mailbox, email string) string
+	}{
+		{"registration", func(t *testing.T, box *mailbox, email string) string {
+			_, token := register(t, box, email, ownerPassword)
+			if _, err := AcceptInvitation(context.Background(),
+				users.AcceptInvitationRequest{EmailAddress: email,
+					Password: ownerPassword, Token: token}); err != nil {
+				t.Fatalf("AcceptInvitation: %v", err)
+			}
+			return ownerPassword
+		}},
+		{"invitation", func(t *testing.T, box *mailbox, email string) string {
+			_, token := invite(t, box, email)
+			if _, err := AcceptInvitation(context.Background(),
+				users.AcceptInvitationRequest{EmailAddress: email,
+					Password: ownerPassword, Token: token}); err != nil {
+				t.Fatalf("AcceptInvitation: %v", err)
+			}
+			return ownerPassword
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := useTestServices(t)
+			box := useTestMailbox(t, cfg)
+			ctx := context.Background()
+			email := "owner-" + tt.name + "@example.com"
+			squatter, squatterToken := register(t, box, email, squatterPassword)
+
+			password := tt.takeOver(t, box, email)
+
+			user, err := GetUserByEMail(ctx, email)
+			if err != nil {
+				t.Fatalf("GetUserByEMail: %v", err)
+			}
+			if user.ID != squatter.ID || user.Unverified {
+				t.Fatalf("account not replaced in place: %+v", user.Identity)
+			}
+			if err := user.Authenticate(squatterPassword); err == nil {
+				t.Error("squatter's password still accepted")
+			}
+			if err := user.Authenticate(password); err != nil {
+				t.Errorf("owner's password refused: %v", err)
+			}
+			err = VerifyEmail(ctx, users.VerifyEmailRequest{EmailAddress: email,
+				Token: squatterToken})
+			if !errors.Is(err, ErrInvalidVerifyToken) {
+				t.Errorf("squatter's token = %v, want %v", err,
+					ErrInvalidVerifyToken)
+			}
+		})
+	}
+}
+
+func TestReregistrationKeepsNeitherPassword(t *testing.T) {
+	cfg := useTestServices(t)
+	box := useTestMailbox(t, cfg)
+	ctx := context.Background()
+	email := "pat.doe@example.com"
+	_, ownerToken := register(t, box, email, ownerPassword)
+	_, mailedToken := register(t, box, email, squatterPassword)
+
+	user, err := GetUserByEMail(ctx, email)
+	if err != nil {
+		t.Fatalf("GetUserByEMail: %v", err)
+	}
+	if user.Password != "" || !user.Unverified {
+		t.Fatalf("replaced registration kept a password: %+v", user.Identity)
+	}
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"replaced registration's token", ownerToken},
+		{"emailed token used to verify", mailedToken},
+	}
+	for _, tt := range tests {
+		err := VerifyEmail(ctx, users.VerifyEmailRequest{EmailAddress: email,
+			Token: tt.token})
+		if !errors.Is(err, ErrInvalidVerifyToken) {
+			t.Errorf("%s: VerifyEmail = %v, want %v", tt.name, err,
+				ErrInvalidVerifyToken)
+		}
+	}
+
+	user, err = AcceptInvitation(ctx, users.AcceptInvitationRequest{
+		EmailAddress: email, Password: ownerPassword, Token: mailedToken,
+	})
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	if err := user.Authenticate(squatterPassword); err == nil {
+		t.Error("second registration's password accepted")
+	}
+	if err := user.Authenticate(ownerPassword); err != nil {
+		t.Errorf("chosen password refused: %v", err)
+	}
+}
+
+func TestRegisterRefusesAccounts(t *testing.T) {
+	cfg := useTestServices(t)
+	box := useTestMailbox(t, cfg)
+	ctx := context.Background()
+	invite(t, box, "invited@example.com")
+	if _, err := CreateUser(ctx, "verified@example.com", "Pat", "", "Doe",
+		ownerPassword); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	tests := []struct {
+		name  string
+		email string
+	}{
+		{"pending invitation", "invited@example.com"},
+		{"verified account", "verified@example.com"},
+	}
+	for _, tt := range tests {
+		_, err := RegisterUser(ctx, users.AddUserRequest{
+			EmailAddress: tt.email, FirstName: "Pat", LastName: "Doe",
+			Password: squatterPassword,
+		})
+		if !errors.Is(err, ErrUserExists) {
+			t.Errorf("%s: RegisterUser = %v, want %v", tt.name, err,
+				ErrUserExists)
+		}
+	}
+	if _, err := CreateUser(ctx, "verified@example.com", "Pat", "", "Doe",
+		ownerPassword); !errors.Is(err, ErrUserExists) {
+		t.Errorf("duplicate CreateUser = %v, want %v", err, ErrUserExists)
+	}
+}
+
+func TestInviteRefusesMalformedWorkgroups(t *testing.T) {
+	cfg := useTestServices(t)
+	useTestMailbox(t, cfg)
+	_, err := InviteUser(context.Background(), users.InvitationRequest{
+		EmailAddress: "malformed@example.com",
+		Workgroups:   []string{"scheduler-employee", "employee"},
+	}, "admin@example.com")
+	if err == nil {
+		t.Fatal("InviteUser accepted a malformed workgroup")
+	}
+	if _, err := GetUserByEMail(context.Background(),
+		"malformed@example.com"); err == nil {
+		t.Error("account created for a refused invitation")
+	}
+}
@@ -4,15 +4,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/jinzhu/gorm"
 	"github.com/lib/pq"
 )
 
 type CompanyHoliday struct {
+	gorm.Model
+	CompanyID   uint           `json:"-" bson:"-"`
 	Code        string         `json:"id" bson:"id"`
 	Name        string         `json:"name" bson:"name"`
 	SortID      uint           `json:"sort" bson:"sort"`
 	ActualDates []time.Time    `json:"actualdates,omitempty" bson:"actualdates,omitempty" gorm:"-:all"`
-	Dates       pq.StringArray `json:"-" bson:"-"`
+	Dates       pq.StringArray `json:"-" bson:"-" gorm:"type:text[]"`
 }
 
 type ByCompanyHoliday []CompanyHoliday
@@ -26,8 +29,35 @@ func (c ByCompanyHoliday) Less(i, j int) bool {
 }
 func (c ByCompanyHoliday) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
+// ConvertToDates copies the actual dates into the postgres text array, while
+// ConvertFromDates rebuilds the actual dates from it.  gorm calls them through
+// the BeforeSave and AfterFind hooks.
 func (ch *CompanyHoliday) ConvertToDates() {
+	ch.Dates = ch.Dates[:0]
+	for _, actual := range ch.ActualDates {
+		ch.Dates = append(ch.Dates, actual.Format(time.RFC3339))
+	}
+}
+
+func (ch *CompanyHoliday) ConvertFromDates() error {
+	ch.ActualDates = ch.ActualDates[:0]
+	for _, dt := range ch.Dates {
+		actual, err := time.Parse(time.RFC3339, dt)
+		if err != nil {
+			return err
+		}
+		ch.ActualDates = append(ch.ActualDates, actual)
+	}
+	return nil
+}
+
+func (ch *CompanyHoliday) BeforeSave() error {
+	ch.ConvertToDates()
+	return nil
+}
 
+func (ch *CompanyHoliday) AfterFind() error {
+	return ch.ConvertFromDates()
 }
 
 func (ch *CompanyHoliday) GetActual(year int) *time.Time {
@@ -51,13 +81,15 @@ func (ch *CompanyHoliday) Purge(date time.Time) {
 }
 
 type Company struct {
+	gorm.Model
+	TeamID         uint             `json:"-" bson:"-"`
 	Code           string           `json:"id" bson:"id"`
 	Name           string           `json:"name" bson:"name"`
 	IngestType     string           `json:"ingest" bson:"ingest"`
 	IngestPeriod   int              `json:"ingestPeriod,omitempty" bson:"ingestPeriod,omitempty"`
 	IngestStartDay int              `json:"startDay,omitempty" bson:"startDay,omitempty"`
 	IngestPwd      string           `json:"ingestPwd" bson:"ingestPwd"`
-	Holidays       []CompanyHoliday `json:"holidays,omitempty" bson:"holidays,omitempty"`
+	Holidays       []CompanyHoliday `json:"holidays,omitempty" bson:"holidays,omitempty" gorm:"foreignkey:CompanyID"`
 }
 
 type ByCompany []Company
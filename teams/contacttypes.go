@@ -4,6 +4,7 @@ import "github.com/jinzhu/gorm"
 
 type ContactType struct {
 	gorm.Model
+	TeamID uint   `json:"-" bson:"-"`
 	Code   int    `json:"id" bson:"id"`
 	Name   string `json:"name" bson:"name"`
 	SortID int    `json:"sort" bson:"sort"`
@@ -19,6 +20,7 @@ func (c ByContactType) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
 type SpecialtyType struct {
 	gorm.Model
+	TeamID uint   `json:"-" bson:"-"`
 	Code   int    `json:"id" bson:"id"`
 	Name   string `json:"name" bson:"name"`
 	SortID int    `json:"sort" bson:"sort"`
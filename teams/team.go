@@ -5,19 +5,23 @@ import (
 	"strings"
 	"time"
 
-	"github.com/erneap/go-models/sites"
-	"github.com/jinzhu/gorm"
+	"github.com/erneap/go-pg-models/sites"
 )
 
+// Team carries its own primary key rather than embedding gorm.Model, so the
+// json/bson id mapping is kept and gorm does not see two id columns.
 type Team struct {
-	gorm.Model
-	ID             uint            `json:"id" bson:"_id"`
+	ID             uint `json:"id" bson:"_id" gorm:"primary_key"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time      `sql:"index"`
 	Name           string          `json:"name" bson:"name"`
-	Workcodes      []Workcode      `json:"workcodes" bson:"workcodes"`
-	Sites          []sites.Site    `json:"sites" bson:"sites"`
-	Companies      []Company       `json:"companies,omitempty" bson:"companies,omitempty"`
-	ContactTypes   []ContactType   `json:"contacttypes,omitempty" bson:"contacttypes,omitempty"`
-	SpecialtyTypes []SpecialtyType `json:"specialties,omitempty" bson:"specialties,omitempty"`
+	Workcodes      []Workcode      `json:"workcodes" bson:"workcodes" gorm:"foreignkey:TeamID"`
+	Sites          []sites.Site    `json:"sites" bson:"sites" gorm:"foreignkey:TeamID"`
+	Companies      []Company       `json:"companies,omitempty" bson:"companies,omitempty" gorm:"foreignkey:TeamID"`
+	ContactTypes   []ContactType   `json:"contacttypes,omitempty" bson:"contacttypes,omitempty" gorm:"foreignkey:TeamID"`
+	SpecialtyTypes []SpecialtyType `json:"specialties,omitempty" bson:"specialties,omitempty" gorm:"foreignkey:TeamID"`
+	Version        uint            `json:"version" bson:"version"`
 }
 
 type ByTeam []Team
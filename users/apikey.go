@@ -0,0 +1,56 @@
+package users
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey is the server side record of a key used by a batch job or another
+// service in place of a user's access token.  Only the hash of the key is
+// kept, along with its first characters so it can be recognized in a list.
+// The key acts in a single application, holding only the roles given it.
+type APIKey struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Name        string             `json:"name" bson:"name"`
+	Application string             `json:"application" bson:"application"`
+	Roles       []Role             `json:"roles" bson:"roles"`
+	Prefix      string             `json:"prefix" bson:"prefix"`
+	KeyHash     string             `json:"-" bson:"keyhash"`
+	CreatedBy   string             `json:"createdBy" bson:"createdby"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdat"`
+	ExpiresAt   *time.Time         `json:"expiresAt,omitempty" bson:"expiresat,omitempty"`
+	LastUsed    *time.Time         `json:"lastUsed,omitempty" bson:"lastused,omitempty"`
+	RevokedAt   *time.Time         `json:"revokedAt,omitempty" bson:"revokedat,omitempty"`
+}
+
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// IsExpired reports whether the key has expired.  A key without an expiry
+// never does.
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// User returns the user the key acts as, named for the key and holding the
+// key's roles in its application, so the role checks treat it as any other
+// user.
+func (k *APIKey) User() *User {
+	user := &User{Identity: Identity{
+		ID:           k.ID,
+		EmailAddress: "apikey:" + k.Name,
+		FirstName:    k.Name,
+		LastName:     "API Key",
+	}}
+	var roles []Role
+	for _, role := range k.Roles {
+		if strings.EqualFold(role.Application, k.Application) {
+			roles = append(roles, role)
+		}
+	}
+	user.SetRoles(roles)
+	return user
+}
@@ -0,0 +1,175 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrPasswordMismatch = errors.New("Email Address/Password mismatch")
+	ErrPasswordExpired  = errors.New("password expired")
+)
+
+// Identity is the account shared by the applications: the login, password,
+// lockout and multi-factor state, and the name.  An account is Unverified
+// from its invitation or registration until the emailed verify token, kept
+// as its hash, comes back to show the email address is the user's.
+// LastLogin is the time of the account's last successful login.  Each
+// application keeps its own settings for the account in a profile, so one
+// account logs into all of them.  The scheduler's profile is its roles, kept
+// alongside the identity in User, while the other applications' profiles are
+// kept in Profiles by application.
+type Identity struct {
+	ID               primitive.ObjectID  `json:"id" bson:"_id"`
+	EmailAddress     string              `json:"emailAddress" bson:"emailAddress"`
+	Password         string              `json:"password" bson:"password"`
+	PasswordExpires  time.Time           `json:"passwordExpires" bson:"passwordExpires"`
+	PasswordHistory  []string            `json:"-" bson:"passwordhistory,omitempty"`
+	BadAttempts      uint                `json:"badAttempts" bson:"badAttempts"`
+	LastBadAttempt   *time.Time          `json:"lastBadAttempt,omitempty" bson:"lastbadattempt,omitempty"`
+	LockedUntil      *time.Time          `json:"lockedUntil,omitempty" bson:"lockeduntil,omitempty"`
+	FirstName        string              `json:"firstName" bson:"firstName"`
+	MiddleName       string              `json:"middleName,omitempty" bson:"middleName,omitempty"`
+	LastName         string              `json:"lastName" bson:"lastName"`
+	ResetToken       string              `json:"-" bson:"resettoken,omitempty"`
+	ResetTokenExp    *time.Time          `json:"-" bson:"resettokenexp,omitempty"`
+	Unverified       bool                `json:"unverified,omitempty" bson:"unverified,omitempty"`
+	VerifyToken      string              `json:"-" bson:"verifytoken,omitempty"`
+	VerifyTokenExp   *time.Time          `json:"-" bson:"verifytokenexp,omitempty"`
+	LastLogin        *time.Time          `json:"lastLogin,omitempty" bson:"lastlogin,omitempty"`
+	MFAEnabled       bool                `json:"mfaEnabled" bson:"mfaenabled"`
+	MFASecret        string              `json:"-" bson:"mfasecret,omitempty"`
+	MFALastStep      int64               `json:"-" bson:"mfalaststep,omitempty"`
+	MFARecoveryCodes []string            `json:"-" bson:"mfarecoverycodes,omitempty"`
+	Profiles         map[string]bson.Raw `json:"-" bson:"profiles,omitempty"`
+	Version          uint                `json:"version" bson:"version"`
+}
+
+// GetProfile reads the application's profile into profile, reporting whether
+// the account has one.
+func (u *Identity) GetProfile(app string, profile interface{}) (bool, error) {
+	raw, ok := u.Profiles[strings.ToLower(app)]
+	if !ok {
+		return false, nil
+	}
+	return true, bson.Unmarshal(raw, profile)
+}
+
+// SetProfile replaces the application's profile.
+func (u *Identity) SetProfile(app string, profile interface{}) error {
+	raw, err := bson.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	if u.Profiles == nil {
+		u.Profiles = make(map[string]bson.Raw)
+	}
+	u.Profiles[strings.ToLower(app)] = raw
+	return nil
+}
+
+// SetPassword sets the password under the default password policy.
+func (u *Identity) SetPassword(passwd string) error {
+	return u.SetPasswordWithPolicy(passwd, DefaultPasswordPolicy)
+}
+
+// SetPasswordWithPolicy sets the password when it passes the policy and isn't
+// the current password or one in the password history, returning a
+// *PasswordError listing the problems otherwise.  The replaced password joins
+// the history and the new one expires after the policy's expiry.
+func (u *Identity) SetPasswordWithPolicy(passwd string,
+	policy PasswordPolicy) error {
+	problems := policy.Check(passwd, u.EmailAddress, u.FirstName,
+		u.MiddleName, u.LastName)
+	if policy.HistorySize > 0 {
+		previous := append([]string{u.Password}, u.PasswordHistory...)
+		for _, hash := range previous {
+			if hash != "" && VerifyPassword(passwd, hash) == nil {
+				problems = append(problems, PasswordProblem{
+					Code:    PasswordReused,
+					Message: "must not be one of your recent passwords",
+				})
+				break
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return &PasswordError{Problems: problems}
+	}
+
+	hashed, err := HashPassword(passwd)
+	if err != nil {
+		return err
+	}
+	u.PasswordHistory = policy.history(u.PasswordHistory, u.Password)
+	u.Password = hashed
+	u.PasswordExpires = policy.expires(time.Now().UTC())
+	u.Unlock()
+	return nil
+}
+
+// Authenticate checks the password under the default lockout policy.
+func (u *Identity) Authenticate(passwd string) error {
+	return u.AuthenticateWithPolicy(passwd, DefaultLockout)
+}
+
+// AuthenticateWithPolicy checks the password, counting a mismatch as a failed
+// login under the lockout policy.  A locked account is refused without
+// checking the password.  An expired password is refused after it matches,
+// but doesn't count as a failure.  A matching password whose hash isn't the
+// default hasher's current one is hashed again, to be saved with the user.
+func (u *Identity) AuthenticateWithPolicy(passwd string,
+	policy LockoutPolicy) error {
+	now := time.Now().UTC()
+	if policy.IsLocked(u.LockedUntil, now) {
+		return ErrAccountLocked
+	}
+
+	if err := VerifyPassword(passwd, u.Password); err != nil {
+		u.BadAttempts, u.LockedUntil = policy.Failure(u.BadAttempts,
+			u.LastBadAttempt, u.LockedUntil, now)
+		u.LastBadAttempt = &now
+		if u.LockedUntil != nil {
+			return ErrAccountLocked
+		}
+		return ErrPasswordMismatch
+	}
+
+	u.Unlock()
+	if !DefaultPasswordHasher.Current(u.Password) {
+		if hashed, err := HashPassword(passwd); err == nil {
+			u.Password = hashed
+		}
+	}
+	if u.PasswordExpires.Before(now) {
+		return ErrPasswordExpired
+	}
+	return nil
+}
+
+// Unlock clears the account's failed logins and lock, as an administrator
+// unlocking the account.
+func (u *Identity) Unlock() {
+	u.BadAttempts = 0
+	u.LastBadAttempt = nil
+	u.LockedUntil = nil
+}
+
+func (u *Identity) GetFullName() string {
+	if u.MiddleName != "" {
+		return fmt.Sprintf("%s %s. %s", u.FirstName, u.MiddleName[:1], u.LastName)
+	}
+	return fmt.Sprintf("%s %s", u.FirstName, u.LastName)
+}
+
+func (u *Identity) GetLastFirst() string {
+	if u.MiddleName != "" {
+		return fmt.Sprintf("%s, %s %s.", u.LastName, u.FirstName, u.MiddleName[:1])
+	}
+	return fmt.Sprintf("%s, %s", u.LastName, u.FirstName)
+}
@@ -4,12 +4,25 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
+// JWTClaim is the claims of the tokens issued to a user.  Access tokens have
+// no purpose, while the short-lived tokens of a login step, such as the
+// multi-factor challenge, name it and can't be used as access tokens.  The
+// token of an administrator impersonating the user has the administrator's
+// id as its ActorID and the application it was issued for as its audience.
 type JWTClaim struct {
 	UserID       string `json:"userid"`
 	EmailAddress string `json:"emailAddress"`
+	Purpose      string `json:"purpose,omitempty"`
+	ActorID      string `json:"actorid,omitempty"`
 	jwt.StandardClaims
 }
 
+// IsImpersonation reports whether the token was issued to an administrator
+// acting as the user.
+func (c *JWTClaim) IsImpersonation() bool {
+	return c.ActorID != ""
+}
+
 type UserName struct {
 	FirstName  string `json:"firstName"`
 	MiddleName string `json:"middleName,omitempty"`
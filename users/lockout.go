@@ -0,0 +1,49 @@
+package users
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrAccountLocked = errors.New("account locked")
+
+// LockoutPolicy locks an account for the Duration once it has had Threshold
+// failed logins, each within the ResetWindow of the one before.  A failure
+// after a longer gap starts the count again.  A zero Threshold turns lockout
+// off.
+type LockoutPolicy struct {
+	Threshold   uint
+	Duration    time.Duration
+	ResetWindow time.Duration
+}
+
+// DefaultLockout is the policy used by the users' Authenticate methods, set
+// by the services from the application's configuration.
+var DefaultLockout = LockoutPolicy{
+	Threshold:   3,
+	Duration:    15 * time.Minute,
+	ResetWindow: time.Hour,
+}
+
+// IsLocked reports whether an account locked until the time is still locked.
+func (p LockoutPolicy) IsLocked(lockedUntil *time.Time, now time.Time) bool {
+	return lockedUntil != nil && now.Before(*lockedUntil)
+}
+
+// Failure returns an account's count of failed logins after another failure
+// at now, given its count, the time of its last failure and the time it was
+// locked until, along with the time the account is locked until when the
+// failure locks it.  A lock which has expired starts the count again.
+func (p LockoutPolicy) Failure(attempts uint, last, lockedUntil *time.Time,
+	now time.Time) (uint, *time.Time) {
+	if last == nil || (p.ResetWindow > 0 && now.Sub(*last) > p.ResetWindow) ||
+		(lockedUntil != nil && !p.IsLocked(lockedUntil, now)) {
+		attempts = 0
+	}
+	attempts++
+	if p.Threshold == 0 || attempts < p.Threshold {
+		return attempts, nil
+	}
+	until := now.Add(p.Duration)
+	return attempts, &until
+}
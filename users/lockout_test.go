@@ -0,0 +1,121 @@
+package users
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLockoutFailure(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 3, Duration: 15 * time.Minute,
+		ResetWindow: time.Hour}
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Minute)
+	stale := now.Add(-2 * time.Hour)
+	expired := now.Add(-time.Second)
+	tests := []struct {
+		name         string
+		policy       LockoutPolicy
+		attempts     uint
+		last         *time.Time
+		lockedUntil  *time.Time
+		wantAttempts uint
+		wantLocked   bool
+	}{
+		{"first failure", policy, 0, nil, nil, 1, false},
+		{"below the threshold", policy, 1, &recent, nil, 2, false},
+		{"at the threshold", policy, 2, &recent, nil, 3, true},
+		{"past the threshold", policy, 5, &recent, nil, 6, true},
+		{"after the reset window", policy, 2, &stale, nil, 1, false},
+		{"after the lock expired", policy, 3, &recent, &expired, 1, false},
+		{"lockout off", LockoutPolicy{}, 10, &recent, nil, 11, false},
+	}
+	for _, tt := range tests {
+		attempts, until := tt.policy.Failure(tt.attempts, tt.last,
+			tt.lockedUntil, now)
+		if attempts != tt.wantAttempts {
+			t.Errorf("%s: attempts = %d, want %d", tt.name, attempts,
+				tt.wantAttempts)
+		}
+		if (until != nil) != tt.wantLocked {
+			t.Errorf("%s: locked until %v, want locked %v", tt.name, until,
+				tt.wantLocked)
+		}
+		if until != nil && !until.Equal(now.Add(tt.policy.Duration)) {
+			t.Errorf("%s: locked until %v, want %v", tt.name, until,
+				now.Add(tt.policy.Duration))
+		}
+	}
+}
+
+func TestAuthenticateLocksAtThreshold(t *testing.T) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("Correct-Password-1"),
+		bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	hasher := DefaultPasswordHasher
+	DefaultPasswordHasher = BcryptHasher{Cost: bcrypt.MinCost}
+	t.Cleanup(func() { DefaultPasswordHasher = hasher })
+
+	policy := LockoutPolicy{Threshold: 3, Duration: time.Hour,
+		ResetWindow: time.Hour}
+	u := &Identity{Password: string(hashed),
+		PasswordExpires: time.Now().Add(time.Hour)}
+	steps := []struct {
+		password string
+		want     error
+	}{
+		{"wrong", ErrPasswordMismatch},
+		{"wrong", ErrPasswordMismatch},
+		{"wrong", ErrAccountLocked},
+		{"Correct-Password-1", ErrAccountLocked},
+	}
+	for i, step := range steps {
+		if err := u.AuthenticateWithPolicy(step.password,
+			policy); !errors.Is(err, step.want) {
+			t.Fatalf("attempt %d: AuthenticateWithPolicy = %v, want %v", i+1,
+				err, step.want)
+		}
+	}
+
+	u.Unlock()
+	if err := u.AuthenticateWithPolicy("Correct-Password-1", policy); err != nil {
+		t.Fatalf("after Unlock: AuthenticateWithPolicy = %v", err)
+	}
+	if u.BadAttempts != 0 || u.LockedUntil != nil {
+		t.Errorf("successful login left %d failures, locked until %v",
+			u.BadAttempts, u.LockedUntil)
+	}
+}
+
+func TestAuthenticateAfterLockExpires(t *testing.T) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("Correct-Password-1"),
+		bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	hasher := DefaultPasswordHasher
+	DefaultPasswordHasher = BcryptHasher{Cost: bcrypt.MinCost}
+	t.Cleanup(func() { DefaultPasswordHasher = hasher })
+
+	policy := LockoutPolicy{Threshold: 3, Duration: time.Hour,
+		ResetWindow: 24 * time.Hour}
+	last := time.Now().UTC().Add(-time.Minute)
+	expired := time.Now().UTC().Add(-time.Second)
+	u := &Identity{Password: string(hashed),
+		PasswordExpires: time.Now().Add(time.Hour), BadAttempts: 3,
+		LastBadAttempt: &last, LockedUntil: &expired}
+
+	if err := u.AuthenticateWithPolicy("wrong",
+		policy); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("AuthenticateWithPolicy = %v, want %v", err,
+			ErrPasswordMismatch)
+	}
+	if u.BadAttempts != 1 || u.LockedUntil != nil {
+		t.Errorf("failure after the lock expired left %d failures, locked "+
+			"until %v", u.BadAttempts, u.LockedUntil)
+	}
+}
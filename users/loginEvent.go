@@ -0,0 +1,45 @@
+package users
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The kinds of login event.
+const (
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+	LoginLockout = "lockout"
+	LoginReset   = "reset"
+	LoginRefresh = "refresh"
+
+	LoginImpersonate    = "impersonate"
+	LoginImpersonateEnd = "impersonate-end"
+)
+
+// LoginEvent records a login, failed login, lockout, password reset or token
+// refresh of an account, with the address and user agent of the client
+// making the request.  A failed login for an unknown address has no user id,
+// only the address tried.  The start and end of an administrator's
+// impersonation of the user give the administrator's id as the ActorID.
+type LoginEvent struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	UserID       string             `json:"userid,omitempty" bson:"userid,omitempty"`
+	EmailAddress string             `json:"emailAddress" bson:"emailAddress"`
+	Type         string             `json:"type" bson:"type"`
+	Detail       string             `json:"detail,omitempty" bson:"detail,omitempty"`
+	IPAddress    string             `json:"ipAddress,omitempty" bson:"ipaddress,omitempty"`
+	UserAgent    string             `json:"userAgent,omitempty" bson:"useragent,omitempty"`
+	ActorID      string             `json:"actorid,omitempty" bson:"actorid,omitempty"`
+	DateTime     time.Time          `json:"datetime" bson:"datetime"`
+}
+
+// ByLoginEvent sorts the events newest first.
+type ByLoginEvent []LoginEvent
+
+func (c ByLoginEvent) Len() int { return len(c) }
+func (c ByLoginEvent) Less(i, j int) bool {
+	return c[i].DateTime.After(c[j].DateTime)
+}
+func (c ByLoginEvent) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
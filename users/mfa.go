@@ -0,0 +1,173 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The TOTP settings of RFC 6238 used by the authenticator apps, six digit
+// codes from an HMAC-SHA1 of the 30 second time step.
+const (
+	totpDigits       = 6
+	totpPeriod       = 30
+	recoveryCodes    = 10
+	recoveryCodeSize = 5
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// StartMFAEnrollment gives the user a new TOTP secret, which isn't used to
+// log in until ConfirmMFAEnrollment has checked a code from it.  A user who
+// already has multi-factor authentication must disable it with a current
+// code first, so a stolen session can't replace the secret.
+func (u *Identity) StartMFAEnrollment() error {
+	if u.MFAEnabled {
+		return fmt.Errorf("mfa already enabled")
+	}
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	u.MFASecret = totpEncoding.EncodeToString(secret)
+	u.MFAEnabled = false
+	u.MFALastStep = 0
+	u.MFARecoveryCodes = nil
+	return nil
+}
+
+// MFAURI returns the otpauth uri of the user's TOTP secret, usually shown as
+// a QR code for the authenticator app to scan.
+func (u *Identity) MFAURI(issuer string) string {
+	label := url.PathEscape(issuer + ":" + u.EmailAddress)
+	values := url.Values{
+		"secret":    {u.MFASecret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// ConfirmMFAEnrollment turns on multi-factor authentication when the code
+// matches the new secret, returning the one-time recovery codes to show the
+// user.  Only their hashes are kept.
+func (u *Identity) ConfirmMFAEnrollment(code string, skew int,
+	now time.Time) ([]string, error) {
+	if u.MFASecret == "" {
+		return nil, fmt.Errorf("mfa enrollment not started")
+	}
+	if !u.VerifyTOTP(code, skew, now) {
+		return nil, fmt.Errorf("invalid authentication code")
+	}
+	codes, err := u.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	u.MFAEnabled = true
+	return codes, nil
+}
+
+// DisableMFA removes the user's TOTP secret and recovery codes when the code
+// is a current authenticator code or one of the recovery codes.
+func (u *Identity) DisableMFA(code string, skew int, now time.Time) error {
+	if u.MFAEnabled && !u.VerifyTOTP(code, skew, now) &&
+		!u.UseRecoveryCode(code) {
+		return fmt.Errorf("invalid authentication code")
+	}
+	u.ResetMFA()
+	return nil
+}
+
+// ResetMFA removes the user's TOTP secret and recovery codes without a code,
+// for an administrator helping a user who has lost the authenticator.
+func (u *Identity) ResetMFA() {
+	u.MFAEnabled = false
+	u.MFASecret = ""
+	u.MFALastStep = 0
+	u.MFARecoveryCodes = nil
+}
+
+// VerifyTOTP checks the code against the time steps within the skew of now,
+// refusing a step at or before the last one used so a code can't be replayed.
+func (u *Identity) VerifyTOTP(code string, skew int, now time.Time) bool {
+	secret, err := totpEncoding.DecodeString(strings.ToUpper(u.MFASecret))
+	if err != nil || len(secret) == 0 {
+		return false
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	step := now.Unix() / totpPeriod
+	for i := -skew; i <= skew; i++ {
+		counter := step + int64(i)
+		if counter <= u.MFALastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, counter)),
+			[]byte(code)) == 1 {
+			u.MFALastStep = counter
+			return true
+		}
+	}
+	return false
+}
+
+// NewRecoveryCodes replaces the user's recovery codes, returning the new
+// codes.
+func (u *Identity) NewRecoveryCodes() ([]string, error) {
+	var codes, hashes []string
+	for i := 0; i < recoveryCodes; i++ {
+		buf := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(buf))
+		code = code[:4] + "-" + code[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	u.MFARecoveryCodes = hashes
+	return codes, nil
+}
+
+// UseRecoveryCode checks the code against the user's recovery codes, removing
+// it when it matches so it can't be used again.
+func (u *Identity) UseRecoveryCode(code string) bool {
+	hash := hashRecoveryCode(code)
+	for i, stored := range u.MFARecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			u.MFARecoveryCodes = append(u.MFARecoveryCodes[:i:i],
+				u.MFARecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// totpCode returns the code for the time step counter, by the dynamic
+// truncation of RFC 4226.
+func totpCode(secret []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
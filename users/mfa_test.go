@@ -0,0 +1,131 @@
+package users
+
+import (
+	"testing"
+	"time"
+)
+
+func enrolledIdentity(t *testing.T, now time.Time) (*Identity, []byte,
+	[]string) {
+	t.Helper()
+	u := &Identity{EmailAddress: "user@example.com"}
+	if err := u.StartMFAEnrollment(); err != nil {
+		t.Fatalf("StartMFAEnrollment: %v", err)
+	}
+	secret, err := totpEncoding.DecodeString(u.MFASecret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	codes, err := u.ConfirmMFAEnrollment(
+		totpCode(secret, now.Unix()/totpPeriod), 1, now)
+	if err != nil {
+		t.Fatalf("ConfirmMFAEnrollment: %v", err)
+	}
+	u.MFALastStep = 0
+	return u, secret, codes
+}
+
+func TestVerifyTOTPSkew(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / totpPeriod
+	tests := []struct {
+		name   string
+		offset int64
+		skew   int
+		want   bool
+	}{
+		{"current step", 0, 0, true},
+		{"previous step within skew", -1, 1, true},
+		{"next step within skew", 1, 1, true},
+		{"previous step without skew", -1, 0, false},
+		{"two steps behind skew of one", -2, 1, false},
+		{"two steps ahead skew of one", 2, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, secret, _ := enrolledIdentity(t, now)
+			code := totpCode(secret, step+tt.offset)
+			if got := u.VerifyTOTP(code, tt.skew, now); got != tt.want {
+				t.Errorf("VerifyTOTP = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPReplay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / totpPeriod
+	u, secret, _ := enrolledIdentity(t, now)
+
+	if !u.VerifyTOTP(totpCode(secret, step), 1, now) {
+		t.Fatal("first use of the code refused")
+	}
+	tests := []struct {
+		name string
+		step int64
+		want bool
+	}{
+		{"same code again", step, false},
+		{"earlier step within skew", step - 1, false},
+		{"later step within skew", step + 1, true},
+		{"later code again", step + 1, false},
+	}
+	for _, tt := range tests {
+		if got := u.VerifyTOTP(totpCode(secret, tt.step), 1, now); got != tt.want {
+			t.Errorf("%s: VerifyTOTP = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	u, _, codes := enrolledIdentity(t, now)
+
+	if !u.UseRecoveryCode(codes[0]) {
+		t.Fatal("recovery code refused")
+	}
+	if u.UseRecoveryCode(codes[0]) {
+		t.Error("recovery code accepted twice")
+	}
+	if len(u.MFARecoveryCodes) != recoveryCodes-1 {
+		t.Errorf("%d recovery codes left, want %d", len(u.MFARecoveryCodes),
+			recoveryCodes-1)
+	}
+}
+
+func TestMFAChangesNeedCode(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / totpPeriod
+	tests := []struct {
+		name    string
+		code    func(secret []byte, codes []string) string
+		wantErr bool
+	}{
+		{"no code", func([]byte, []string) string { return "" }, true},
+		{"wrong code", func([]byte, []string) string { return "000000" }, true},
+		{"current code", func(secret []byte, _ []string) string {
+			return totpCode(secret, step)
+		}, false},
+		{"recovery code", func(_ []byte, codes []string) string {
+			return codes[1]
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, secret, codes := enrolledIdentity(t, now)
+			if err := u.StartMFAEnrollment(); err == nil {
+				t.Fatal("StartMFAEnrollment replaced an enabled secret")
+			}
+			err := u.DisableMFA(tt.code(secret, codes), 1, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DisableMFA error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if u.MFAEnabled == !tt.wantErr {
+				t.Errorf("MFAEnabled = %v after DisableMFA", u.MFAEnabled)
+			}
+			if tt.wantErr && len(u.MFARecoveryCodes) != recoveryCodes {
+				t.Errorf("refused DisableMFA changed the recovery codes")
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes the passwords, as a string in the PHC format naming
+// the algorithm and its parameters, such as
+// "$argon2id$v=19$m=65536,t=3,p=2$salt$hash".  bcrypt hashes keep their
+// usual "$2a$12$..." form, which the format grew from.
+type PasswordHasher interface {
+	Hash(passwd string) (string, error)
+
+	// Current reports whether the hash was made by this hasher with its
+	// current parameters, so it needn't be made again.
+	Current(hash string) bool
+}
+
+// DefaultPasswordHasher hashes the new passwords.  A password hashed some
+// other way is still checked by the algorithm named in its hash, and is
+// hashed again by this hasher the next time the user logs in.
+var DefaultPasswordHasher PasswordHasher = BcryptHasher{Cost: 12}
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// BcryptHasher hashes the passwords with bcrypt at the cost.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(passwd string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(passwd), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h BcryptHasher) Current(hash string) bool {
+	if !isBcrypt(hash) {
+		return false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost == h.Cost
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") ||
+		strings.HasPrefix(hash, "$2y$")
+}
+
+// Argon2idHasher hashes the passwords with argon2id, using Memory KiB of
+// memory, Iterations passes over it and Parallelism threads, with a random
+// salt of SaltLength bytes, giving a hash of KeyLength bytes.
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewArgon2idHasher returns the argon2id hasher with the memory, iterations
+// and parallelism, and the recommended 16 byte salt and 32 byte hash.
+func NewArgon2idHasher(memory, iterations uint32,
+	parallelism uint8) Argon2idHasher {
+	return Argon2idHasher{
+		Memory:      memory,
+		Iterations:  iterations,
+		Parallelism: parallelism,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+func (h Argon2idHasher) Hash(passwd string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(passwd), salt, h.Iterations, h.Memory,
+		h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Current(hash string) bool {
+	params, salt, key, err := parseArgon2id(hash)
+	return err == nil && params.Memory == h.Memory &&
+		params.Iterations == h.Iterations &&
+		params.Parallelism == h.Parallelism &&
+		uint32(len(salt)) == h.SaltLength && uint32(len(key)) == h.KeyLength
+}
+
+// the largest argon2id costs read from a hash, far above any hasher's
+// settings, so that a corrupt hash can't stall a login.
+const (
+	maxArgon2Memory     = 4 * 1024 * 1024
+	maxArgon2Iterations = 64
+)
+
+// parseArgon2id reads the parameters, salt and key of an argon2id hash.
+func parseArgon2id(hash string) (Argon2idHasher, []byte, []byte, error) {
+	var params Argon2idHasher
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version: %d",
+			version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory,
+		&params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if params.Memory == 0 || params.Memory > maxArgon2Memory ||
+		params.Iterations == 0 || params.Iterations > maxArgon2Iterations ||
+		params.Parallelism == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(salt) == 0 || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// HashPassword hashes the password with the default hasher.
+func HashPassword(passwd string) (string, error) {
+	return DefaultPasswordHasher.Hash(passwd)
+}
+
+// VerifyPassword checks the password against the hash, by the algorithm the
+// hash names, returning ErrPasswordMismatch when it doesn't match.
+func VerifyPassword(passwd, hash string) error {
+	switch {
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(passwd))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		return err
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return err
+		}
+		computed := argon2.IDKey([]byte(passwd), salt, params.Iterations,
+			params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	}
+	return ErrUnknownHash
+}
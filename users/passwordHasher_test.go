@@ -0,0 +1,41 @@
+package users
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVerifyPasswordMalformedArgon2id(t *testing.T) {
+	hash, err := NewArgon2idHasher(1024, 1, 1).Hash("Correct-Password-1")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if err := VerifyPassword("Correct-Password-1", hash); err != nil {
+		t.Fatalf("VerifyPassword = %v", err)
+	}
+
+	const salt = "c2FsdHNhbHRzYWx0c2FsdA"
+	const key = "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"no memory", "$argon2id$v=19$m=0,t=1,p=1$" + salt + "$" + key},
+		{"absurd memory", "$argon2id$v=19$m=4294967295,t=1,p=1$" + salt +
+			"$" + key},
+		{"no passes", "$argon2id$v=19$m=1024,t=0,p=1$" + salt + "$" + key},
+		{"absurd passes", "$argon2id$v=19$m=1024,t=100000,p=1$" + salt +
+			"$" + key},
+		{"no threads", "$argon2id$v=19$m=1024,t=1,p=0$" + salt + "$" + key},
+		{"too many threads", "$argon2id$v=19$m=1024,t=1,p=256$" + salt +
+			"$" + key},
+		{"no salt", "$argon2id$v=19$m=1024,t=1,p=1$$" + key},
+		{"no key", "$argon2id$v=19$m=1024,t=1,p=1$" + salt + "$"},
+	}
+	for _, tt := range tests {
+		if err := VerifyPassword("", tt.hash); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("%s: VerifyPassword = %v, want %v", tt.name, err,
+				ErrUnknownHash)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package users
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PasswordPolicy is the set of rules a new password must pass.  HistorySize
+// is the number of previous passwords which can't be reused, and Expiry the
+// time until a new password expires, where zero means the default of 90
+// days.
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	Banned        []string
+	HistorySize   int
+	Expiry        time.Duration
+}
+
+// CommonPasswords are refused by the default policy whatever the other rules.
+var CommonPasswords = []string{
+	"password", "password1", "password123", "passw0rd", "123456",
+	"12345678", "123456789", "1234567890", "qwerty", "qwerty123",
+	"letmein", "welcome", "welcome1", "admin", "administrator", "changeme",
+	"iloveyou", "monkey", "dragon", "football", "baseball", "abc123",
+	"trustno1", "sunshine", "princess", "master", "superman",
+}
+
+// DefaultPasswordPolicy is the policy used by SetPassword, set by the
+// services from the application's configuration.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:    10,
+	RequireUpper: true,
+	RequireLower: true,
+	RequireDigit: true,
+	Banned:       CommonPasswords,
+	HistorySize:  5,
+	Expiry:       90 * 24 * time.Hour,
+}
+
+const defaultPasswordExpiry = 90 * 24 * time.Hour
+
+// The codes of the password problems, for the user interface to choose its
+// own wording.
+const (
+	PasswordTooShort      = "too-short"
+	PasswordMissingUpper  = "missing-upper"
+	PasswordMissingLower  = "missing-lower"
+	PasswordMissingDigit  = "missing-digit"
+	PasswordMissingSymbol = "missing-symbol"
+	PasswordBanned        = "banned"
+	PasswordContainsName  = "contains-name"
+	PasswordContainsEmail = "contains-email"
+	PasswordReused        = "reused"
+)
+
+// PasswordProblem is a rule the password failed.
+type PasswordProblem struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordError is returned by SetPassword when the password fails the
+// policy, listing every rule it failed.
+type PasswordError struct {
+	Problems []PasswordProblem `json:"problems"`
+}
+
+func (e *PasswordError) Error() string {
+	var messages []string
+	for _, problem := range e.Problems {
+		messages = append(messages, problem.Message)
+	}
+	return "password not accepted: " + strings.Join(messages, "; ")
+}
+
+// Check returns the problems with the password, which mustn't contain the
+// given names or email address.  Reuse is checked by SetPassword, which has
+// the password history.
+func (p PasswordPolicy) Check(passwd string, email string,
+	names ...string) []PasswordProblem {
+	var problems []PasswordProblem
+	add := func(code, message string) {
+		problems = append(problems, PasswordProblem{Code: code, Message: message})
+	}
+
+	if len([]rune(passwd)) < p.MinLength {
+		add(PasswordTooShort, "must be at least "+strconv.Itoa(p.MinLength)+
+			" characters")
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range passwd {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add(PasswordMissingUpper, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		add(PasswordMissingLower, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(PasswordMissingDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(PasswordMissingSymbol, "must contain a symbol")
+	}
+
+	lowered := strings.ToLower(passwd)
+	for _, banned := range p.Banned {
+		if strings.EqualFold(strings.TrimSpace(banned), passwd) {
+			add(PasswordBanned, "is too common")
+			break
+		}
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) >= 3 && strings.Contains(lowered, name) {
+			add(PasswordContainsName, "must not contain your name")
+			break
+		}
+	}
+	local := strings.ToLower(strings.SplitN(email, "@", 2)[0])
+	if len(local) >= 3 && strings.Contains(lowered, local) {
+		add(PasswordContainsEmail, "must not contain your email address")
+	}
+	return problems
+}
+
+// expires returns when a password set at now expires.
+func (p PasswordPolicy) expires(now time.Time) time.Time {
+	if p.Expiry <= 0 {
+		return now.Add(defaultPasswordExpiry)
+	}
+	return now.Add(p.Expiry)
+}
+
+// history returns the password history after the password hash is replaced,
+// keeping the most recent of the policy's history size.
+func (p PasswordPolicy) history(history []string, replaced string) []string {
+	if p.HistorySize <= 0 {
+		return nil
+	}
+	if replaced != "" {
+		history = append([]string{replaced}, history...)
+	}
+	if len(history) > p.HistorySize {
+		history = history[:p.HistorySize]
+	}
+	return history
+}
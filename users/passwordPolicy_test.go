@@ -0,0 +1,100 @@
+package users
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func problemCodes(problems []PasswordProblem) []string {
+	var codes []string
+	for _, problem := range problems {
+		codes = append(codes, problem.Code)
+	}
+	return codes
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+		Banned:        []string{"Password123!"},
+	}
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"Good-Pass-2026", nil},
+		{"Sh0rt!", []string{PasswordTooShort}},
+		{"lower-case-2026", []string{PasswordMissingUpper}},
+		{"UPPER-CASE-2026", []string{PasswordMissingLower}},
+		{"No-Digits-Here", []string{PasswordMissingDigit}},
+		{"NoSymbols2026", []string{PasswordMissingSymbol}},
+		{"password123!", []string{PasswordMissingUpper, PasswordBanned}},
+		{"Smithson-2026!", []string{PasswordContainsName}},
+		{"Pdoe-Mail-2026", []string{PasswordContainsEmail}},
+		{"", []string{PasswordTooShort, PasswordMissingUpper,
+			PasswordMissingLower, PasswordMissingDigit, PasswordMissingSymbol}},
+	}
+	for _, tt := range tests {
+		got := problemCodes(policy.Check(tt.password, "pdoe@example.com",
+			"Pat", "Smithson"))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Check(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestSetPasswordHistory(t *testing.T) {
+	hasher := DefaultPasswordHasher
+	DefaultPasswordHasher = BcryptHasher{Cost: bcrypt.MinCost}
+	t.Cleanup(func() { DefaultPasswordHasher = hasher })
+
+	policy := PasswordPolicy{MinLength: 8, HistorySize: 2, Expiry: time.Hour}
+	u := &Identity{EmailAddress: "user@example.com"}
+	steps := []struct {
+		password string
+		reused   bool
+	}{
+		{"first-secret", false},
+		{"second-secret", false},
+		{"first-secret", true},
+		{"second-secret", true},
+		{"third-secret", false},
+		{"first-secret", true},
+		{"fourth-secret", false},
+		{"first-secret", false},
+	}
+	for i, step := range steps {
+		before := time.Now()
+		err := u.SetPasswordWithPolicy(step.password, policy)
+		var perr *PasswordError
+		if step.reused {
+			if !errors.As(err, &perr) ||
+				!reflect.DeepEqual(problemCodes(perr.Problems),
+					[]string{PasswordReused}) {
+				t.Fatalf("step %d: SetPasswordWithPolicy(%q) = %v, want reused",
+					i+1, step.password, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("step %d: SetPasswordWithPolicy(%q) = %v", i+1,
+				step.password, err)
+		}
+		if len(u.PasswordHistory) > policy.HistorySize {
+			t.Errorf("step %d: history of %d, want at most %d", i+1,
+				len(u.PasswordHistory), policy.HistorySize)
+		}
+		if u.PasswordExpires.Before(before.Add(policy.Expiry)) {
+			t.Errorf("step %d: password expires %v, want after %v", i+1,
+				u.PasswordExpires, before.Add(policy.Expiry))
+		}
+	}
+}
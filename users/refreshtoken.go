@@ -0,0 +1,43 @@
+package users
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is the server side record of a refresh token, which is only
+// kept as the hash of the token given to the client.  Each use of a refresh
+// token revokes it and issues its replacement in the same family, so a
+// revoked token being used again shows it was stolen and the whole family is
+// revoked.
+type RefreshToken struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	UserID     string             `json:"userid" bson:"userid"`
+	Family     string             `json:"family" bson:"family"`
+	TokenHash  string             `json:"-" bson:"tokenhash"`
+	IssuedAt   time.Time          `json:"issuedAt" bson:"issuedat"`
+	ExpiresAt  time.Time          `json:"expiresAt" bson:"expiresat"`
+	RevokedAt  *time.Time         `json:"revokedAt,omitempty" bson:"revokedat"`
+	ReplacedBy string             `json:"replacedBy,omitempty" bson:"replacedby,omitempty"`
+}
+
+func (t *RefreshToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t *RefreshToken) IsExpired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+// RevokedToken is an entry on the access token revocation list.  It rejects
+// the access token with the TokenID, or when the TokenID is blank, every
+// access token of the user issued before RevokedAt.  The entry can be purged
+// after ExpiresAt, when the tokens it covers have expired anyway.
+type RevokedToken struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	TokenID   string             `json:"tokenid" bson:"tokenid"`
+	UserID    string             `json:"userid" bson:"userid"`
+	RevokedAt time.Time          `json:"revokedAt" bson:"revokedat"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresat"`
+}
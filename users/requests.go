@@ -26,5 +26,35 @@ type PasswordResetRequest struct {
 	EmailAddress string `json:"emailAddress"`
 	Password     string `json:"password"`
 	Token        string `json:"token"`
-	Application  string `json:"application:omitempty"`
+	Application  string `json:"application,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type MFARequest struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+type InvitationRequest struct {
+	EmailAddress string   `json:"emailAddress"`
+	FirstName    string   `json:"firstName"`
+	MiddleName   string   `json:"middleName,omitempty"`
+	LastName     string   `json:"lastName"`
+	Workgroups   []string `json:"workgroups,omitempty"`
+	Application  string   `json:"application,omitempty"`
+}
+
+type AcceptInvitationRequest struct {
+	EmailAddress string `json:"emailAddress"`
+	Password     string `json:"password"`
+	Token        string `json:"token"`
+	Application  string `json:"application,omitempty"`
+}
+
+type VerifyEmailRequest struct {
+	EmailAddress string `json:"emailAddress"`
+	Token        string `json:"token"`
 }
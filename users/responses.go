@@ -1,9 +1,12 @@
 package users
 
 type AuthenticationResponse struct {
-	Token     string `json:"token"`
-	User      User   `json:"user,omitempty"`
-	Exception string `json:"exception"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	MFARequired  bool   `json:"mfaRequired,omitempty"`
+	MFAToken     string `json:"mfaToken,omitempty"`
+	User         User   `json:"user,omitempty"`
+	Exception    string `json:"exception"`
 }
 
 type UserResponse struct {
@@ -12,8 +15,9 @@ type UserResponse struct {
 }
 
 type TokenRenewalResponse struct {
-	Token     string `json:"token"`
-	Exception string `json:"exception"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	Exception    string `json:"exception"`
 }
 
 type UsersResponse struct {
@@ -24,3 +28,15 @@ type UsersResponse struct {
 type ExceptionResponse struct {
 	Exception string `json:"exception"`
 }
+
+type PasswordErrorResponse struct {
+	Problems  []PasswordProblem `json:"problems"`
+	Exception string            `json:"exception"`
+}
+
+type MFAEnrollmentResponse struct {
+	Secret        string   `json:"secret,omitempty"`
+	URI           string   `json:"uri,omitempty"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+	Exception     string   `json:"exception"`
+}
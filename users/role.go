@@ -0,0 +1,266 @@
+package users
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scope limits a role to a team, or to a site within a team.  A blank team
+// or site matches any, so the zero scope is unlimited.  As the target of a
+// permission check, a scope names the team and site of the resource touched.
+type Scope struct {
+	TeamID string `json:"teamid,omitempty" bson:"teamid,omitempty"`
+	SiteID string `json:"siteid,omitempty" bson:"siteid,omitempty"`
+}
+
+// AnyTeam is the team of an explicit cross-team grant, such as
+// "scheduler-admin@*", which CanFrom doesn't confine to the user's own team.
+const AnyTeam = "*"
+
+// Covers reports whether the scope includes the resource's scope.
+func (s Scope) Covers(resource Scope) bool {
+	return scopeMatches(s.TeamID, resource.TeamID) &&
+		scopeMatches(s.SiteID, resource.SiteID)
+}
+
+func scopeMatches(held, resource string) bool {
+	return held == "" || held == AnyTeam || strings.EqualFold(held, resource)
+}
+
+// Role is a role the user holds in an application, optionally limited to a
+// scope.
+type Role struct {
+	Application string `json:"application" bson:"application"`
+	Name        string `json:"role" bson:"role"`
+	Scope       `bson:",inline"`
+}
+
+// ParseRole reads a role in its string form, the "app-role" of the legacy
+// workgroups followed optionally by "@team" or "@team/site" for its scope.
+func ParseRole(value string) (Role, error) {
+	var role Role
+	name, scope, scoped := strings.Cut(strings.TrimSpace(value), "@")
+	app, group, ok := strings.Cut(name, "-")
+	if !ok || app == "" || group == "" {
+		return role, fmt.Errorf("malformed role: %q", value)
+	}
+	role.Application = strings.ToLower(app)
+	role.Name = strings.ToLower(group)
+	if scoped {
+		team, site, _ := strings.Cut(scope, "/")
+		if team == "" {
+			return role, fmt.Errorf("malformed role scope: %q", value)
+		}
+		role.TeamID = team
+		role.SiteID = site
+	}
+	return role, nil
+}
+
+func (r Role) String() string {
+	answer := r.Application + "-" + r.Name
+	if r.TeamID != "" {
+		answer += "@" + r.TeamID
+		if r.SiteID != "" {
+			answer += "/" + r.SiteID
+		}
+	}
+	return answer
+}
+
+// ParseRoles reads the roles in their string form, returning the malformed
+// ones separately.
+func ParseRoles(values []string) ([]Role, []string) {
+	var roles []Role
+	var malformed []string
+	for _, value := range values {
+		role, err := ParseRole(value)
+		if err != nil {
+			malformed = append(malformed, value)
+			continue
+		}
+		roles = append(roles, role)
+	}
+	return roles, malformed
+}
+
+// The scheduler's permissions.
+const (
+	PermApproveLeave = "approve-leave"
+	PermEditEmployee = "edit-employee"
+	PermViewEmployee = "view-employee"
+	PermEditSchedule = "edit-schedule"
+	PermAdminister   = "administer"
+	PermImpersonate  = "impersonate"
+)
+
+// RoleHierarchy gives, for each application, the roles each role implies, so
+// a holder of the role also holds those it implies, within the same scope.
+var RoleHierarchy = map[string]map[string][]string{
+	"scheduler": {
+		"admin":     {"scheduler"},
+		"scheduler": {"employee"},
+	},
+}
+
+// Permissions gives, for each application, the roles granted each
+// permission.  A role implying one of those roles is granted it too.
+var Permissions = map[string]map[string][]string{
+	"scheduler": {
+		PermApproveLeave: {"scheduler"},
+		PermEditEmployee: {"scheduler"},
+		PermViewEmployee: {"employee"},
+		PermEditSchedule: {"scheduler"},
+		PermAdminister:   {"admin"},
+		PermImpersonate:  {"admin"},
+	},
+}
+
+// implies reports whether holding the role in the application includes the
+// target role, directly or through the hierarchy.
+func implies(app, role, target string) bool {
+	seen := make(map[string]bool)
+	pending := []string{strings.ToLower(role)}
+	target = strings.ToLower(target)
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		if current == target {
+			return true
+		}
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+		pending = append(pending, RoleHierarchy[strings.ToLower(app)][current]...)
+	}
+	return false
+}
+
+// GetRoles returns the user's roles, read from the legacy workgroups for a
+// user whose roles haven't been converted, skipping malformed workgroups.
+func (u *User) GetRoles() []Role {
+	if len(u.Roles) > 0 {
+		return u.Roles
+	}
+	roles, _ := ParseRoles(u.Workgroups)
+	return roles
+}
+
+// SetRoles replaces the user's roles, also writing them as workgroups for the
+// clients still reading those.
+func (u *User) SetRoles(roles []Role) {
+	u.Roles = append([]Role(nil), roles...)
+	u.Workgroups = nil
+	for _, role := range roles {
+		u.Workgroups = append(u.Workgroups, role.String())
+	}
+}
+
+// SyncRoles brings the user's roles up to date with the workgroups before the
+// user is saved, so GetRoles never reads roles older than the workgroups a
+// client still writing them has changed.  Workgroups naming other roles than
+// the user's replace them, refusing a malformed one, while a user whose roles
+// haven't been converted keeps the legacy workgroups as they are.
+func (u *User) SyncRoles() error {
+	if len(u.Roles) == 0 {
+		return nil
+	}
+	roles, malformed := ParseRoles(u.Workgroups)
+	if len(roles) == len(u.Roles) {
+		same := true
+		for i, role := range roles {
+			if role.String() != u.Roles[i].String() {
+				same = false
+				break
+			}
+		}
+		if same {
+			return nil
+		}
+	}
+	if len(malformed) > 0 {
+		return fmt.Errorf("malformed roles: %s", strings.Join(malformed, ", "))
+	}
+	u.SetRoles(roles)
+	return nil
+}
+
+// HasRole reports whether the user holds the role in the application over the
+// resource's scope, directly or through the role hierarchy.
+func (u *User) HasRole(app, role string, resource Scope) bool {
+	for _, held := range u.GetRoles() {
+		if strings.EqualFold(held.Application, app) &&
+			held.Covers(resource) && implies(app, held.Name, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRoleAnywhere reports whether the user holds the role in the application
+// at any scope.
+func (u *User) HasRoleAnywhere(app, role string) bool {
+	for _, held := range u.GetRoles() {
+		if strings.EqualFold(held.Application, app) &&
+			implies(app, held.Name, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the user has the application's permission over the
+// resource's scope, such as approving leave for a site.
+func (u *User) Can(app, permission string, resource Scope) bool {
+	granted := Permissions[strings.ToLower(app)][strings.ToLower(permission)]
+	for _, role := range granted {
+		if u.HasRole(app, role, resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAnywhere reports whether the user has the application's permission at
+// any scope.
+func (u *User) CanAnywhere(app, permission string) bool {
+	granted := Permissions[strings.ToLower(app)][strings.ToLower(permission)]
+	for _, role := range granted {
+		if u.HasRoleAnywhere(app, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanFrom reports whether the user, whose own team and site are home, has the
+// application's permission over the resource's scope.  Unlike Can, a role
+// without a scope only reaches the user's home team and site, and not at all
+// when home has no team, so a supervisor can't act on another team's
+// employees.  Reaching further takes a role scoped to the other team or site,
+// or the cross-team grant of a role scoped to AnyTeam.
+func (u *User) CanFrom(app, permission string, home, resource Scope) bool {
+	granted := Permissions[strings.ToLower(app)][strings.ToLower(permission)]
+	for _, held := range u.GetRoles() {
+		if !strings.EqualFold(held.Application, app) {
+			continue
+		}
+		reach := held.Scope
+		if reach.TeamID == "" {
+			if home.TeamID == "" {
+				continue
+			}
+			reach = home
+		}
+		if !reach.Covers(resource) {
+			continue
+		}
+		for _, role := range granted {
+			if implies(app, held.Name, role) {
+				return true
+			}
+		}
+	}
+	return false
+}
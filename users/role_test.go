@@ -0,0 +1,144 @@
+package users
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Role
+		wantErr bool
+	}{
+		{"scheduler-admin", Role{Application: "scheduler", Name: "admin"}, false},
+		{"Scheduler-Employee", Role{Application: "scheduler", Name: "employee"},
+			false},
+		{"scheduler-scheduler@dcgs", Role{Application: "scheduler",
+			Name: "scheduler", Scope: Scope{TeamID: "dcgs"}}, false},
+		{"scheduler-employee@dcgs/ofallon", Role{Application: "scheduler",
+			Name: "employee", Scope: Scope{TeamID: "dcgs", SiteID: "ofallon"}},
+			false},
+		{"scheduler-admin@*", Role{Application: "scheduler", Name: "admin",
+			Scope: Scope{TeamID: AnyTeam}}, false},
+		{"scheduler", Role{}, true},
+		{"-admin", Role{}, true},
+		{"scheduler-admin@", Role{}, true},
+		{"scheduler-admin@/site", Role{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRole(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRole(%q) error = %v, wantErr %v", tt.value, err,
+				tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseRole(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestHasRoleHierarchy(t *testing.T) {
+	user := &User{Workgroups: []string{"scheduler-admin@dcgs"}}
+	dcgs := Scope{TeamID: "dcgs"}
+	tests := []struct {
+		name     string
+		role     string
+		resource Scope
+		want     bool
+	}{
+		{"held role", "admin", dcgs, true},
+		{"implied role", "scheduler", dcgs, true},
+		{"transitively implied role", "employee", dcgs, true},
+		{"other team", "employee", Scope{TeamID: "other"}, false},
+		{"unlisted role", "auditor", dcgs, false},
+	}
+	for _, tt := range tests {
+		if got := user.HasRole("scheduler", tt.role, tt.resource); got != tt.want {
+			t.Errorf("%s: HasRole = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSyncRoles(t *testing.T) {
+	admin := Role{Application: "scheduler", Name: "admin"}
+	employee := Role{Application: "scheduler", Name: "employee",
+		Scope: Scope{TeamID: "dcgs"}}
+	tests := []struct {
+		name       string
+		roles      []Role
+		workgroups []string
+		want       []Role
+		wantErr    bool
+	}{
+		{"unconverted user keeps workgroups", nil,
+			[]string{"scheduler-admin", "bad"}, nil, false},
+		{"workgroups in step", []Role{admin},
+			[]string{"scheduler-admin"}, []Role{admin}, false},
+		{"legacy casing and malformed entry", []Role{admin},
+			[]string{"Scheduler-Admin", "bad"}, []Role{admin}, false},
+		{"workgroup added", []Role{admin},
+			[]string{"scheduler-admin", "scheduler-employee@dcgs"},
+			[]Role{admin, employee}, false},
+		{"workgroup removed", []Role{admin, employee},
+			[]string{"scheduler-employee@dcgs"}, []Role{employee}, false},
+		{"all workgroups removed", []Role{admin}, nil, nil, false},
+		{"malformed workgroup in a change", []Role{admin},
+			[]string{"scheduler-admin", "scheduler-employee@dcgs", "bad"},
+			[]Role{admin}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Roles: tt.roles, Workgroups: tt.workgroups}
+			err := user.SyncRoles()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SyncRoles error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(user.Roles, tt.want) {
+				t.Errorf("Roles = %v, want %v", user.Roles, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanFrom(t *testing.T) {
+	dcgs := Scope{TeamID: "dcgs"}
+	dcgsSite := Scope{TeamID: "dcgs", SiteID: "ofallon"}
+	other := Scope{TeamID: "other"}
+	tests := []struct {
+		name       string
+		workgroups []string
+		home       Scope
+		resource   Scope
+		want       bool
+	}{
+		{"unscoped role on home team", []string{"scheduler-scheduler"}, dcgs,
+			dcgsSite, true},
+		{"unscoped role on another team", []string{"scheduler-scheduler"}, dcgs,
+			other, false},
+		{"unscoped role without a home team", []string{"scheduler-scheduler"},
+			Scope{}, dcgs, false},
+		{"role scoped to the other team", []string{"scheduler-scheduler@other"},
+			dcgs, other, true},
+		{"role scoped to another site", []string{
+			"scheduler-scheduler@dcgs/ofallon"}, dcgsSite,
+			Scope{TeamID: "dcgs", SiteID: "elsewhere"}, false},
+		{"cross-team grant", []string{"scheduler-scheduler@*"}, dcgs, other,
+			true},
+		{"role not granted the permission", []string{"scheduler-employee@*"},
+			dcgs, other, false},
+		{"role of another application", []string{"journal-admin@*"}, dcgs,
+			dcgs, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Workgroups: tt.workgroups}
+			got := user.CanFrom("scheduler", PermApproveLeave, tt.home,
+				tt.resource)
+			if got != tt.want {
+				t.Errorf("CanFrom = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,27 +1,11 @@
 package users
 
-import (
-	"errors"
-	"fmt"
-	"strings"
-	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
-)
-
+// User is an account as the scheduler sees it, the shared identity with the
+// scheduler's roles.
 type User struct {
-	ID              primitive.ObjectID `json:"id" bson:"_id"`
-	EmailAddress    string             `json:"emailAddress" bson:"emailAddress"`
-	Password        string             `json:"password" bson:"password"`
-	PasswordExpires time.Time          `json:"passwordExpires" bson:"passwordExpires"`
-	BadAttempts     uint               `json:"badAttempts" bson:"badAttempts"`
-	FirstName       string             `json:"firstName" bson:"firstName"`
-	MiddleName      string             `json:"middleName,omitempty" bson:"middleName,omitempty"`
-	LastName        string             `json:"lastName" bson:"lastName"`
-	Workgroups      []string           `json:"workgroups" bson:"workgroups"`
-	ResetToken      string             `json:"-" bson:"resettoken,omitempty"`
-	ResetTokenExp   *time.Time         `json:"-" bson:"resettokenexp,omitempty"`
+	Identity   `bson:",inline"`
+	Workgroups []string `json:"workgroups" bson:"workgroups"`
+	Roles      []Role   `json:"roles,omitempty" bson:"roles,omitempty"`
 }
 
 type ByUser []User
@@ -38,57 +22,8 @@ func (c ByUser) Less(i, j int) bool {
 }
 func (c ByUser) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
 
+// IsInGroup reports whether the user holds the role in the application at any
+// scope, directly or through the role hierarchy.
 func (u *User) IsInGroup(app, group string) bool {
-	answer := false
-	for _, perm := range u.Workgroups {
-		parts := strings.Split(perm, "-")
-		if strings.EqualFold(app, parts[0]) && strings.EqualFold(group, parts[1]) {
-			answer = true
-		}
-	}
-	return answer
-}
-
-func (u *User) SetPassword(passwd string) error {
-	hashed, err := bcrypt.GenerateFromPassword([]byte(passwd), 12)
-	if err != nil {
-		return err
-	}
-	u.Password = string(hashed)
-	u.BadAttempts = 0
-	u.PasswordExpires = time.Now().UTC().AddDate(0, 0, 90)
-	return nil
-}
-
-func (u *User) Authenticate(passwd string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(passwd))
-	if err != nil {
-		u.BadAttempts++
-		return errors.New("Email Address/Password mismatch")
-	}
-
-	if u.PasswordExpires.Before(time.Now().UTC()) {
-		u.BadAttempts++
-		return errors.New("password expired")
-	}
-	if u.BadAttempts > 2 {
-		return errors.New("account locked")
-	}
-
-	u.BadAttempts = 0
-	return nil
-}
-
-func (u *User) GetFullName() string {
-	if u.MiddleName != "" {
-		return fmt.Sprintf("%s %s. %s", u.FirstName, u.MiddleName[:1], u.LastName)
-	}
-	return fmt.Sprintf("%s %s", u.FirstName, u.LastName)
-}
-
-func (u *User) GetLastFirst() string {
-	if u.MiddleName != "" {
-		return fmt.Sprintf("%s, %s %s.", u.LastName, u.FirstName, u.MiddleName[:1])
-	}
-	return fmt.Sprintf("%s, %s", u.LastName, u.FirstName)
+	return u.HasRoleAnywhere(app, group)
 }